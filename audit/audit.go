@@ -0,0 +1,218 @@
+// Package audit records who changed what in the frontend.
+//
+// Entries are appended to a file with one JSON object per line, and are never modified afterwards.
+package audit
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/pkg/errors"
+)
+
+// Outcomes of an action.
+const (
+	Success = "success"
+	Failure = "failure"
+	Denied  = "denied"
+)
+
+// Values of secret parameters, e.g. password, recording that the secret changed without revealing it.
+const (
+	SecretSet     = "set"
+	SecretCleared = "cleared"
+)
+
+// Entry describes a single action taken by a user.
+type Entry struct {
+	Time       time.Time         `json:"time"`
+	Actor      string            `json:"actor"`
+	SourceIP   string            `json:"source_ip"`
+	Action     string            `json:"action"`
+	Registry   string            `json:"registry,omitempty"`
+	Repository string            `json:"repository,omitempty"`
+	Tag        string            `json:"tag,omitempty"`
+	Digest     string            `json:"digest,omitempty"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Outcome    string            `json:"outcome"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// Filter selects entries when querying the log. Empty fields match every entry.
+type Filter struct {
+	Actor      string
+	Action     string
+	Registry   string
+	Repository string
+	Outcome    string
+	Since      time.Time
+	Until      time.Time
+}
+
+func (f Filter) matches(e Entry) bool {
+	return (f.Actor == "" || strings.EqualFold(f.Actor, e.Actor)) &&
+		(f.Action == "" || f.Action == e.Action) &&
+		(f.Registry == "" || f.Registry == e.Registry) &&
+		(f.Repository == "" || f.Repository == e.Repository) &&
+		(f.Outcome == "" || f.Outcome == e.Outcome) &&
+		(f.Since.IsZero() || !e.Time.Before(f.Since)) &&
+		(f.Until.IsZero() || e.Time.Before(f.Until))
+}
+
+// Log is an append-only audit log stored in a JSON lines file.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+}
+
+// Open opens the audit log at path, creating it if it does not exist.
+func Open(path string) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening audit log")
+	}
+
+	return &Log{path: path, f: f}, nil
+}
+
+// Record appends the entry to the log.
+// The values of parameters that look like they contain secrets are redacted, unless they are SecretSet or SecretCleared.
+func (l *Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	e.Parameters = redactSecrets(e.Parameters)
+
+	b, err := json.Marshal(e)
+
+	if err != nil {
+		return errors.Wrap(err, "failed encoding audit entry")
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.f.Write(append(b, '\n')); err != nil {
+		return errors.Wrap(err, "failed writing audit entry")
+	}
+
+	return errors.Wrap(l.f.Sync(), "failed writing audit entry")
+}
+
+// Query returns the entries matching the filter, newest first.
+func (l *Log) Query(f Filter) ([]Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, err := os.Open(l.path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening audit log")
+	}
+	defer file.Close()
+
+	var res []Entry
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		e := Entry{}
+
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, errors.Wrap(err, "corrupt audit log entry")
+		}
+
+		if f.matches(e) {
+			res = append(res, e)
+		}
+	}
+
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+
+	return res, errors.Wrap(scanner.Err(), "failed reading audit log")
+}
+
+// Close closes the underlying file.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.f.Close()
+}
+
+// WriteCSV writes the entries to w in CSV format, with a header row.
+// Cells that spreadsheets would run as formulas are prefixed with a quote.
+func WriteCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+
+	err := cw.Write([]string{"time", "actor", "source_ip", "action", "registry", "repository", "tag", "digest", "parameters", "outcome", "error"})
+
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		params := ""
+		if len(e.Parameters) > 0 {
+			b, _ := json.Marshal(e.Parameters)
+			params = string(b)
+		}
+
+		row := []string{
+			e.Time.Format(time.RFC3339),
+			e.Actor,
+			e.SourceIP,
+			e.Action,
+			e.Registry,
+			e.Repository,
+			e.Tag,
+			e.Digest,
+			params,
+			e.Outcome,
+			e.Error,
+		}
+
+		for i, cell := range row {
+			if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+				row[i] = "'" + cell
+			}
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func redactSecrets(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+
+	res := make(map[string]string, len(params))
+
+	for k, v := range params {
+		if secret.IsSecretName(k) && v != SecretSet && v != SecretCleared {
+			v = secret.Redacted
+		}
+		res[k] = v
+	}
+
+	return res
+}
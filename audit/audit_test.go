@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/secret"
+)
+
+func TestLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := Open(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	now := time.Now()
+	entries := []Entry{
+		{Time: now.Add(-2 * time.Hour), Actor: "alice", Action: "add_registry", Registry: "internal", Parameters: map[string]string{"url": "https://registry", "password": "hunter2", "client_key": SecretSet}, Outcome: Success},
+		{Time: now.Add(-time.Hour), Actor: "bob", Action: "delete_tag", Registry: "internal", Repository: "app", Tag: "1.0", Outcome: Denied},
+		{Time: now, Actor: "alice", Action: "delete_tag", Registry: "internal", Repository: "app", Tag: "1.1", Outcome: Success},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := l.Query(Filter{Actor: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res[0].Tag != "1.1" {
+		t.Errorf("expected alice's two entries newest first, got %+v", res)
+	}
+	if p := res[1].Parameters; p["password"] != secret.Redacted || p["client_key"] != SecretSet {
+		t.Errorf("expected the password to be redacted and the client key change to be kept, got %v", p)
+	}
+
+	res, _ = l.Query(Filter{Action: "delete_tag", Since: now.Add(-90 * time.Minute), Until: now.Add(-30 * time.Minute)})
+	if len(res) != 1 || res[0].Actor != "bob" {
+		t.Errorf("expected bob's entry, got %+v", res)
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "audit.log"))
+	if strings.Contains(string(content), "hunter2") {
+		t.Error("password was written to the audit log")
+	}
+
+	buf := &bytes.Buffer{}
+	res, _ = l.Query(Filter{})
+	if err := WriteCSV(buf, res); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(buf.String(), "\n"); lines != 4 {
+		t.Errorf("expected header and 3 rows, got %d lines", lines)
+	}
+}
+
+func TestWriteCSVFormulas(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := WriteCSV(buf, []Entry{{Actor: "=HYPERLINK(\"https://evil.example\")", Action: "login", Error: "-1+1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(buf.String(), `"'=HYPERLINK(""https://evil.example"")"`) || !strings.Contains(buf.String(), "'-1+1") {
+		t.Errorf("expected formulas to be quoted, got %s", buf.String())
+	}
+}
@@ -0,0 +1,55 @@
+// Package auth provides the means of authenticating users of the frontend.
+//
+// Users can be authenticated by a password checked against an htpasswd file,
+// by a header set by a trusted reverse proxy, or by logging in through an OpenID Connect provider.
+package auth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrUnauthenticated    = errors.New("not authenticated")
+)
+
+// User is an authenticated user of the frontend.
+type User struct {
+	Name   string
+	Groups []string
+}
+
+// PasswordAuthenticator verifies a username and password submitted through the login form.
+type PasswordAuthenticator interface {
+	Authenticate(user, password string) (*User, error)
+}
+
+// RequestAuthenticator identifies a user from the request alone.
+// If the request does not carry any identity, ErrUnauthenticated is returned.
+type RequestAuthenticator interface {
+	AuthenticateRequest(r *http.Request) (*User, error)
+}
+
+// Methods collects the authentication methods enabled for the frontend.
+// Any of the fields can be left empty.
+type Methods struct {
+	Password PasswordAuthenticator
+	Request  []RequestAuthenticator
+	OIDC     *OIDCProvider
+}
+
+type contextKey struct{}
+
+// WithUser returns a copy of ctx carrying the user.
+func WithUser(ctx context.Context, u *User) context.Context {
+	return context.WithValue(ctx, contextKey{}, u)
+}
+
+// UserFromContext returns the user stored in ctx, or nil if the request is anonymous.
+func UserFromContext(ctx context.Context) *User {
+	u, _ := ctx.Value(contextKey{}).(*User)
+	return u
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testHtpasswd(h *Htpasswd, user, password string, expected bool) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+		t.Parallel()
+		u, err := h.Authenticate(user, password)
+
+		if expected && (err != nil || u.Name != user) {
+			t.Errorf("expected %s to be authenticated, got %v", user, err)
+		}
+		if !expected && err != ErrInvalidCredentials {
+			t.Errorf("expected %s to be rejected, got %v", user, err)
+		}
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := ParseHtpasswd(strings.NewReader("# comment\n\nalice:" + string(hash) + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("correct password", testHtpasswd(h, "alice", "secret", true))
+	t.Run("wrong password", testHtpasswd(h, "alice", "wrong", false))
+	t.Run("unknown user", testHtpasswd(h, "bob", "secret", false))
+
+	if _, err := ParseHtpasswd(strings.NewReader("alice:{SHA}W6ph5Mm5Pz8GgiULbPgzG37mj9g=")); err == nil {
+		t.Error("expected SHA1 hashes to be rejected")
+	}
+}
+
+func testHeader(h *Header, remoteAddr, user string, expected bool) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+		t.Parallel()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-User", user)
+		r.Header.Set("X-Forwarded-Groups", "dev, ops")
+
+		u, err := h.AuthenticateRequest(r)
+
+		if expected && (err != nil || u.Name != user || len(u.Groups) != 2) {
+			t.Errorf("expected %s from %s to be authenticated, got %+v %v", user, remoteAddr, u, err)
+		}
+		if !expected && err != ErrUnauthenticated {
+			t.Errorf("expected %s from %s to be rejected, got %v", user, remoteAddr, err)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	h, err := NewHeader("X-Forwarded-User", "X-Forwarded-Groups", []string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("trusted range", testHeader(h, "10.1.2.3:4567", "alice", true))
+	t.Run("trusted address", testHeader(h, "192.168.1.1:4567", "alice", true))
+	t.Run("untrusted address", testHeader(h, "192.168.1.2:4567", "alice", false))
+	t.Run("missing header", testHeader(h, "10.1.2.3:4567", "", false))
+}
@@ -0,0 +1,43 @@
+package auth
+
+import (
+	"crypto/x509"
+	"net/http"
+)
+
+// ClientCert identifies users by the client certificate they presented when connecting over https.
+// Only certificates verified by the server are trusted, so the server must be configured with the client CAs.
+// The groups of the user are the organizational units of the certificate subject.
+type ClientCert struct {
+	// Email uses the first email address of the certificate as the user name, instead of the common name.
+	Email bool
+}
+
+var _ RequestAuthenticator = ClientCert{}
+
+func (c ClientCert) AuthenticateRequest(r *http.Request) (*User, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return nil, ErrUnauthenticated
+	}
+
+	cert := r.TLS.VerifiedChains[0][0]
+	name := c.userName(cert)
+
+	if name == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	return &User{Name: name, Groups: cert.Subject.OrganizationalUnit}, nil
+}
+
+func (c ClientCert) userName(cert *x509.Certificate) string {
+	if !c.Email {
+		return cert.Subject.CommonName
+	}
+
+	if len(cert.EmailAddresses) > 0 {
+		return cert.EmailAddresses[0]
+	}
+
+	return ""
+}
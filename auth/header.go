@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Header trusts the identity in a header set by a reverse proxy doing single sign-on.
+// The header is only trusted when the request comes from one of the trusted proxies,
+// as anybody else would be able to set it as well.
+type Header struct {
+	UserHeader   string
+	GroupsHeader string
+	trusted      []*net.IPNet
+}
+
+var _ RequestAuthenticator = &Header{}
+
+// NewHeader creates a Header authenticator reading the user name from userHeader,
+// and an optional comma separated list of groups from groupsHeader.
+// trustedProxies is a list of IP addresses or CIDR ranges the proxy will connect from.
+func NewHeader(userHeader, groupsHeader string, trustedProxies []string) (*Header, error) {
+	h := &Header{UserHeader: userHeader, GroupsHeader: groupsHeader}
+
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			if strings.Contains(p, ":") {
+				p += "/128"
+			} else {
+				p += "/32"
+			}
+		}
+
+		_, n, err := net.ParseCIDR(p)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid trusted proxy %q", p)
+		}
+
+		h.trusted = append(h.trusted, n)
+	}
+
+	return h, nil
+}
+
+func (h *Header) AuthenticateRequest(r *http.Request) (*User, error) {
+	name := r.Header.Get(h.UserHeader)
+
+	if name == "" || !h.isTrusted(r.RemoteAddr) {
+		return nil, ErrUnauthenticated
+	}
+
+	u := &User{Name: name}
+
+	if h.GroupsHeader != "" {
+		for _, g := range strings.Split(r.Header.Get(h.GroupsHeader), ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				u.Groups = append(u.Groups, g)
+			}
+		}
+	}
+
+	return u, nil
+}
+
+func (h *Header) isTrusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+
+	if ip == nil {
+		return false
+	}
+
+	for _, n := range h.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
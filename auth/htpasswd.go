@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Htpasswd authenticates users against the bcrypt hashes of an htpasswd file,
+// as created by `htpasswd -B`.
+type Htpasswd struct {
+	users map[string][]byte
+}
+
+var _ PasswordAuthenticator = &Htpasswd{}
+
+// LoadHtpasswd reads the htpasswd file at path.
+func LoadHtpasswd(path string) (*Htpasswd, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening htpasswd file")
+	}
+	defer f.Close()
+
+	return ParseHtpasswd(f)
+}
+
+// ParseHtpasswd reads htpasswd entries from r.
+// Only bcrypt hashes are accepted, as the other formats supported by htpasswd are considered insecure.
+func ParseHtpasswd(r io.Reader) (*Htpasswd, error) {
+	h := &Htpasswd{users: make(map[string][]byte)}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		l := strings.TrimSpace(scanner.Text())
+
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		i := strings.Index(l, ":")
+		if i <= 0 {
+			return nil, errors.Errorf("htpasswd line %d: missing username", line)
+		}
+
+		hash := l[i+1:]
+		if !strings.HasPrefix(hash, "$2y$") && !strings.HasPrefix(hash, "$2a$") && !strings.HasPrefix(hash, "$2b$") {
+			return nil, errors.Errorf("htpasswd line %d: only bcrypt hashes are supported", line)
+		}
+
+		h.users[l[:i]] = []byte(hash)
+	}
+
+	return h, errors.Wrap(scanner.Err(), "failed reading htpasswd file")
+}
+
+func (h *Htpasswd) Authenticate(user, password string) (*User, error) {
+	hash, ok := h.users[user]
+
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &User{Name: user}, nil
+}
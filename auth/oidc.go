@@ -0,0 +1,354 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// OIDCConfig describes how to log in through an OpenID Connect provider.
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL must point to the /login/oidc/callback route of the frontend.
+	RedirectURL string
+	// Scopes requested in addition to "openid".
+	Scopes []string
+	// UsernameClaim defaults to preferred_username, falling back to email and sub.
+	UsernameClaim string
+	// GroupsClaim defaults to groups.
+	GroupsClaim string
+}
+
+// OIDCProvider logs users in using the authorization code flow of an OpenID Connect provider.
+// Only RS256 signed ID tokens are supported.
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	c      *http.Client
+	meta   providerMetadata
+	mu     sync.Mutex
+	keys   map[string]*rsa.PublicKey
+	leeway time.Duration
+	// refreshed is when the keys were last fetched, which happens at most once per refreshInterval.
+	refreshed       time.Time
+	refreshInterval time.Duration
+}
+
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDCProvider discovers the endpoints of the provider at cfg.Issuer.
+// If c is nil, http.DefaultClient is used.
+func NewOIDCProvider(ctx context.Context, cfg OIDCConfig, c *http.Client) (*OIDCProvider, error) {
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	p := &OIDCProvider{cfg: cfg, c: c, leeway: time.Minute, refreshInterval: time.Minute}
+
+	u := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+
+	if err := p.getJSON(ctx, u, &p.meta); err != nil {
+		return nil, errors.Wrap(err, "failed discovering OpenID Connect provider")
+	}
+
+	if p.meta.Issuer != cfg.Issuer {
+		return nil, errors.Errorf("issuer mismatch: configured %q, provider reports %q", cfg.Issuer, p.meta.Issuer)
+	}
+
+	return p, nil
+}
+
+// AuthCodeURL returns the URL of the provider's login page.
+func (p *OIDCProvider) AuthCodeURL(state, nonce string) string {
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", p.cfg.ClientID)
+	v.Set("redirect_uri", p.cfg.RedirectURL)
+	v.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+
+	sep := "?"
+	if strings.Contains(p.meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+
+	return p.meta.AuthorizationEndpoint + sep + v.Encode()
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+// Exchange redeems the authorization code, and returns the user identified by the ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce string) (*User, error) {
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", p.cfg.RedirectURL)
+
+	req, err := http.NewRequest(http.MethodPost, p.meta.TokenEndpoint, strings.NewReader(v.Encode()))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create token request")
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.c.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed exchanging authorization code")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status code %d from token endpoint", resp.StatusCode)
+	}
+
+	tr := tokenResponse{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, errors.Wrap(err, "could not parse token response")
+	}
+
+	claims, err := p.verify(ctx, tr.IDToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("ID token nonce mismatch")
+	}
+
+	return p.user(claims)
+}
+
+func (p *OIDCProvider) user(claims map[string]interface{}) (*User, error) {
+	u := &User{}
+
+	names := []string{"preferred_username", "email", "sub"}
+	if p.cfg.UsernameClaim != "" {
+		names = []string{p.cfg.UsernameClaim}
+	}
+
+	for _, c := range names {
+		if s, ok := claims[c].(string); ok && s != "" {
+			u.Name = s
+			break
+		}
+	}
+
+	if u.Name == "" {
+		return nil, errors.New("ID token does not identify the user")
+	}
+
+	groupsClaim := p.cfg.GroupsClaim
+	if groupsClaim == "" {
+		groupsClaim = "groups"
+	}
+
+	if gs, ok := claims[groupsClaim].([]interface{}); ok {
+		for _, g := range gs {
+			if s, ok := g.(string); ok {
+				u.Groups = append(u.Groups, s)
+			}
+		}
+	}
+
+	return u, nil
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// verify checks the signature and standard claims of an ID token, and returns its claims.
+func (p *OIDCProvider) verify(ctx context.Context, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, errors.New("malformed ID token")
+	}
+
+	h := jwtHeader{}
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, errors.Wrap(err, "malformed ID token header")
+	}
+
+	if h.Alg != "RS256" {
+		return nil, errors.Errorf("unsupported ID token algorithm %q", h.Alg)
+	}
+
+	key, err := p.key(ctx, h.Kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed ID token signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sig); err != nil {
+		return nil, errors.Wrap(err, "invalid ID token signature")
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errors.Wrap(err, "malformed ID token claims")
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.cfg.Issuer {
+		return nil, errors.Errorf("ID token issued by %q", iss)
+	}
+
+	if !audienceContains(claims["aud"], p.cfg.ClientID) {
+		return nil, errors.New("ID token not issued for this client")
+	}
+
+	exp, _ := claims["exp"].(float64)
+	if time.Unix(int64(exp), 0).Add(p.leeway).Before(time.Now()) {
+		return nil, errors.New("ID token has expired")
+	}
+
+	return claims, nil
+}
+
+func audienceContains(aud interface{}, clientID string) bool {
+	switch a := aud.(type) {
+	case string:
+		return a == clientID
+	case []interface{}:
+		for _, v := range a {
+			if v == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type jwks struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// key returns the signing key with the given id, refreshing the key set if the key is unknown.
+// The key set is refreshed at most once per refreshInterval, so tokens with made up key ids
+// cannot make the frontend fetch the keys on every request. Unknown keys are rejected in between.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	if !p.refreshed.IsZero() && time.Since(p.refreshed) < p.refreshInterval {
+		return nil, errors.Errorf("unknown ID token signing key %q", kid)
+	}
+
+	p.refreshed = time.Now()
+	set := jwks{}
+
+	if err := p.getJSON(ctx, p.meta.JWKSURI, &set); err != nil {
+		return nil, errors.Wrap(err, "failed fetching provider keys")
+	}
+
+	p.keys = make(map[string]*rsa.PublicKey)
+
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+
+		p.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	return nil, errors.Errorf("unknown ID token signing key %q", kid)
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := p.c.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return errors.Wrap(err, "could not read response")
+	}
+
+	return errors.Wrap(json.Unmarshal(content, v), "could not parse response")
+}
+
+func decodeSegment(s string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// mockIssuer is a minimal OpenID Connect provider issuing ID tokens for a fixed code.
+type mockIssuer struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	claims map[string]interface{}
+	// keyFetches counts the requests for the key set.
+	keyFetches int
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &mockIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 m.URL,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		m.keyFetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "frontend" || secret != "s3cret" || r.FormValue("code") != "good-code" {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": m.sign(t)})
+	})
+	m.Server = httptest.NewServer(mux)
+	m.claims = map[string]interface{}{
+		"iss":                m.URL,
+		"aud":                "frontend",
+		"exp":                time.Now().Add(time.Hour).Unix(),
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"groups":             []string{"admins"},
+	}
+	return m
+}
+
+func (m *mockIssuer) sign(t *testing.T) string {
+	m.claims["nonce"] = m.nonce
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	c, _ := json.Marshal(m.claims)
+	signed := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	d := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, m.key, crypto.SHA256, d[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCProvider(t *testing.T) {
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	p, err := NewOIDCProvider(context.Background(), OIDCConfig{
+		Issuer:       m.URL,
+		ClientID:     "frontend",
+		ClientSecret: "s3cret",
+		RedirectURL:  "http://frontend/login/oidc/callback",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(p.AuthCodeURL("state", "nonce"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Query().Get("state") != "state" || u.Query().Get("client_id") != "frontend" {
+		t.Errorf("unexpected authorization URL %s", u)
+	}
+
+	m.nonce = "nonce"
+	user, err := p.Exchange(context.Background(), "good-code", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" || len(user.Groups) != 1 || user.Groups[0] != "admins" {
+		t.Errorf("unexpected user %+v", user)
+	}
+
+	if _, err := p.Exchange(context.Background(), "good-code", "other-nonce"); err == nil {
+		t.Error("expected nonce mismatch to be rejected")
+	}
+
+	if _, err := p.Exchange(context.Background(), "bad-code", "nonce"); err == nil {
+		t.Error("expected invalid code to be rejected")
+	}
+
+	m.claims["aud"] = "someone-else"
+	if _, err := p.Exchange(context.Background(), "good-code", "nonce"); err == nil {
+		t.Error("expected token for another audience to be rejected")
+	}
+}
+
+func TestOIDCKeyRefresh(t *testing.T) {
+	m := newMockIssuer(t)
+	defer m.Close()
+
+	p, err := NewOIDCProvider(context.Background(), OIDCConfig{Issuer: m.URL, ClientID: "frontend"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.key(context.Background(), "test"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := p.key(context.Background(), "unknown"); err == nil {
+			t.Error("expected an unknown key to be rejected")
+		}
+	}
+	if m.keyFetches != 1 {
+		t.Errorf("expected the keys to be fetched once within the refresh interval, got %d", m.keyFetches)
+	}
+
+	p.refreshed = p.refreshed.Add(-p.refreshInterval)
+	if _, err := p.key(context.Background(), "unknown"); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+	if m.keyFetches != 2 {
+		t.Errorf("expected the keys to be fetched again after the refresh interval, got %d", m.keyFetches)
+	}
+}
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Session is the server side state of a logged in user.
+type Session struct {
+	ID      string
+	User    User
+	Expires time.Time
+}
+
+// SessionStore keeps the sessions of logged in users in memory.
+// Sessions are lost when the frontend restarts, which requires users to log in again.
+type SessionStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	sessions map[string]Session
+	pending  map[string]pendingLogin
+}
+
+type pendingLogin struct {
+	nonce   string
+	next    string
+	expires time.Time
+}
+
+// NewSessionStore creates a SessionStore where sessions expire ttl after they were created.
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		ttl:      ttl,
+		sessions: make(map[string]Session),
+		pending:  make(map[string]pendingLogin),
+	}
+}
+
+// Create starts a new session for the user.
+func (s *SessionStore) Create(u User) (Session, error) {
+	id, err := RandomString(32)
+
+	if err != nil {
+		return Session{}, err
+	}
+
+	sess := Session{ID: id, User: u, Expires: time.Now().Add(s.ttl)}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+	s.sessions[id] = sess
+
+	return sess, nil
+}
+
+// Get returns the session with the given id, if it exists and has not expired.
+func (s *SessionStore) Get(id string) (Session, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[id]
+
+	if !ok || time.Now().After(sess.Expires) {
+		return Session{}, false
+	}
+
+	return sess, true
+}
+
+// Delete ends the session with the given id.
+func (s *SessionStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, id)
+}
+
+// BeginLogin remembers an OpenID Connect login in progress.
+// The returned state and nonce must be passed to the provider.
+func (s *SessionStore) BeginLogin(next string) (state, nonce string, err error) {
+	if state, err = RandomString(16); err != nil {
+		return "", "", err
+	}
+	if nonce, err = RandomString(16); err != nil {
+		return "", "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.expire()
+	s.pending[state] = pendingLogin{nonce: nonce, next: next, expires: time.Now().Add(10 * time.Minute)}
+
+	return state, nonce, nil
+}
+
+// CompleteLogin returns the nonce and the page to return to for the login started with state.
+// A state can only be used once.
+func (s *SessionStore) CompleteLogin(state string) (nonce, next string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.pending[state]
+	delete(s.pending, state)
+
+	if !ok || time.Now().After(p.expires) {
+		return "", "", false
+	}
+
+	return p.nonce, p.next, true
+}
+
+// expire removes expired sessions and logins. The caller must hold the lock.
+func (s *SessionStore) expire() {
+	now := time.Now()
+
+	for id, sess := range s.sessions {
+		if now.After(sess.Expires) {
+			delete(s.sessions, id)
+		}
+	}
+
+	for state, p := range s.pending {
+		if now.After(p.expires) {
+			delete(s.pending, state)
+		}
+	}
+}
+
+// RandomString returns n random bytes encoded as URL-safe base64.
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed generating random bytes")
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
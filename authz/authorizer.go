@@ -0,0 +1,16 @@
+package authz
+
+import "github.com/mikaellindemann/registryfrontend/auth"
+
+// Authorizer answers what a user may do. It is implemented by both Policy and PolicyFile.
+type Authorizer interface {
+	Role(u *auth.User, registry, repository string) Role
+	RegistryRole(u *auth.User, registry string) Role
+	CanView(u *auth.User, registry string) bool
+	IsAdmin(u *auth.User) bool
+}
+
+var (
+	_ Authorizer = &Policy{}
+	_ Authorizer = &PolicyFile{}
+)
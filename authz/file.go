@@ -0,0 +1,95 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/internal/watch"
+	"github.com/pkg/errors"
+)
+
+// PolicyFile is a Policy read from a file, which can be reloaded without restarting the frontend.
+// If reloading fails, the previously loaded policy stays in effect.
+type PolicyFile struct {
+	path    string
+	mu      sync.RWMutex
+	policy  *Policy
+	modTime time.Time
+}
+
+// OpenPolicyFile loads the policy file at path.
+func OpenPolicyFile(path string) (*PolicyFile, error) {
+	f := &PolicyFile{path: path}
+
+	return f, f.Reload()
+}
+
+// Reload reads the policy file again.
+func (f *PolicyFile) Reload() error {
+	info, err := os.Stat(f.path)
+
+	if err != nil {
+		return errors.Wrap(err, "failed reading policy file")
+	}
+
+	p, err := LoadPolicy(f.path)
+
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.policy = p
+	f.modTime = info.ModTime()
+
+	return nil
+}
+
+// Watch reloads the policy file whenever its modification time changes, checking every interval.
+// The result of every reload is passed to onReload. Watch returns when ctx is cancelled.
+func (f *PolicyFile) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	watch.Poll(ctx, interval, f.changed, f.Reload, onReload)
+}
+
+// changed reports whether the modification time of the policy file changed since the last reload.
+func (f *PolicyFile) changed() (bool, error) {
+	info, err := os.Stat(f.path)
+
+	if err != nil {
+		return false, errors.Wrap(err, "failed reading policy file")
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return !info.ModTime().Equal(f.modTime), nil
+}
+
+// Policy returns the currently loaded policy.
+func (f *PolicyFile) Policy() *Policy {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.policy
+}
+
+func (f *PolicyFile) Role(u *auth.User, registry, repository string) Role {
+	return f.Policy().Role(u, registry, repository)
+}
+
+func (f *PolicyFile) RegistryRole(u *auth.User, registry string) Role {
+	return f.Policy().RegistryRole(u, registry)
+}
+
+func (f *PolicyFile) CanView(u *auth.User, registry string) bool {
+	return f.Policy().CanView(u, registry)
+}
+
+func (f *PolicyFile) IsAdmin(u *auth.User) bool {
+	return f.Policy().IsAdmin(u)
+}
@@ -0,0 +1,231 @@
+// Package authz decides what users of the frontend are allowed to see and do.
+//
+// A Policy consists of rules granting a role to users and groups,
+// scoped to registries and repositories matching name patterns.
+// A user has the highest role granted by any matching rule, and no access when no rule matches.
+package authz
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Role describes what a user may do. Each role includes the permissions of the roles before it.
+type Role int
+
+const (
+	None Role = iota
+	// Viewer can browse registries, repositories and tags.
+	Viewer
+	// Deleter can additionally delete tags.
+	Deleter
+	// Admin can additionally add and remove registries.
+	Admin
+)
+
+var roleNames = map[string]Role{
+	"none":    None,
+	"viewer":  Viewer,
+	"deleter": Deleter,
+	"admin":   Admin,
+}
+
+func (r Role) String() string {
+	for name, role := range roleNames {
+		if role == r {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+func (r *Role) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	role, ok := roleNames[strings.ToLower(s)]
+
+	if !ok {
+		return errors.Errorf("unknown role %q", s)
+	}
+
+	*r = role
+	return nil
+}
+
+// Rule grants Role to the listed users and groups.
+// Users may contain "*" to match everybody, including anonymous users when authentication is disabled.
+// Registries and Repositories are patterns where "*" matches any sequence of characters, including "/".
+// Leaving out Registries or Repositories matches all of them.
+type Rule struct {
+	Users        []string `yaml:"users"`
+	Groups       []string `yaml:"groups"`
+	Registries   []string `yaml:"registries"`
+	Repositories []string `yaml:"repositories"`
+	Role         Role     `yaml:"role"`
+
+	registries   []*regexp.Regexp
+	repositories []*regexp.Regexp
+}
+
+type Policy struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// ParsePolicy reads a policy in YAML format.
+func ParsePolicy(content []byte) (*Policy, error) {
+	p := &Policy{}
+
+	if err := yaml.UnmarshalStrict(content, p); err != nil {
+		return nil, errors.Wrap(err, "could not parse policy")
+	}
+
+	for i := range p.Rules {
+		r := &p.Rules[i]
+
+		if len(r.Users) == 0 && len(r.Groups) == 0 {
+			return nil, errors.Errorf("rule %d: no users or groups", i+1)
+		}
+
+		var err error
+		if r.registries, err = compilePatterns(r.Registries); err != nil {
+			return nil, errors.Wrapf(err, "rule %d", i+1)
+		}
+		if r.repositories, err = compilePatterns(r.Repositories); err != nil {
+			return nil, errors.Wrapf(err, "rule %d", i+1)
+		}
+	}
+
+	return p, nil
+}
+
+// LoadPolicy reads the policy file at path.
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading policy file")
+	}
+
+	return ParsePolicy(content)
+}
+
+// Role returns the role of the user in the repository of the registry.
+// u is nil for anonymous users.
+func (p *Policy) Role(u *auth.User, registry, repository string) Role {
+	res := None
+
+	for _, r := range p.Rules {
+		if r.Role > res && r.appliesTo(u) && matchesAny(r.registries, registry) && matchesAny(r.repositories, repository) {
+			res = r.Role
+		}
+	}
+
+	return res
+}
+
+// RegistryRole returns the role of the user for the registry as a whole,
+// only considering rules that are not restricted to some of its repositories.
+func (p *Policy) RegistryRole(u *auth.User, registry string) Role {
+	res := None
+
+	for _, r := range p.Rules {
+		if r.Role > res && r.appliesTo(u) && matchesAny(r.registries, registry) && len(r.repositories) == 0 {
+			res = r.Role
+		}
+	}
+
+	return res
+}
+
+// CanView reports whether the user can see the registry, which is the case
+// if the user can view at least some of its repositories.
+func (p *Policy) CanView(u *auth.User, registry string) bool {
+	for _, r := range p.Rules {
+		if r.Role >= Viewer && r.appliesTo(u) && matchesAny(r.registries, registry) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsAdmin reports whether the user is admin of any registry, and may thus add new registries.
+func (p *Policy) IsAdmin(u *auth.User) bool {
+	for _, r := range p.Rules {
+		if r.Role >= Admin && r.appliesTo(u) && len(r.repositories) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) appliesTo(u *auth.User) bool {
+	for _, name := range r.Users {
+		if name == "*" || (u != nil && name == u.Name) {
+			return true
+		}
+	}
+
+	if u == nil {
+		return false
+	}
+
+	for _, g := range r.Groups {
+		for _, ug := range u.Groups {
+			if g == ug {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+
+	for _, p := range patterns {
+		if p == "" {
+			return nil, errors.New("empty pattern")
+		}
+
+		parts := strings.Split(p, "*")
+		for i := range parts {
+			parts[i] = regexp.QuoteMeta(parts[i])
+		}
+
+		re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid pattern %q", p)
+		}
+
+		res = append(res, re)
+	}
+
+	return res, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
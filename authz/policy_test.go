@@ -0,0 +1,73 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+)
+
+const testPolicy = `
+rules:
+  - users: ["*"]
+    registries: [public]
+    role: viewer
+  - groups: [developers]
+    registries: [internal]
+    repositories: ["team-a/*"]
+    role: deleter
+  - users: [alice]
+    role: admin
+`
+
+func testRole(p *Policy, u *auth.User, registry, repository string, expected Role) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+		t.Parallel()
+		actual := p.Role(u, registry, repository)
+
+		if expected != actual {
+			t.Errorf("expected %s was %s", expected, actual)
+		}
+	}
+}
+
+func TestPolicyRole(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alice := &auth.User{Name: "alice"}
+	bob := &auth.User{Name: "bob", Groups: []string{"developers"}}
+
+	t.Run("anonymous public", testRole(p, nil, "public", "library/alpine", Viewer))
+	t.Run("anonymous internal", testRole(p, nil, "internal", "team-a/app", None))
+	t.Run("bob team-a", testRole(p, bob, "internal", "team-a/app", Deleter))
+	t.Run("bob nested team-a", testRole(p, bob, "internal", "team-a/sub/app", Deleter))
+	t.Run("bob team-b", testRole(p, bob, "internal", "team-b/app", None))
+	t.Run("bob public", testRole(p, bob, "public", "library/alpine", Viewer))
+	t.Run("alice anywhere", testRole(p, alice, "internal", "team-b/app", Admin))
+
+	if !p.CanView(bob, "internal") || p.CanView(nil, "internal") {
+		t.Error("unexpected registry visibility")
+	}
+	if p.RegistryRole(bob, "internal") != None || p.RegistryRole(alice, "internal") != Admin {
+		t.Error("unexpected registry role")
+	}
+	if p.IsAdmin(bob) || !p.IsAdmin(alice) {
+		t.Error("unexpected admin status")
+	}
+}
+
+func TestParsePolicyErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown role":  "rules:\n  - users: [a]\n    role: owner\n",
+		"no subjects":   "rules:\n  - role: viewer\n",
+		"unknown field": "rules:\n  - users: [a]\n    rol: viewer\n",
+		"empty pattern": "rules:\n  - users: [a]\n    registries: ['']\n    role: viewer\n",
+	} {
+		if _, err := ParsePolicy([]byte(content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// ErrNotImage is returned by Tag for manifests that are artifacts rather than container images.
+var ErrNotImage = errors.New("the manifest is not a container image")
+
+// imageConfigMediaTypes are the config media types of container images.
+var imageConfigMediaTypes = map[string]bool{
+	"application/vnd.docker.container.image.v1+json": true,
+	"application/vnd.oci.image.config.v1+json":       true,
+}
+
+// artifactKinds names common artifact types, and the config and layer media types of artifacts pushed without one.
+var artifactKinds = map[string]string{
+	HelmConfigMediaType:                                         "Helm chart",
+	HelmChartMediaType:                                          "Helm chart",
+	"application/vnd.wasm.config.v0+json":                       "WebAssembly module",
+	"application/vnd.wasm.content.layer.v1+wasm":                "WebAssembly module",
+	"application/vnd.module.wasm.content.layer.v1+wasm":         "WebAssembly module",
+	"application/spdx+json":                                     "SPDX SBOM",
+	"text/spdx":                                                 "SPDX SBOM",
+	"application/vnd.cyclonedx+json":                            "CycloneDX SBOM",
+	"application/vnd.cyclonedx+xml":                             "CycloneDX SBOM",
+	"application/vnd.dev.cosign.artifact.sig.v1+json":           "Cosign signature",
+	"application/vnd.dev.cosign.simplesigning.v1+json":          "Cosign signature",
+	"application/vnd.dev.cosign.artifact.att.v1+json":           "Attestation",
+	"application/vnd.dsse.envelope.v1+json":                     "Attestation",
+	"application/vnd.in-toto+json":                              "Attestation",
+	"application/vnd.cncf.notary.signature":                     "Notary signature",
+	"application/vnd.dev.sigstore.bundle.v0.3+json":             "Sigstore bundle",
+	"application/vnd.oci.image.index.v1+json":                   "Image index",
+	"application/vnd.docker.distribution.manifest.list.v2+json": "Image index",
+	"application/vnd.oci.image.config.v1+json":                  "Container image",
+	"application/vnd.docker.container.image.v1+json":            "Container image",
+}
+
+// ArtifactKind names the first of the media types that is recognized, e.g. Helm chart, or is OCI artifact if none is.
+func ArtifactKind(mediaTypes ...string) string {
+	for _, t := range mediaTypes {
+		if k, ok := artifactKinds[t]; ok {
+			return k
+		}
+	}
+	return "OCI artifact"
+}
+
+// isArtifact reports whether a manifest has an artifact type, or a config that is not an image configuration.
+func isArtifact(dto manifestDto) bool {
+	return dto.ArtifactType != "" || (dto.Config != nil && dto.Config.MediaType != "" && !imageConfigMediaTypes[dto.Config.MediaType])
+}
+
+// Artifact describes the manifest referenced by a tag or digest, whether or not it is an image.
+func (v *V2Client) Artifact(ctx context.Context, repository, reference string) (*registryfrontend.ArtifactInfo, error) {
+	m, err := v.Manifest(ctx, repository, reference)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dto := struct {
+		MediaType    string                        `json:"mediaType"`
+		ArtifactType string                        `json:"artifactType"`
+		Config       *registryfrontend.Descriptor  `json:"config"`
+		Layers       []registryfrontend.Descriptor `json:"layers"`
+		Manifests    []registryfrontend.Descriptor `json:"manifests"`
+		Subject      *registryfrontend.Descriptor  `json:"subject"`
+		Annotations  map[string]string             `json:"annotations"`
+	}{}
+
+	if err := json.Unmarshal(m.Content, &dto); err != nil {
+		return nil, errors.Wrap(err, "could not parse registry response")
+	}
+
+	info := &registryfrontend.ArtifactInfo{
+		MediaType:    m.MediaType,
+		ArtifactType: dto.ArtifactType,
+		Subject:      dto.Subject,
+		Annotations:  dto.Annotations,
+		Layers:       append(dto.Layers, dto.Manifests...),
+	}
+
+	if info.MediaType == "" {
+		info.MediaType = dto.MediaType
+	}
+
+	// The kind is preferably named by the artifact type, then by the layers, as e.g. cosign signatures have an image config.
+	mediaTypes := []string{dto.ArtifactType}
+
+	for _, l := range info.Layers {
+		info.Size += l.Size
+		mediaTypes = append(mediaTypes, l.MediaType)
+	}
+
+	if dto.Config != nil {
+		mediaTypes = append(mediaTypes, dto.Config.MediaType)
+
+		if info.ArtifactType == "" {
+			info.ArtifactType = dto.Config.MediaType
+		}
+	}
+
+	info.Kind = ArtifactKind(append(mediaTypes, info.MediaType)...)
+
+	if dto.Config != nil && dto.Config.MediaType == HelmConfigMediaType {
+		if info.Chart, err = readChart(ctx, v, repository, *dto.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	if created, err := time.Parse(time.RFC3339, dto.Annotations["org.opencontainers.image.created"]); err == nil {
+		info.Created = created
+	}
+
+	return info, nil
+}
+
+const (
+	// DSSEMediaType is the media type of the DSSE envelopes of in-toto attestations, e.g. pushed by cosign attest.
+	DSSEMediaType = "application/vnd.dsse.envelope.v1+json"
+
+	// MaxAttestationSize limits the size of the SBOMs and attestations read into memory.
+	MaxAttestationSize = 32 << 20
+)
+
+// AttachedLayer is a layer of a manifest attached to an image, e.g. a signature, SBOM or attestation.
+type AttachedLayer struct {
+	registryfrontend.Descriptor
+	// Source is the tag the manifest was found by, or referrers if it was found by the referrers API.
+	Source string
+}
+
+// Attached lists the layers of the manifests attached to the image with digest d, found either by the tags cosign uses,
+// sha256-<hash> followed by one of the suffixes, e.g. .sig, or by the referrers API for referrers of the artifact types.
+// Layers found both ways are listed once.
+func Attached(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest, suffixes []string, artifactTypes map[string]bool) ([]AttachedLayer, error) {
+	type source struct {
+		name      string
+		reference string
+	}
+
+	tag := d.Algorithm().String() + "-" + d.Encoded()
+	sources := make([]source, 0, len(suffixes))
+
+	for _, s := range suffixes {
+		sources = append(sources, source{tag + s, tag + s})
+	}
+
+	referrers, err := c.Referrers(ctx, repository, d)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range referrers {
+		if artifactTypes[r.ArtifactType] {
+			sources = append(sources, source{"referrers", r.Digest.String()})
+		}
+	}
+
+	var layers []AttachedLayer
+	seen := make(map[digest.Digest]bool)
+
+	for _, src := range sources {
+		m, err := c.Manifest(ctx, repository, src.reference)
+
+		if StatusCode(err) == http.StatusNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "failed fetching %s", src.name)
+		}
+
+		manifest := struct {
+			Layers []registryfrontend.Descriptor `json:"layers"`
+		}{}
+
+		if err := json.Unmarshal(m.Content, &manifest); err != nil {
+			return nil, errors.Wrapf(err, "could not parse %s", src.name)
+		}
+
+		for _, l := range manifest.Layers {
+			if !seen[l.Digest] {
+				seen[l.Digest] = true
+				layers = append(layers, AttachedLayer{Descriptor: l, Source: src.name})
+			}
+		}
+	}
+
+	return layers, nil
+}
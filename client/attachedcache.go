@@ -0,0 +1,87 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+)
+
+// AttachedCache keeps what was read from the manifests attached to images in memory, e.g. their SBOMs or attested
+// vulnerability reports, so it is not fetched again on every request.
+// Entries expire after the ttl, which picks up manifests attached to an image later.
+type AttachedCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	evicted func(value interface{})
+
+	mu      sync.Mutex
+	entries map[string]attachedEntry
+}
+
+type attachedEntry struct {
+	value   interface{}
+	fetched time.Time
+}
+
+// NewAttachedCache creates a cache whose entries expire after the ttl.
+// If evicted is not nil, it is called with the value of every expired entry as it is removed.
+func NewAttachedCache(ttl time.Duration, evicted func(value interface{})) *AttachedCache {
+	return &AttachedCache{ttl: ttl, now: time.Now, evicted: evicted, entries: make(map[string]attachedEntry)}
+}
+
+// Get returns the value cached for the image with digest d, calling fetch if there is none or it has expired.
+// Errors of fetch are not cached. Entries are kept apart by the URL of the registry as well as its name,
+// so a registry pointed at another URL does not see the values of the old one.
+func (c *AttachedCache) Get(reg registryfrontend.Client, repository string, d digest.Digest, fetch func() (interface{}, error)) (interface{}, error) {
+	key := reg.Name() + " " + reg.URL() + "/" + repository + "@" + d.String()
+	now := c.now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+
+	if ok && now.Sub(e.fetched) < c.ttl {
+		return e.value, nil
+	}
+
+	value, err := fetch()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The entry being fetched again is replaced rather than evicted.
+	delete(c.entries, key)
+	c.expire(now)
+	c.entries[key] = attachedEntry{value: value, fetched: now}
+
+	return value, nil
+}
+
+// Expire removes the expired entries.
+func (c *AttachedCache) Expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expire(c.now())
+}
+
+// expire removes the entries expired at now. The caller must hold the lock.
+func (c *AttachedCache) expire(now time.Time) {
+	for k, e := range c.entries {
+		if now.Sub(e.fetched) < c.ttl {
+			continue
+		}
+
+		delete(c.entries, k)
+
+		if c.evicted != nil {
+			c.evicted(e.value)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+)
+
+func TestAttachedCache(t *testing.T) {
+	c := NewAttachedCache(time.Hour, nil)
+	d := digest.FromString("image")
+
+	fetches := 0
+	get := func(url string) func(*testing.T) {
+		return func(t *testing.T) {
+			reg, _ := MakeV2("reg", url)
+			if _, err := c.Get(reg, "app", d, func() (interface{}, error) {
+				fetches++
+				return url, nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("first", get("https://a.example"))
+	t.Run("cached", get("https://a.example"))
+	t.Run("moved", get("https://b.example"))
+
+	if fetches != 2 {
+		t.Errorf("expected a fetch for each URL of the registry, got %d", fetches)
+	}
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// Cache reuses the clients of registries, and with them their connections, tokens and logins,
+// until the configuration or the credentials of a registry change.
+type Cache struct {
+	mu      sync.Mutex
+	clients map[string]cachedClient
+}
+
+type cachedClient struct {
+	reg    registryfrontend.Registry
+	creds  Credentials
+	client registryfrontend.Client
+}
+
+func NewCache() *Cache {
+	return &Cache{clients: make(map[string]cachedClient)}
+}
+
+// Client returns the cached client of the registry, or creates one like New if the registry is new or has changed.
+func (c *Cache) Client(r registryfrontend.Registry) (registryfrontend.Client, error) {
+	k, ok := kinds[r.Kind]
+
+	if !ok {
+		return nil, errors.Errorf("registry %s has unknown kind %q", r.Name, r.Kind)
+	}
+
+	// Credentials of the provider may change, e.g. when the Docker configuration is edited.
+	creds := resolveCredentials(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.clients[r.Name]; ok && cached.creds == creds && reflect.DeepEqual(cached.reg, r) {
+		return cached.client, nil
+	}
+
+	client, err := newClient(r, k, creds)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.clients[r.Name] = cachedClient{reg: r, creds: creds, client: client}
+
+	return client, nil
+}
+
+// Forget removes the client of the registry with the given name, e.g. when it is updated or removed.
+func (c *Cache) Forget(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.clients, name)
+}
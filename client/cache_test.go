@@ -0,0 +1,34 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+)
+
+func TestCache(t *testing.T) {
+	c := NewCache()
+	reg := registryfrontend.Registry{Name: "internal", Url: "https://registry.internal", User: "ci", Password: "s3cret", Repositories: []string{"app"}}
+
+	first, err := c.Client(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := c.Client(reg); again != first {
+		t.Error("expected the client to be reused")
+	}
+
+	reg.Password = "changed"
+	changed, err := c.Client(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == first {
+		t.Error("expected a new client when the registry changed")
+	}
+
+	c.Forget(reg.Name)
+	if forgotten, _ := c.Client(reg); forgotten == changed {
+		t.Error("expected a new client after forgetting the registry")
+	}
+}
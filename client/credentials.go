@@ -0,0 +1,350 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// dockerHubServer is the server Docker stores the credentials of Docker Hub under.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// Credentials authenticate the frontend with a registry.
+type Credentials struct {
+	User     string
+	Password string
+	// IdentityToken is an OAuth2 refresh token exchanged for registry tokens, used instead of the password.
+	IdentityToken string
+}
+
+// CredentialProvider looks up the credentials of registries by host, e.g. registry.example.com:5000.
+// Empty credentials are returned for unknown hosts.
+type CredentialProvider interface {
+	Credentials(host string) (Credentials, error)
+}
+
+var provider struct {
+	sync.RWMutex
+	p       CredentialProvider
+	onError func(error)
+}
+
+// SetCredentialProvider makes New look up the credentials of registries opting in with DockerConfig
+// and configured without a user or password, or stop doing so if p is nil.
+// Registries whose credentials cannot be looked up are accessed anonymously, passing the error to onError.
+func SetCredentialProvider(p CredentialProvider, onError func(error)) {
+	provider.Lock()
+	defer provider.Unlock()
+
+	provider.p = p
+	provider.onError = onError
+}
+
+// resolveCredentials returns the credentials of the registry, asking the credential provider if it has none
+// and opted in.
+func resolveCredentials(r registryfrontend.Registry) Credentials {
+	c := Credentials{User: r.User, Password: r.Password}
+
+	provider.RLock()
+	p, onError := provider.p, provider.onError
+	provider.RUnlock()
+
+	if c != (Credentials{}) || !r.DockerConfig || p == nil {
+		return c
+	}
+
+	u, err := url.Parse(r.Url)
+
+	if err == nil {
+		c, err = p.Credentials(u.Host)
+	}
+
+	if err != nil {
+		if onError != nil {
+			onError(errors.Wrapf(err, "registry %s: failed looking up credentials", r.Name))
+		}
+		return Credentials{}
+	}
+
+	return c
+}
+
+// DockerConfig is the configuration file of the Docker CLI, usually ~/.docker/config.json.
+// Credentials are read from its auths, or from the credential helpers it names, e.g. docker-credential-ecr-login.
+type DockerConfig struct {
+	Auths map[string]dockerAuth `json:"auths"`
+	// CredsStore is the credential helper used for all registries not in CredHelpers.
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+
+	// helper runs credential helpers, and is replaced by tests.
+	helper func(ctx context.Context, name, server string) (Credentials, error)
+
+	mu    sync.Mutex
+	cache map[string]cachedCredentials
+}
+
+type dockerAuth struct {
+	// Auth is the base64 encoded user and password, separated by a colon.
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+type cachedCredentials struct {
+	c       Credentials
+	expires time.Time
+}
+
+// helperCacheTTL is how long the credentials of helpers are reused, as registries are looked up on every request.
+const helperCacheTTL = time.Minute
+
+// ParseDockerConfig reads a Docker configuration file in JSON format.
+func ParseDockerConfig(content []byte) (*DockerConfig, error) {
+	d := &DockerConfig{helper: runCredentialHelper, cache: make(map[string]cachedCredentials)}
+
+	if err := json.Unmarshal(content, d); err != nil {
+		return nil, errors.Wrap(err, "could not parse docker config")
+	}
+
+	for server, a := range d.Auths {
+		if _, err := a.credentials(); err != nil {
+			return nil, errors.Wrapf(err, "auths[%s]", server)
+		}
+	}
+
+	return d, nil
+}
+
+// ParsePullSecret reads the Docker configuration of a Kubernetes imagePullSecret of type kubernetes.io/dockerconfigjson.
+// The content is either the Secret manifest in YAML or JSON, its .dockerconfigjson payload, or the payload in base64.
+func ParsePullSecret(content []byte) (*DockerConfig, error) {
+	content = bytes.TrimSpace(content)
+
+	if decoded, err := base64.StdEncoding.DecodeString(string(content)); err == nil {
+		content = decoded
+	}
+
+	secret := struct {
+		Kind       string            `yaml:"kind"`
+		Data       map[string]string `yaml:"data"`
+		StringData map[string]string `yaml:"stringData"`
+	}{}
+
+	// JSON is YAML as well, so payloads are told apart from manifests by their kind.
+	if err := yaml.Unmarshal(content, &secret); err != nil || secret.Kind != "Secret" {
+		return ParseDockerConfig(content)
+	}
+
+	if payload, ok := secret.StringData[".dockerconfigjson"]; ok {
+		return ParseDockerConfig([]byte(payload))
+	}
+
+	payload, ok := secret.Data[".dockerconfigjson"]
+
+	if !ok {
+		return nil, errors.New("the secret has no .dockerconfigjson, it must be of type kubernetes.io/dockerconfigjson")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "the .dockerconfigjson of the secret is not valid base64")
+	}
+
+	return ParseDockerConfig(decoded)
+}
+
+// LoadDockerConfig reads the Docker configuration file at path.
+func LoadDockerConfig(path string) (*DockerConfig, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading docker config")
+	}
+
+	return ParseDockerConfig(content)
+}
+
+// Credentials returns the credentials of the host, preferring a credential helper over the auths, like Docker does.
+func (d *DockerConfig) Credentials(host string) (Credentials, error) {
+	host = normalizeServer(host)
+
+	name := d.CredsStore
+
+	for server, h := range d.CredHelpers {
+		if normalizeServer(server) == host {
+			name = h
+		}
+	}
+
+	if name != "" {
+		return d.helperCredentials(name, host)
+	}
+
+	for server, a := range d.Auths {
+		if normalizeServer(server) == host {
+			return a.credentials()
+		}
+	}
+
+	return Credentials{}, nil
+}
+
+// Logins returns the credentials stored in the auths of the configuration by host, without running credential helpers.
+func (d *DockerConfig) Logins() map[string]Credentials {
+	res := make(map[string]Credentials, len(d.Auths))
+
+	for server, a := range d.Auths {
+		// Parsing has already checked the auths.
+		c, _ := a.credentials()
+		res[normalizeServer(server)] = c
+	}
+
+	return res
+}
+
+// KindOfHost returns the hosted kind whose registry is at host, e.g. ghcr for ghcr.io, or empty for other hosts.
+func KindOfHost(host string) string {
+	host = normalizeServer(host)
+
+	for name, k := range kinds {
+		if k.url != "" && normalizeServer(k.url) == host {
+			return name
+		}
+	}
+
+	return ""
+}
+
+func (d *DockerConfig) helperCredentials(name, host string) (Credentials, error) {
+	key := name + "/" + host
+
+	d.mu.Lock()
+	cached, ok := d.cache[key]
+	d.mu.Unlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		return cached.c, nil
+	}
+
+	server := host
+	if host == "index.docker.io" {
+		server = dockerHubServer
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	c, err := d.helper(ctx, name, server)
+
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	d.mu.Lock()
+	d.cache[key] = cachedCredentials{c: c, expires: time.Now().Add(helperCacheTTL)}
+	d.mu.Unlock()
+
+	return c, nil
+}
+
+func (a dockerAuth) credentials() (Credentials, error) {
+	c := Credentials{User: a.Username, Password: a.Password, IdentityToken: a.IdentityToken}
+
+	if a.Auth == "" {
+		return c, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(a.Auth)
+
+	if err != nil {
+		return c, errors.Wrap(err, "auth is not valid base64")
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+
+	if len(parts) != 2 {
+		return c, errors.New("auth must be a user and password separated by a colon")
+	}
+
+	c.User, c.Password = parts[0], parts[1]
+
+	// Docker stores logins with identity tokens with an empty password.
+	if c.IdentityToken != "" {
+		c.Password = ""
+	}
+
+	return c, nil
+}
+
+// normalizeServer turns the servers of Docker configurations, e.g. https://index.docker.io/v1/, into hosts,
+// folding the hosts of Docker Hub into one.
+func normalizeServer(server string) string {
+	server = strings.ToLower(server)
+
+	if i := strings.Index(server, "://"); i >= 0 {
+		server = server[i+3:]
+	}
+
+	if i := strings.Index(server, "/"); i >= 0 {
+		server = server[:i]
+	}
+
+	switch server {
+	case "docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		return "index.docker.io"
+	}
+
+	return server
+}
+
+// runCredentialHelper gets the credentials of the server from docker-credential-<name>,
+// following the protocol of https://github.com/docker/docker-credential-helpers.
+func runCredentialHelper(ctx context.Context, name, server string) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "docker-credential-"+name, "get")
+	cmd.Stdin = strings.NewReader(server)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + " " + stderr.String())
+
+		// Helpers report unknown servers on stdout, and exit with an error.
+		if strings.Contains(msg, "credentials not found") {
+			return Credentials{}, nil
+		}
+
+		return Credentials{}, errors.Wrapf(err, "credential helper %s failed: %s", name, msg)
+	}
+
+	dto := struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}{}
+
+	if err := json.Unmarshal(stdout.Bytes(), &dto); err != nil {
+		return Credentials{}, errors.Wrapf(err, "could not parse the output of credential helper %s", name)
+	}
+
+	// Helpers return identity tokens with the user <token>.
+	if dto.Username == "<token>" {
+		return Credentials{IdentityToken: dto.Secret}, nil
+	}
+
+	return Credentials{User: dto.Username, Password: dto.Secret}, nil
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+func TestDockerConfig(t *testing.T) {
+	d, err := ParseDockerConfig([]byte(`{
+		"auths": {
+			"https://index.docker.io/v1/": {"auth": "Y2k6czNjcmV0"},
+			"registry.internal:5000": {"auth": "Y2k6", "identitytoken": "refr3sh"}
+		},
+		"credHelpers": {"ecr.example.com": "ecr-login"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var helped []string
+	d.helper = func(ctx context.Context, name, server string) (Credentials, error) {
+		helped = append(helped, name+" "+server)
+		return Credentials{User: "AWS", Password: "ecr"}, nil
+	}
+
+	credentials := func(host string, expected Credentials) func(*testing.T) {
+		return func(t *testing.T) {
+			c, err := d.Credentials(host)
+			if err != nil || c != expected {
+				t.Errorf("expected %+v, got %+v %v", expected, c, err)
+			}
+		}
+	}
+
+	t.Run("docker hub", credentials("registry-1.docker.io", Credentials{User: "ci", Password: "s3cret"}))
+	t.Run("identity token", credentials("registry.internal:5000", Credentials{User: "ci", IdentityToken: "refr3sh"}))
+	t.Run("helper", credentials("ecr.example.com", Credentials{User: "AWS", Password: "ecr"}))
+	t.Run("cached helper", credentials("ECR.example.com", Credentials{User: "AWS", Password: "ecr"}))
+	t.Run("unknown", credentials("ghcr.io", Credentials{}))
+
+	if len(helped) != 1 || helped[0] != "ecr-login ecr.example.com" {
+		t.Errorf("expected the helper to run once, ran %v", helped)
+	}
+
+	if _, err := ParseDockerConfig([]byte(`{"auths":{"ghcr.io":{"auth":"not base64"}}}`)); err == nil {
+		t.Error("expected invalid auth to be rejected")
+	}
+}
+
+func TestCredentialProvider(t *testing.T) {
+	srv := fakeProvider(t, nil)
+	defer srv.Close()
+
+	u, _ := url.Parse(srv.URL)
+	d, err := ParseDockerConfig([]byte(fmt.Sprintf(`{"auths":{"%s":{"identitytoken":"refr3sh"}},"credHelpers":{"broken.example.com":"broken"}}`, u.Host)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.helper = func(ctx context.Context, name, server string) (Credentials, error) {
+		return Credentials{}, errors.New("helper failed")
+	}
+
+	var failed []error
+	SetCredentialProvider(d, func(err error) { failed = append(failed, err) })
+	defer SetCredentialProvider(nil, nil)
+
+	tags := func(reg registryfrontend.Registry, listed bool) func(*testing.T) {
+		return func(t *testing.T) {
+			c, err := New(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tags, err := c.Tags(context.Background(), "acme/app")
+			if listed != (err == nil && len(tags) == 1) {
+				t.Errorf("expected the tags to be listed: %v, got %v %+v", listed, tags, err)
+			}
+		}
+	}
+
+	t.Run("opted in", tags(registryfrontend.Registry{Name: "hosted", Url: srv.URL, DockerConfig: true}, true))
+	t.Run("not opted in", tags(registryfrontend.Registry{Name: "hosted", Url: srv.URL}, false))
+
+	if _, err := New(registryfrontend.Registry{Name: "broken", Url: "https://broken.example.com", DockerConfig: true}); err != nil {
+		t.Errorf("expected an anonymous client when the helper fails, got %v", err)
+	}
+	if len(failed) != 1 {
+		t.Errorf("expected the failed lookup to be reported, got %v", failed)
+	}
+}
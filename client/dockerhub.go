@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// dockerHubRepositories lists the repositories of a Docker Hub user or organization.
+// Private repositories are listed when the registry has credentials, which are used to log in to Docker Hub.
+// The token of the login is reused by later listings until Docker Hub rejects it.
+func dockerHubRepositories(ctx context.Context, api *apiClient, r registryfrontend.Registry) ([]string, error) {
+	ns, err := namespace(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	login := r.User != "" && r.Password != ""
+	loggedIn := false
+
+	if login && !api.hasHeader("Authorization") {
+		if err := dockerHubLogin(ctx, api, r); err != nil {
+			return nil, err
+		}
+		loggedIn = true
+	}
+
+	repositories, err := listDockerHubRepositories(ctx, api, ns)
+
+	// The token of an earlier login may have expired.
+	if login && !loggedIn && StatusCode(err) == http.StatusUnauthorized {
+		if err := dockerHubLogin(ctx, api, r); err != nil {
+			return nil, err
+		}
+
+		repositories, err = listDockerHubRepositories(ctx, api, ns)
+	}
+
+	return repositories, err
+}
+
+// dockerHubLogin logs in to Docker Hub, authorizing the later API requests with the token of the login.
+func dockerHubLogin(ctx context.Context, api *apiClient, r registryfrontend.Registry) error {
+	login := struct {
+		Token string `json:"token"`
+	}{}
+
+	if _, err := api.do(ctx, http.MethodPost, "/v2/users/login", map[string]string{"username": r.User, "password": r.Password}, &login); err != nil {
+		return errors.Wrap(err, "failed logging in to Docker Hub")
+	}
+
+	api.setHeader("Authorization", "Bearer "+login.Token)
+
+	return nil
+}
+
+func listDockerHubRepositories(ctx context.Context, api *apiClient, ns string) ([]string, error) {
+	var repositories []string
+	next := fmt.Sprintf("/v2/repositories/%s/?page_size=100", url.PathEscape(ns))
+
+	for next != "" {
+		page := struct {
+			Next    string `json:"next"`
+			Results []struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"results"`
+		}{}
+
+		if _, err := api.get(ctx, next, &page); err != nil {
+			return nil, errors.Wrap(err, "failed listing Docker Hub repositories")
+		}
+
+		for _, res := range page.Results {
+			if res.Namespace == "" {
+				res.Namespace = ns
+			}
+			repositories = append(repositories, res.Namespace+"/"+res.Name)
+		}
+
+		next = page.Next
+	}
+
+	return sortedRepositories(repositories), nil
+}
@@ -0,0 +1,40 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// StatusError is returned when the registry responds with an unexpected status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code %d", e.StatusCode)
+}
+
+func statusError(resp *http.Response) error {
+	return errors.WithStack(&StatusError{StatusCode: resp.StatusCode})
+}
+
+// StatusCode returns the status code of the StatusError err was caused by, or 0 if it was caused by something else.
+func StatusCode(err error) int {
+	if se, ok := errors.Cause(err).(*StatusError); ok {
+		return se.StatusCode
+	}
+	return 0
+}
+
+// DigestError is returned when content does not match the digest it was requested by, or reported with by the registry.
+type DigestError struct {
+	Expected digest.Digest
+	Actual   digest.Digest
+}
+
+func (e *DigestError) Error() string {
+	return fmt.Sprintf("content has digest %s, expected %s", e.Actual, e.Expected)
+}
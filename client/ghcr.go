@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// ghcrPageSize is the number of packages requested per page, the maximum of the GitHub API.
+const ghcrPageSize = 100
+
+// ghcrRepositories lists the container packages of a GitHub organization, or of a user if there is no such organization.
+// The password of the registry is used as token for the GitHub API, and must be allowed to read packages.
+func ghcrRepositories(ctx context.Context, api *apiClient, r registryfrontend.Registry) ([]string, error) {
+	ns, err := namespace(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Password != "" {
+		api.setHeader("Authorization", "Bearer "+r.Password)
+	}
+
+	var repositories []string
+	owner := "orgs"
+
+	for page := 1; ; page++ {
+		var packages []struct {
+			Name string `json:"name"`
+		}
+
+		_, err := api.get(ctx, fmt.Sprintf("/%s/%s/packages?package_type=container&per_page=%d&page=%d", owner, url.PathEscape(ns), ghcrPageSize, page), &packages)
+
+		if page == 1 && owner == "orgs" && StatusCode(err) == http.StatusNotFound {
+			owner, page = "users", 0
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed listing GitHub packages")
+		}
+
+		for _, p := range packages {
+			repositories = append(repositories, strings.ToLower(ns)+"/"+p.Name)
+		}
+
+		if len(packages) < ghcrPageSize {
+			return sortedRepositories(repositories), nil
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// gitLabRepositories lists the container repositories of a GitLab group and its projects,
+// or of a project if there is no such group. The namespace is the full path, e.g. group/subgroup.
+// The password of the registry is used as personal access token for the GitLab API.
+func gitLabRepositories(ctx context.Context, api *apiClient, r registryfrontend.Registry) ([]string, error) {
+	ns, err := namespace(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Password != "" {
+		api.setHeader("PRIVATE-TOKEN", r.Password)
+	}
+
+	var repositories []string
+	owner := "groups"
+
+	for page := "1"; page != ""; {
+		var repos []struct {
+			Path string `json:"path"`
+		}
+
+		header, err := api.get(ctx, fmt.Sprintf("/api/v4/%s/%s/registry/repositories?per_page=100&page=%s", owner, url.PathEscape(ns), page), &repos)
+
+		if page == "1" && owner == "groups" && StatusCode(err) == http.StatusNotFound {
+			owner = "projects"
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed listing GitLab container repositories")
+		}
+
+		for _, repo := range repos {
+			repositories = append(repositories, repo.Path)
+		}
+
+		page = header.Get("X-Next-Page")
+	}
+
+	return sortedRepositories(repositories), nil
+}
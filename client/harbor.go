@@ -0,0 +1,260 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+const (
+	harborAPI      = "/api/v2.0"
+	harborPageSize = 100
+	// harborReportType is the mime type of the vulnerability reports in the scan overview of artifacts.
+	harborReportType = "application/vnd.security.vulnerability.report; version=1.1"
+)
+
+// HarborClient uses the Harbor API to list repositories, which does not require admin rights unlike the catalog,
+// and to describe projects, repositories and artifacts. Everything else uses the distribution API.
+type HarborClient struct {
+	*V2Client
+	api *apiClient
+	// repositories is the allowlist of the registry, listed instead of the repositories of the Harbor API.
+	repositories []string
+}
+
+var (
+	_ registryfrontend.ProjectLister          = &HarborClient{}
+	_ registryfrontend.RepositoryDescriber    = &HarborClient{}
+	_ registryfrontend.ArtifactStatusProvider = &HarborClient{}
+)
+
+func newHarborClient(v *V2Client, r registryfrontend.Registry, k kind) (*HarborClient, error) {
+	api, err := newAPIClient(r, k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if r.User != "" || r.Password != "" {
+		api.basicAuth(r.User, r.Password)
+	}
+
+	h := &HarborClient{V2Client: v, api: api, repositories: append([]string(nil), r.Repositories...)}
+	sort.Strings(h.repositories)
+
+	return h, nil
+}
+
+func (h *HarborClient) Repositories(ctx context.Context) ([]string, error) {
+	if len(h.repositories) > 0 {
+		return h.repositories, nil
+	}
+
+	var repositories []string
+
+	err := h.pages(ctx, "/repositories", func(get func(v interface{}) error) (int, error) {
+		var page []struct {
+			Name string `json:"name"`
+		}
+
+		if err := get(&page); err != nil {
+			return 0, err
+		}
+
+		for _, repo := range page {
+			repositories = append(repositories, repo.Name)
+		}
+
+		return len(page), nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed listing Harbor repositories")
+	}
+
+	return sortedRepositories(repositories), nil
+}
+
+func (h *HarborClient) RepositoriesN(ctx context.Context, n int, last string) ([]string, error) {
+	repositories, err := h.Repositories(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pageRepositories(repositories, n, last), nil
+}
+
+// Projects lists the projects the user can see, with their storage quota and usage.
+func (h *HarborClient) Projects(ctx context.Context) ([]registryfrontend.Project, error) {
+	var projects []registryfrontend.Project
+	ids := make(map[int]int)
+
+	err := h.pages(ctx, "/projects", func(get func(v interface{}) error) (int, error) {
+		var page []struct {
+			ID        int               `json:"project_id"`
+			Name      string            `json:"name"`
+			RepoCount int               `json:"repo_count"`
+			Metadata  map[string]string `json:"metadata"`
+		}
+
+		if err := get(&page); err != nil {
+			return 0, err
+		}
+
+		for _, p := range page {
+			ids[p.ID] = len(projects)
+			projects = append(projects, registryfrontend.Project{
+				Name:         p.Name,
+				Public:       p.Metadata["public"] == "true",
+				Repositories: p.RepoCount,
+				Quota:        -1,
+			})
+		}
+
+		return len(page), nil
+	})
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed listing Harbor projects")
+	}
+
+	// Quotas can only be listed by admins, so projects are listed without them otherwise.
+	err = h.pages(ctx, "/quotas?reference=project", func(get func(v interface{}) error) (int, error) {
+		var page []struct {
+			Ref struct {
+				ID int `json:"id"`
+			} `json:"ref"`
+			Hard map[string]int64 `json:"hard"`
+			Used map[string]int64 `json:"used"`
+		}
+
+		if err := get(&page); err != nil {
+			return 0, err
+		}
+
+		for _, q := range page {
+			if i, ok := ids[q.Ref.ID]; ok {
+				projects[i].Quota, projects[i].Used = q.Hard["storage"], q.Used["storage"]
+			}
+		}
+
+		return len(page), nil
+	})
+
+	if err != nil && StatusCode(err) != http.StatusUnauthorized && StatusCode(err) != http.StatusForbidden {
+		return nil, errors.Wrap(err, "failed listing Harbor quotas")
+	}
+
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].Name < projects[j].Name
+	})
+
+	return projects, nil
+}
+
+// RepositoryInfo returns the description, pull count and number of artifacts of a repository.
+func (h *HarborClient) RepositoryInfo(ctx context.Context, repository string) (*registryfrontend.RepositoryInfo, error) {
+	dto := struct {
+		Description   string    `json:"description"`
+		PullCount     int64     `json:"pull_count"`
+		ArtifactCount int       `json:"artifact_count"`
+		UpdateTime    time.Time `json:"update_time"`
+	}{}
+
+	if _, err := h.api.get(ctx, harborAPI+harborRepositoryPath(repository), &dto); err != nil {
+		return nil, errors.Wrap(err, "failed fetching Harbor repository")
+	}
+
+	return &registryfrontend.RepositoryInfo{
+		Description: dto.Description,
+		PullCount:   dto.PullCount,
+		Artifacts:   dto.ArtifactCount,
+		Updated:     dto.UpdateTime,
+	}, nil
+}
+
+// ArtifactStatus returns when an artifact was pushed and last pulled, and the result of its latest vulnerability scan.
+func (h *HarborClient) ArtifactStatus(ctx context.Context, repository, reference string) (*registryfrontend.ArtifactStatus, error) {
+	type report struct {
+		ScanStatus string `json:"scan_status"`
+		Severity   string `json:"severity"`
+		Summary    struct {
+			Total   int            `json:"total"`
+			Fixable int            `json:"fixable"`
+			Summary map[string]int `json:"summary"`
+		} `json:"summary"`
+	}
+
+	dto := struct {
+		PushTime     time.Time         `json:"push_time"`
+		PullTime     time.Time         `json:"pull_time"`
+		ScanOverview map[string]report `json:"scan_overview"`
+	}{}
+
+	path := fmt.Sprintf("%s%s/artifacts/%s?with_scan_overview=true", harborAPI, harborRepositoryPath(repository), url.PathEscape(reference))
+
+	if _, err := h.api.get(ctx, path, &dto); err != nil {
+		return nil, errors.Wrap(err, "failed fetching Harbor artifact")
+	}
+
+	// Harbor reports a pull time of 0001-01-01 for artifacts that were never pulled, which is the zero time.
+	status := &registryfrontend.ArtifactStatus{Pushed: dto.PushTime, Pulled: dto.PullTime}
+
+	if r, ok := dto.ScanOverview[harborReportType]; ok {
+		status.ScanStatus = r.ScanStatus
+		status.Severity = r.Severity
+		status.Vulnerabilities = registryfrontend.VulnerabilityCounts{
+			Total:    r.Summary.Total,
+			Fixable:  r.Summary.Fixable,
+			Critical: r.Summary.Summary["Critical"],
+			High:     r.Summary.Summary["High"],
+			Medium:   r.Summary.Summary["Medium"],
+			Low:      r.Summary.Summary["Low"],
+		}
+	}
+
+	return status, nil
+}
+
+// pages calls the paginated endpoint at path until a page is not full. read decodes a page, and returns its length.
+func (h *HarborClient) pages(ctx context.Context, path string, read func(get func(v interface{}) error) (int, error)) error {
+	sep := "?"
+
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+
+	for page := 1; ; page++ {
+		n, err := read(func(v interface{}) error {
+			_, err := h.api.get(ctx, fmt.Sprintf("%s%s%spage=%d&page_size=%d", harborAPI, path, sep, page, harborPageSize), v)
+			return err
+		})
+
+		if err != nil {
+			return err
+		} else if n < harborPageSize {
+			return nil
+		}
+	}
+}
+
+// harborRepositoryPath is the API path of a repository, e.g. /projects/library/repositories/team%252Fapp
+// for library/team/app. Harbor requires the slashes of the repository name within the project to be escaped twice.
+func harborRepositoryPath(repository string) string {
+	parts := strings.SplitN(repository, "/", 2)
+	name := ""
+
+	if len(parts) == 2 {
+		name = parts[1]
+	}
+
+	return fmt.Sprintf("/projects/%s/repositories/%s", url.PathEscape(parts[0]), url.PathEscape(url.PathEscape(name)))
+}
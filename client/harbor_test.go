@@ -0,0 +1,89 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+)
+
+// fakeHarbor emulates the Harbor API for the user admin with the password Harbor12345.
+func fakeHarbor() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if u, p, _ := r.BasicAuth(); u != "admin" || p != "Harbor12345" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/api/v2.0/repositories":
+			fmt.Fprint(w, `[{"name":"library/team/app","project_id":1},{"name":"library/db","project_id":1},{"name":"ci/runner","project_id":2}]`)
+		case "/api/v2.0/projects":
+			fmt.Fprint(w, `[{"project_id":2,"name":"ci","repo_count":1,"metadata":{"public":"false"}},{"project_id":1,"name":"library","repo_count":2,"metadata":{"public":"true"}}]`)
+		case "/api/v2.0/quotas":
+			fmt.Fprint(w, `[{"ref":{"id":1,"name":"library"},"hard":{"storage":1073741824},"used":{"storage":536870912}},{"ref":{"id":2,"name":"ci"},"hard":{"storage":-1},"used":{"storage":100}}]`)
+		case "/api/v2.0/projects/library/repositories/team%252Fapp":
+			fmt.Fprint(w, `{"name":"library/team/app","description":"The app","pull_count":42,"artifact_count":3,"update_time":"2023-05-01T10:00:00Z"}`)
+		case "/api/v2.0/projects/library/repositories/team%252Fapp/artifacts/1.0":
+			fmt.Fprint(w, `{"push_time":"2023-05-01T10:00:00Z","pull_time":"0001-01-01T00:00:00.000Z","scan_overview":{
+				"application/vnd.security.vulnerability.report; version=1.1":{"scan_status":"Success","severity":"High",
+				"summary":{"total":5,"fixable":4,"summary":{"High":2,"Medium":3}}}}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestHarbor(t *testing.T) {
+	srv := fakeHarbor()
+	defer srv.Close()
+
+	c, err := New(registryfrontend.Registry{Name: "harbor", Url: srv.URL, Kind: "harbor", User: "admin", Password: "Harbor12345"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	t.Run("repositories", func(t *testing.T) {
+		repositories, err := c.Repositories(ctx)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if strings.Join(repositories, ",") != "ci/runner,library/db,library/team/app" {
+			t.Errorf("unexpected repositories %v", repositories)
+		}
+	})
+	t.Run("projects", func(t *testing.T) {
+		projects, err := c.(registryfrontend.ProjectLister).Projects(ctx)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		expected := "[{ci false 1 -1 100} {library true 2 1073741824 536870912}]"
+		if fmt.Sprint(projects) != expected {
+			t.Errorf("expected %s, got %v", expected, projects)
+		}
+	})
+	t.Run("repository", func(t *testing.T) {
+		info, err := c.(registryfrontend.RepositoryDescriber).RepositoryInfo(ctx, "library/team/app")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if info.Description != "The app" || info.PullCount != 42 || info.Artifacts != 3 {
+			t.Errorf("unexpected repository %+v", info)
+		}
+	})
+	t.Run("artifact", func(t *testing.T) {
+		st, err := c.(registryfrontend.ArtifactStatusProvider).ArtifactStatus(ctx, "library/team/app", "1.0")
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		if !st.Pulled.IsZero() || st.ScanStatus != "Success" || st.Vulnerabilities != (registryfrontend.VulnerabilityCounts{Total: 5, Fixable: 4, High: 2, Medium: 3}) {
+			t.Errorf("unexpected status %+v", st)
+		}
+	})
+}
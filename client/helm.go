@@ -0,0 +1,126 @@
+package client
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HelmConfigMediaType is the config media type of Helm charts, containing the metadata of Chart.yaml as JSON.
+	HelmConfigMediaType = "application/vnd.cncf.helm.config.v1+json"
+	// HelmChartMediaType is the media type of the layer containing the chart archive.
+	HelmChartMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+
+	maxChartConfigSize  = 1 << 20
+	maxChartArchiveSize = 16 << 20
+	// maxChartFileSize limits the size of the files read from a chart archive.
+	maxChartFileSize = 1 << 20
+)
+
+// ErrNotChart is returned by ChartFiles for artifacts that are not Helm charts.
+var ErrNotChart = errors.New("the artifact is not a Helm chart")
+
+// ChartFiles are the files of a Helm chart that describe how to use it. Files missing from the chart are empty.
+type ChartFiles struct {
+	Readme string
+	Values string
+}
+
+func readChart(ctx context.Context, c registryfrontend.Client, repository string, config registryfrontend.Descriptor) (*registryfrontend.Chart, error) {
+	content, err := ReadBlob(ctx, c, repository, config, maxChartConfigSize)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed reading chart metadata")
+	}
+
+	chart := &registryfrontend.Chart{}
+
+	if err := json.Unmarshal(content, chart); err != nil {
+		return nil, errors.Wrap(err, "could not parse chart metadata")
+	}
+
+	return chart, nil
+}
+
+// ReadChartFiles reads the README and values.yaml of the chart in the layers of a Helm chart artifact.
+// Files of subcharts are left out.
+func ReadChartFiles(ctx context.Context, c registryfrontend.Client, repository string, a *registryfrontend.ArtifactInfo) (*ChartFiles, error) {
+	if a.Chart == nil {
+		return nil, ErrNotChart
+	}
+
+	for _, l := range a.Layers {
+		if l.MediaType != HelmChartMediaType {
+			continue
+		}
+
+		content, err := ReadBlob(ctx, c, repository, l, maxChartArchiveSize)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading chart archive")
+		}
+
+		return chartFiles(content)
+	}
+
+	return nil, errors.New("the chart has no chart archive")
+}
+
+// chartFiles reads the files at the top level of the chart directory, e.g. app/values.yaml, from a chart archive.
+func chartFiles(archive []byte) (*ChartFiles, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read chart archive")
+	}
+	defer gz.Close()
+
+	files := &ChartFiles{}
+	r := tar.NewReader(gz)
+
+	for {
+		h, err := r.Next()
+
+		if err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return nil, errors.Wrap(err, "could not read chart archive")
+		}
+
+		parts := strings.Split(strings.TrimPrefix(h.Name, "./"), "/")
+
+		if h.Typeflag != tar.TypeReg || len(parts) != 2 {
+			continue
+		}
+
+		var file *string
+
+		switch name := parts[1]; {
+		case name == "values.yaml":
+			file = &files.Values
+		case strings.HasPrefix(strings.ToLower(name), "readme") && files.Readme == "":
+			file = &files.Readme
+		default:
+			continue
+		}
+
+		content, err := ioutil.ReadAll(io.LimitReader(r, maxChartFileSize+1))
+
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read chart archive")
+		} else if len(content) > maxChartFileSize {
+			return nil, errors.Errorf("%s is too large", h.Name)
+		}
+
+		*file = string(content)
+	}
+}
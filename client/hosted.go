@@ -0,0 +1,257 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// kind describes a kind of registry, and how its repositories are listed if it disables the catalog.
+type kind struct {
+	name string
+	// url and api are the default URLs of the registry and of the API of the provider.
+	url string
+	api string
+	// list lists the repositories of the namespace of the registry, or is nil if the catalog is used.
+	list func(ctx context.Context, api *apiClient, r registryfrontend.Registry) ([]string, error)
+}
+
+var kinds = map[string]kind{
+	"":          {name: "Distribution"},
+	"dockerhub": {name: "Docker Hub", url: "https://registry-1.docker.io", api: "https://hub.docker.com", list: dockerHubRepositories},
+	"ghcr":      {name: "GitHub Container Registry", url: "https://ghcr.io", api: "https://api.github.com", list: ghcrRepositories},
+	"quay":      {name: "Quay", url: "https://quay.io", api: "https://quay.io", list: quayRepositories},
+	"gitlab":    {name: "GitLab", url: "https://registry.gitlab.com", api: "https://gitlab.com", list: gitLabRepositories},
+	// Harbor is self-hosted, and serves its API next to the registry.
+	"harbor": {name: "Harbor"},
+}
+
+// Kinds lists the kinds of registries, starting with the self-hosted distribution registry, which is the empty kind.
+func Kinds() []string {
+	res := make([]string, 0, len(kinds))
+
+	for k := range kinds {
+		res = append(res, k)
+	}
+
+	sort.Strings(res)
+	return res
+}
+
+// KindName names a kind of registry, e.g. Docker Hub.
+func KindName(k string) string {
+	if kind, ok := kinds[k]; ok {
+		return kind.name
+	}
+	return k
+}
+
+// DefaultURL returns the URL of the registry of a hosted kind, e.g. https://ghcr.io, or empty for other kinds.
+func DefaultURL(k string) string {
+	return kinds[k].url
+}
+
+// ValidateKind returns an error if the kind is unknown.
+func ValidateKind(k string) error {
+	if _, ok := kinds[k]; !ok {
+		return errors.Errorf("unknown kind %q, must be one of %s", k, strings.Join(Kinds()[1:], ", "))
+	}
+	return nil
+}
+
+// hostedClient lists the repositories of a registry that disables the catalog, e.g. through the API of its provider.
+type hostedClient struct {
+	*V2Client
+	list func(ctx context.Context) ([]string, error)
+}
+
+func (h *hostedClient) Repositories(ctx context.Context) ([]string, error) {
+	return h.list(ctx)
+}
+
+func (h *hostedClient) RepositoriesN(ctx context.Context, n int, last string) ([]string, error) {
+	repositories, err := h.list(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pageRepositories(repositories, n, last), nil
+}
+
+// pageRepositories pages through sorted repositories like the catalog does, returning up to n repositories after last.
+func pageRepositories(repositories []string, n int, last string) []string {
+	if n <= 0 {
+		return repositories
+	}
+
+	i := sort.SearchStrings(repositories, last)
+
+	if i < len(repositories) && repositories[i] == last {
+		i++
+	}
+
+	repositories = repositories[i:]
+
+	if len(repositories) > n {
+		repositories = repositories[:n]
+	}
+
+	return repositories
+}
+
+// apiClient calls the REST API of the provider of a hosted registry.
+// It is shared by the requests using a cached client, so the header is guarded by mu.
+type apiClient struct {
+	base string
+	c    http.Client
+
+	mu     sync.Mutex
+	header http.Header
+}
+
+func newAPIClient(r registryfrontend.Registry, k kind) (*apiClient, error) {
+	base := r.API
+
+	if base == "" {
+		base = k.api
+	}
+
+	if base == "" {
+		base = r.Url
+	}
+
+	if _, err := url.Parse(base); err != nil {
+		return nil, errors.Wrapf(err, "registry %s has an invalid API URL", r.Name)
+	}
+
+	t, err := newTransport(r.TLS)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "registry %s", r.Name)
+	}
+
+	return &apiClient{base: strings.TrimSuffix(base, "/"), c: http.Client{Transport: t, Timeout: r.Timeout}, header: make(http.Header)}, nil
+}
+
+// do sends a request to the path, which may also be an absolute URL, e.g. of the next page, and decodes the JSON response into v.
+// The credentials are only sent to absolute URLs with the scheme and host of the API.
+func (a *apiClient) do(ctx context.Context, method, path string, body interface{}, v interface{}) (http.Header, error) {
+	u := path
+
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		u = a.base + path
+	}
+
+	var content io.Reader
+
+	if body != nil {
+		b, err := json.Marshal(body)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed encoding API request")
+		}
+
+		content = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, content)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create API request")
+	}
+
+	if a.sameOrigin(req.URL) {
+		a.mu.Lock()
+		for k, vs := range a.header {
+			req.Header[k] = vs
+		}
+		a.mu.Unlock()
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := a.c.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed calling API")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp.Header, statusError(resp)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, errors.Wrap(err, "could not parse API response")
+	}
+
+	return resp.Header, nil
+}
+
+// sameOrigin reports whether u has the scheme and host of the API.
+func (a *apiClient) sameOrigin(u *url.URL) bool {
+	base, err := url.Parse(a.base)
+
+	return err == nil && strings.EqualFold(base.Scheme, u.Scheme) && strings.EqualFold(base.Host, u.Host)
+}
+
+// setHeader sets a header sent with every API request, e.g. to authorize them.
+func (a *apiClient) setHeader(key, value string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.header.Set(key, value)
+}
+
+// hasHeader reports whether a header is sent with every API request.
+func (a *apiClient) hasHeader(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return a.header.Get(key) != ""
+}
+
+// basicAuth authorizes the API requests with the user and password.
+func (a *apiClient) basicAuth(user, password string) {
+	a.setHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)))
+}
+
+func (a *apiClient) get(ctx context.Context, path string, v interface{}) (http.Header, error) {
+	return a.do(ctx, http.MethodGet, path, nil, v)
+}
+
+// namespace returns the namespace whose repositories are listed, which defaults to the user.
+func namespace(r registryfrontend.Registry) (string, error) {
+	ns := r.Namespace
+
+	if ns == "" {
+		ns = r.User
+	}
+
+	if ns == "" {
+		return "", errors.Errorf("registry %s needs a namespace or a list of repositories, as %s registries do not list all repositories", r.Name, r.Kind)
+	}
+
+	return ns, nil
+}
+
+// sortedRepositories sorts the repositories, as the catalog does.
+func sortedRepositories(repositories []string) []string {
+	sort.Strings(repositories)
+	return repositories
+}
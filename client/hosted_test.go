@@ -0,0 +1,254 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+)
+
+// fakeProvider emulates the registry and API of a hosted provider. The registry uses token authentication,
+// handing out tokens for the password s3cret, or for the identity token refr3sh.
+func fakeProvider(t *testing.T, api map[string]func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token" && r.Method == http.MethodPost:
+			if r.PostFormValue("grant_type") != "refresh_token" || r.PostFormValue("refresh_token") != "refr3sh" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"t0k3n","expires_in":300}`)
+		case r.URL.Path == "/token":
+			if _, p, _ := r.BasicAuth(); p != "s3cret" || r.URL.Query().Get("scope") != "repository:acme/app:pull" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"token":"t0k3n","expires_in":300}`)
+		case strings.HasPrefix(r.URL.Path, "/v2/acme/app/"):
+			if r.Header.Get("Authorization") != "Bearer t0k3n" {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:acme/app:pull"`, srv.URL))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if api[r.URL.Path] != nil {
+				api[r.URL.Path](w, r)
+				return
+			}
+			fmt.Fprint(w, `{"name":"acme/app","tags":["1.0"]}`)
+		case r.URL.Path == "/v2/_catalog":
+			w.WriteHeader(http.StatusUnauthorized)
+		case api[r.URL.Path] != nil:
+			api[r.URL.Path](w, r)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	return srv
+}
+
+func TestHosted(t *testing.T) {
+	list := func(reg registryfrontend.Registry, api map[string]func(w http.ResponseWriter, r *http.Request), expected string) func(*testing.T) {
+		return func(t *testing.T) {
+			srv := fakeProvider(t, api)
+			defer srv.Close()
+
+			reg.Name, reg.Url, reg.API = "hosted", srv.URL, srv.URL
+			c, err := New(reg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			repositories, err := c.Repositories(context.Background())
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if strings.Join(repositories, ",") != expected {
+				t.Errorf("expected repositories %s, got %v", expected, repositories)
+			}
+
+			page, err := c.RepositoriesN(context.Background(), 1, repositories[0])
+			if err != nil || len(page) != 1 || page[0] != repositories[1] {
+				t.Errorf("expected the page after %s to be %s, got %v %v", repositories[0], repositories[1], page, err)
+			}
+
+			tags, err := c.Tags(context.Background(), "acme/app")
+			if err != nil || len(tags) != 1 {
+				t.Errorf("expected the tags to be listed with a token, got %v %+v", tags, err)
+			}
+		}
+	}
+
+	t.Run("docker hub", list(registryfrontend.Registry{Kind: "dockerhub", User: "ci", Password: "s3cret", Namespace: "acme"}, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/v2/users/login": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"token":"jwt"}`)
+		},
+		"/v2/repositories/acme/": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer jwt" {
+				w.WriteHeader(http.StatusUnauthorized)
+			} else if r.URL.Query().Get("page") == "" {
+				fmt.Fprintf(w, `{"next":"http://%s/v2/repositories/acme/?page=2","results":[{"name":"worker","namespace":"acme"}]}`, r.Host)
+			} else {
+				fmt.Fprint(w, `{"next":null,"results":[{"name":"app","namespace":"acme"}]}`)
+			}
+		},
+	}, "acme/app,acme/worker"))
+
+	t.Run("ghcr", list(registryfrontend.Registry{Kind: "ghcr", User: "ci", Password: "s3cret", Namespace: "Acme"}, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/users/Acme/packages": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer s3cret" || r.URL.Query().Get("package_type") != "container" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `[{"name":"app","package_type":"container"},{"name":"tools/lint","package_type":"container"}]`)
+		},
+	}, "acme/app,acme/tools/lint"))
+
+	t.Run("quay", list(registryfrontend.Registry{Kind: "quay", User: "acme+ci", Password: "s3cret"}, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/api/v1/repository": func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("namespace") != "acme" || r.URL.Query().Get("public") != "true" {
+				w.WriteHeader(http.StatusBadRequest)
+			} else if r.URL.Query().Get("next_page") == "" {
+				fmt.Fprint(w, `{"repositories":[{"namespace":"acme","name":"worker"}],"next_page":"abc"}`)
+			} else {
+				fmt.Fprint(w, `{"repositories":[{"namespace":"acme","name":"app"}]}`)
+			}
+		},
+	}, "acme/app,acme/worker"))
+
+	t.Run("gitlab", list(registryfrontend.Registry{Kind: "gitlab", User: "ci", Password: "s3cret", Namespace: "acme/backend"}, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/api/v4/groups/acme/backend/registry/repositories": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("PRIVATE-TOKEN") != "s3cret" {
+				w.WriteHeader(http.StatusUnauthorized)
+			} else if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"id":1,"name":"","path":"acme/backend/api"}]`)
+			} else {
+				fmt.Fprint(w, `[{"id":2,"name":"worker","path":"acme/backend/api/worker"}]`)
+			}
+		},
+	}, "acme/backend/api,acme/backend/api/worker"))
+
+	t.Run("allowlist", list(registryfrontend.Registry{User: "ci", Password: "s3cret", Repositories: []string{"acme/worker", "acme/app"}}, nil, "acme/app,acme/worker"))
+
+	t.Run("missing namespace", func(t *testing.T) {
+		c, err := New(registryfrontend.Registry{Name: "hub", Url: "https://registry-1.docker.io", Kind: "dockerhub"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.Repositories(context.Background()); err == nil {
+			t.Error("expected an error without a namespace")
+		}
+	})
+}
+
+func TestHostedBlobRedirect(t *testing.T) {
+	f := newFakeRegistry()
+	d := f.putBlob("acme/app", "layer")
+
+	// Like signed CDN or S3 URLs, the CDN rejects requests carrying the credentials of the registry.
+	cdn := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.ServeHTTP(w, r)
+	}))
+	defer cdn.Close()
+
+	srv := fakeProvider(t, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/v2/acme/app/blobs/" + d.String(): redirectBlobs(cdn.URL, nil).ServeHTTP,
+	})
+	defer srv.Close()
+
+	for _, kind := range []string{"dockerhub", "ghcr", "quay", "gitlab"} {
+		c, err := New(registryfrontend.Registry{Name: kind, Url: srv.URL, API: srv.URL, Kind: kind, User: "ci", Password: "s3cret", Namespace: "acme"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Run(kind, blobContent(c, "acme/app", d, "layer"))
+	}
+}
+
+func TestAPIClientCredentials(t *testing.T) {
+	var auth string
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{}`)
+	})
+	api := httptest.NewServer(handler)
+	defer api.Close()
+	other := httptest.NewServer(handler)
+	defer other.Close()
+
+	a, err := newAPIClient(registryfrontend.Registry{Name: "hosted", Url: api.URL}, kinds[""])
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.basicAuth("ci", "s3cret")
+
+	var v struct{}
+	for _, u := range []string{"/repositories", api.URL + "/repositories?page=2"} {
+		if _, err := a.get(context.Background(), u, &v); err != nil {
+			t.Fatal(err)
+		}
+		if auth == "" {
+			t.Errorf("expected credentials to be sent to %s", u)
+		}
+	}
+
+	if _, err := a.get(context.Background(), other.URL+"/repositories?page=3", &v); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "" {
+		t.Error("expected no credentials to be sent to another host")
+	}
+}
+
+func TestDockerHubLogin(t *testing.T) {
+	logins, token := 0, "jwt"
+
+	srv := fakeProvider(t, map[string]func(w http.ResponseWriter, r *http.Request){
+		"/v2/users/login": func(w http.ResponseWriter, r *http.Request) {
+			logins++
+			fmt.Fprintf(w, `{"token":%q}`, token)
+		},
+		"/v2/repositories/acme/": func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer "+token {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"next":null,"results":[{"name":"app","namespace":"acme"}]}`)
+		},
+	})
+	defer srv.Close()
+
+	c, err := New(registryfrontend.Registry{Name: "hub", Url: srv.URL, API: srv.URL, Kind: "dockerhub", User: "ci", Password: "s3cret", Namespace: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Repositories(context.Background()); err != nil {
+			t.Fatalf("%+v", err)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("expected the login to be reused, got %d logins", logins)
+	}
+
+	token = "renewed"
+	if _, err := c.Repositories(context.Background()); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if logins != 2 {
+		t.Errorf("expected to log in again when the token is rejected, got %d logins", logins)
+	}
+}
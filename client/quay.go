@@ -0,0 +1,59 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
+)
+
+// quayRepositories lists the repositories of a Quay organization or user.
+// Robot accounts cannot use the Quay API, so private repositories are only listed when logging in with an OAuth token,
+// i.e. with the user $oauthtoken. The namespace defaults to the organization of a robot account, e.g. org of org+robot.
+func quayRepositories(ctx context.Context, api *apiClient, r registryfrontend.Registry) ([]string, error) {
+	if r.Namespace == "" {
+		r.Namespace = strings.SplitN(r.User, "+", 2)[0]
+	}
+
+	ns, err := namespace(r)
+
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{"namespace": {ns}}
+
+	if r.User == "$oauthtoken" {
+		api.setHeader("Authorization", "Bearer "+r.Password)
+	} else {
+		q.Set("public", "true")
+	}
+
+	var repositories []string
+
+	for {
+		page := struct {
+			Repositories []struct {
+				Namespace string `json:"namespace"`
+				Name      string `json:"name"`
+			} `json:"repositories"`
+			NextPage string `json:"next_page"`
+		}{}
+
+		if _, err := api.get(ctx, "/api/v1/repository?"+q.Encode(), &page); err != nil {
+			return nil, errors.Wrap(err, "failed listing Quay repositories")
+		}
+
+		for _, repo := range page.Repositories {
+			repositories = append(repositories, repo.Namespace+"/"+repo.Name)
+		}
+
+		if page.NextPage == "" {
+			return sortedRepositories(repositories), nil
+		}
+
+		q.Set("next_page", page.NextPage)
+	}
+}
@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// challengeParam matches the parameters of a WWW-Authenticate challenge, e.g. realm="https://auth.docker.io/token".
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// repositoryPath matches the paths of requests for the content of a repository.
+var repositoryPath = regexp.MustCompile(`^/v2/(.+)/(manifests|blobs|tags|referrers)/`)
+
+// tokenRoundTripper implements the token authentication of the distribution spec, used by hosted registries:
+// when the registry responds 401 with a Bearer challenge, a token is fetched from the realm of the challenge,
+// with the credentials of the registry if any, and the request is retried with it.
+// Identity tokens are exchanged for tokens with the OAuth2 refresh token grant instead.
+// Tokens are reused for later requests for the same repository on the registry host until they expire,
+// but not for redirects to other hosts, e.g. blobs served from a CDN.
+type tokenRoundTripper struct {
+	host  string
+	creds Credentials
+	inner http.RoundTripper
+	// auth fetches the tokens, as the realm is usually on another host than the registry.
+	auth *http.Client
+
+	mu     sync.Mutex
+	tokens map[string]token
+}
+
+type token struct {
+	value   string
+	expires time.Time
+}
+
+func newTokenRoundTripper(host string, creds Credentials, inner http.RoundTripper, auth *http.Client) *tokenRoundTripper {
+	return &tokenRoundTripper{host: host, creds: creds, inner: inner, auth: auth, tokens: make(map[string]token)}
+}
+
+func (t *tokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := ""
+
+	if m := repositoryPath.FindStringSubmatch(req.URL.Path); m != nil {
+		key = m[1]
+	}
+
+	t.mu.Lock()
+	tok, ok := t.tokens[key]
+	t.mu.Unlock()
+
+	first := req
+
+	if ok && req.URL.Host == t.host && time.Now().Before(tok.expires) {
+		first = withBearer(req, tok.value)
+	}
+
+	resp, err := t.inner.RoundTrip(first)
+
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	params, ok := bearerChallenge(resp.Header.Get("WWW-Authenticate"))
+
+	// Requests that cannot be replayed, e.g. streamed uploads, fail with the 401.
+	if !ok || (req.Body != nil && req.GetBody == nil) {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+
+	tok, err = t.fetch(req.Context(), params)
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.tokens[key] = tok
+	t.mu.Unlock()
+
+	retry := withBearer(req, tok.value)
+
+	if req.GetBody != nil {
+		if retry.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	return t.inner.RoundTrip(retry)
+}
+
+// withBearer returns a copy of the request, authorized by the token.
+func withBearer(req *http.Request, value string) *http.Request {
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+value)
+	return r
+}
+
+// bearerChallenge parses the parameters of a Bearer challenge, e.g. realm, service and scope.
+func bearerChallenge(header string) (map[string]string, bool) {
+	if !strings.HasPrefix(strings.ToLower(header), "bearer ") {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+
+	for _, m := range challengeParam.FindAllStringSubmatch(header, -1) {
+		params[strings.ToLower(m[1])] = m[2]
+	}
+
+	return params, params["realm"] != ""
+}
+
+func (t *tokenRoundTripper) fetch(ctx context.Context, params map[string]string) (token, error) {
+	u, err := url.Parse(params["realm"])
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return token{}, errors.Errorf("invalid token realm %q", params["realm"])
+	}
+
+	req, err := t.tokenRequest(u, params)
+
+	if err != nil {
+		return token{}, errors.Wrap(err, "failed to create token request")
+	}
+
+	resp, err := t.auth.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return token{}, errors.Wrap(err, "failed fetching token")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return token{}, errors.Wrap(statusError(resp), "failed fetching token")
+	}
+
+	dto := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&dto); err != nil {
+		return token{}, errors.Wrap(err, "could not parse token response")
+	}
+
+	tok := token{value: dto.Token, expires: time.Now().Add(time.Minute)}
+
+	if tok.value == "" {
+		tok.value = dto.AccessToken
+	}
+
+	// The token is renewed a little before it expires, so it does not expire on the way to the registry.
+	if dto.ExpiresIn > 10 {
+		tok.expires = time.Now().Add(time.Duration(dto.ExpiresIn-10) * time.Second)
+	}
+
+	return tok, nil
+}
+
+// tokenRequest creates the request for a token from the realm u.
+// Identity tokens are posted as OAuth2 refresh tokens, while other credentials authenticate a GET request.
+func (t *tokenRoundTripper) tokenRequest(u *url.URL, params map[string]string) (*http.Request, error) {
+	if t.creds.IdentityToken != "" {
+		form := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {t.creds.IdentityToken},
+			"client_id":     {"registryfrontend"},
+			"service":       {params["service"]},
+			"scope":         {params["scope"]},
+		}
+
+		req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(form.Encode()))
+
+		if err != nil {
+			return nil, err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return req, nil
+	}
+
+	q := u.Query()
+
+	if s := params["service"]; s != "" {
+		q.Set("service", s)
+	}
+
+	// Scopes are separated by spaces when the request needs several.
+	for _, s := range strings.Fields(params["scope"]) {
+		q.Add("scope", s)
+	}
+
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.creds.User != "" || t.creds.Password != "" {
+		req.SetBasicAuth(t.creds.User, t.creds.Password)
+	}
+
+	return req, nil
+}
@@ -0,0 +1,322 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// schema1MediaType is accepted in addition to manifestMediaTypes when fetching manifests,
+// so images pushed by old clients can be copied as well.
+const schema1MediaType = "application/vnd.docker.distribution.manifest.v1+prettyjws"
+
+// Manifest fetches the manifest, and verifies it against the requested digest, or the digest reported by the registry.
+// A DigestError is returned if the manifest does not match.
+func (v *V2Client) Manifest(ctx context.Context, repository, reference string) (*registryfrontend.Manifest, error) {
+	u := fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)
+
+	req, err := v.newRequest(http.MethodGet, u, nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create registry request")
+	}
+
+	for _, mt := range append(manifestMediaTypes, schema1MediaType) {
+		req.Header.Add("Accept", mt)
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed fetching manifest")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	content, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read registry response")
+	}
+
+	d, err := verifyManifest(reference, resp.Header.Get("Docker-Content-Digest"), content)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "manifest %s", reference)
+	}
+
+	return &registryfrontend.Manifest{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    d,
+		Content:   content,
+	}, nil
+}
+
+func (v *V2Client) PutManifest(ctx context.Context, repository, reference string, m *registryfrontend.Manifest) error {
+	u := fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)
+
+	req, err := v.newRequest(http.MethodPut, u, bytes.NewReader(m.Content))
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create registry request")
+	}
+
+	req.Header.Set("Content-Type", m.MediaType)
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return errors.Wrap(err, "failed uploading manifest")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp)
+	}
+
+	return nil
+}
+
+func (v *V2Client) Blob(ctx context.Context, repository string, d digest.Digest) (io.ReadCloser, int64, error) {
+	u := fmt.Sprintf("/v2/%s/blobs/%s", repository, d.String())
+
+	req, err := v.newRequest(http.MethodGet, u, nil)
+
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to create registry request")
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed fetching blob")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, 0, statusError(resp)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// PutBlob uploads the blob in a single request, after starting an upload session.
+func (v *V2Client) PutBlob(ctx context.Context, repository string, d digest.Digest, size int64, content io.Reader) error {
+	u := fmt.Sprintf("/v2/%s/blobs/uploads/", repository)
+
+	req, err := v.newRequest(http.MethodPost, u, nil)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create registry request")
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return errors.Wrap(err, "failed starting blob upload")
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return statusError(resp)
+	}
+
+	loc, err := url.Parse(resp.Header.Get("Location"))
+
+	if err != nil {
+		return errors.Wrap(err, "registry returned an invalid upload location")
+	}
+
+	q := loc.Query()
+	q.Set("digest", d.String())
+	loc.RawQuery = q.Encode()
+
+	req, err = v.newRequest(http.MethodPut, loc.String(), content)
+
+	if err != nil {
+		return errors.Wrap(err, "failed to create registry request")
+	}
+
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	req = req.WithContext(ctx)
+	resp, err = v.c.Do(req)
+
+	if err != nil {
+		return errors.Wrap(err, "failed uploading blob")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return statusError(resp)
+	}
+
+	return nil
+}
+
+// Copy copies the manifest referenced by srcRef, and every manifest and blob it references, to dst.
+// If the manifest is an index, all of its platforms are copied.
+// Blobs that already exist in the destination repository are skipped, as are foreign layers,
+// which are downloaded from their own URLs. Manifests and blobs are verified against their digests.
+// Copy returns the digest of the copied manifest.
+func Copy(ctx context.Context, src registryfrontend.Client, srcRepo, srcRef string, dst registryfrontend.Client, dstRepo, dstRef string) (digest.Digest, error) {
+	return copyManifest(ctx, src, srcRepo, srcRef, dst, dstRepo, dstRef, 0)
+}
+
+// copyManifest copies the manifest referenced by srcRef, which is referenced by depth levels of indexes.
+func copyManifest(ctx context.Context, src registryfrontend.Client, srcRepo, srcRef string, dst registryfrontend.Client, dstRepo, dstRef string, depth int) (digest.Digest, error) {
+	m, err := src.Manifest(ctx, srcRepo, srcRef)
+
+	if err != nil {
+		return "", errors.Wrapf(err, "failed fetching %s:%s", srcRepo, srcRef)
+	}
+
+	refs := manifestDto{}
+
+	if err := json.Unmarshal(m.Content, &refs); err != nil {
+		return "", errors.Wrap(err, "could not parse manifest")
+	}
+
+	if len(refs.Manifests) > 0 && depth >= maxIndexDepth {
+		return "", errors.Errorf("%s:%s nests indexes more than %d levels deep", srcRepo, srcRef, maxIndexDepth)
+	}
+
+	for _, child := range refs.Manifests {
+		if _, err := copyManifest(ctx, src, srcRepo, child.Digest.String(), dst, dstRepo, child.Digest.String(), depth+1); err != nil {
+			return "", err
+		}
+	}
+
+	blobs := refs.Layers
+
+	if refs.Config != nil {
+		blobs = append(blobs, *refs.Config)
+	}
+
+	for _, l := range refs.FSLayers {
+		blobs = append(blobs, descriptor{Digest: l.BlobSum})
+	}
+
+	copied := make(map[digest.Digest]bool)
+
+	for _, b := range blobs {
+		if copied[b.Digest] || len(b.URLs) > 0 {
+			continue
+		}
+
+		if err := copyBlob(ctx, src, srcRepo, dst, dstRepo, b); err != nil {
+			return "", errors.Wrapf(err, "failed copying blob %s", b.Digest)
+		}
+
+		copied[b.Digest] = true
+	}
+
+	if err := dst.PutManifest(ctx, dstRepo, dstRef, m); err != nil {
+		return "", errors.Wrapf(err, "failed uploading %s:%s", dstRepo, dstRef)
+	}
+
+	return m.Digest, nil
+}
+
+func copyBlob(ctx context.Context, src registryfrontend.Client, srcRepo string, dst registryfrontend.Client, dstRepo string, b descriptor) error {
+	_, err := dst.BlobSize(ctx, dstRepo, b.Digest)
+
+	if err == nil {
+		return nil
+	} else if StatusCode(err) != http.StatusNotFound {
+		return err
+	}
+
+	content, size, err := src.Blob(ctx, srcRepo, b.Digest)
+
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	// Schema 1 manifests do not include the size of layers.
+	if b.Size > 0 {
+		size = b.Size
+	}
+
+	verified, err := VerifyBlob(content, b.Digest, size)
+
+	if err != nil {
+		return err
+	}
+
+	return dst.PutBlob(ctx, dstRepo, b.Digest, size, verified)
+}
+
+// referrersMediaType is the media type of the index returned by the referrers API.
+const referrersMediaType = "application/vnd.oci.image.index.v1+json"
+
+// Referrers uses the referrers API of OCI 1.1 registries. For older registries, where the API is not found,
+// the referrers are read from the index tagged with the digest, e.g. sha256-<hash>, following the referrers tag schema.
+func (v *V2Client) Referrers(ctx context.Context, repository string, d digest.Digest) ([]registryfrontend.Descriptor, error) {
+	u := fmt.Sprintf("/v2/%s/referrers/%s", repository, d.String())
+
+	req, err := v.newRequest(http.MethodGet, u, nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create registry request")
+	}
+
+	req.Header.Set("Accept", referrersMediaType)
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed fetching referrers")
+	}
+	defer resp.Body.Close()
+
+	var content []byte
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if content, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, errors.Wrap(err, "could not read registry response")
+		}
+	case http.StatusNotFound:
+		m, err := v.Manifest(ctx, repository, d.Algorithm().String()+"-"+d.Encoded())
+
+		if StatusCode(err) == http.StatusNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		content = m.Content
+	default:
+		return nil, statusError(resp)
+	}
+
+	index := struct {
+		Manifests []registryfrontend.Descriptor `json:"manifests"`
+	}{}
+
+	if err := json.Unmarshal(content, &index); err != nil {
+		return nil, errors.Wrap(err, "could not parse referrers")
+	}
+
+	return index.Manifests, nil
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+)
+
+// fakeRegistry stores manifests and blobs in memory, keyed by repository and reference or digest.
+type fakeRegistry struct {
+	mu        sync.Mutex
+	manifests map[string]*registryfrontend.Manifest
+	blobs     map[string][]byte
+	tags      map[string][]string
+}
+
+func newFakeRegistry() *fakeRegistry {
+	return &fakeRegistry{manifests: map[string]*registryfrontend.Manifest{}, blobs: map[string][]byte{}, tags: map[string][]string{}}
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	p := strings.TrimPrefix(r.URL.Path, "/v2/")
+
+	switch {
+	case strings.HasSuffix(p, "/tags/list"):
+		repository := strings.TrimSuffix(p, "/tags/list")
+		json.NewEncoder(w).Encode(map[string]interface{}{"name": repository, "tags": f.tags[repository]})
+	case strings.Contains(p, "/manifests/"):
+		i := strings.Index(p, "/manifests/")
+		key := p[:i] + "@" + p[i+len("/manifests/"):]
+
+		if r.Method == http.MethodPut {
+			content, _ := ioutil.ReadAll(r.Body)
+			m := &registryfrontend.Manifest{MediaType: r.Header.Get("Content-Type"), Digest: digest.FromBytes(content), Content: content}
+			f.manifests[key] = m
+			f.manifests[p[:i]+"@"+m.Digest.String()] = m
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		m, ok := f.manifests[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", m.MediaType)
+		w.Write(m.Content)
+	case strings.HasSuffix(p, "/blobs/uploads/"):
+		w.Header().Set("Location", "/upload/"+strings.TrimSuffix(p, "/blobs/uploads/"))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(p, "/blobs/"):
+		b, ok := f.blobs[strings.Replace(p, "/blobs/", "@", 1)]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(b)))
+		w.Write(b)
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		content, _ := ioutil.ReadAll(r.Body)
+		if digest.FromBytes(content).String() != r.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.blobs[strings.TrimPrefix(r.URL.Path, "/upload/")+"@"+r.URL.Query().Get("digest")] = content
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeRegistry) putBlob(repository, content string) digest.Digest {
+	d := digest.FromString(content)
+	f.blobs[repository+"@"+d.String()] = []byte(content)
+	return d
+}
+
+func (f *fakeRegistry) putTags(repository string, tags ...string) {
+	f.tags[repository] = tags
+}
+
+func (f *fakeRegistry) putManifest(repository, reference, mediaType, content string) digest.Digest {
+	m := &registryfrontend.Manifest{MediaType: mediaType, Digest: digest.FromString(content), Content: []byte(content)}
+	f.manifests[repository+"@"+reference] = m
+	f.manifests[repository+"@"+m.Digest.String()] = m
+	return m.Digest
+}
+
+func TestCopy(t *testing.T) {
+	src, dst := newFakeRegistry(), newFakeRegistry()
+
+	config := src.putBlob("app", `{"architecture":"amd64"}`)
+	layer := src.putBlob("app", "layer")
+	image := src.putManifest("app", "", "application/vnd.oci.image.manifest.v1+json", fmt.Sprintf(
+		`{"config":{"digest":"%s"},"layers":[{"digest":"%s"},{"digest":"%s","urls":["https://foreign.example/layer"]}]}`,
+		config, layer, digest.FromString("foreign")))
+	index := src.putManifest("app", "1.0", "application/vnd.oci.image.index.v1+json", fmt.Sprintf(`{"manifests":[{"digest":"%s"}]}`, image))
+
+	srcSrv, dstSrv := httptest.NewServer(src), httptest.NewServer(dst)
+	defer srcSrv.Close()
+	defer dstSrv.Close()
+
+	srcClient, _ := New(registryfrontend.Registry{Name: "src", Url: srcSrv.URL})
+	dstClient, _ := New(registryfrontend.Registry{Name: "dst", Url: dstSrv.URL})
+
+	d, err := Copy(context.Background(), srcClient, "app", "1.0", dstClient, "mirror/app", "latest")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if d != index {
+		t.Errorf("expected digest %s, got %s", index, d)
+	}
+
+	for _, key := range []string{"mirror/app@latest", "mirror/app@" + image.String()} {
+		if dst.manifests[key] == nil {
+			t.Errorf("expected manifest %s to be copied", key)
+		}
+	}
+	if len(dst.blobs) != 2 {
+		t.Errorf("expected the config and layer to be copied, got %d blobs", len(dst.blobs))
+	}
+
+	_, err = srcClient.Manifest(context.Background(), "app", "missing")
+	if StatusCode(err) != http.StatusNotFound {
+		t.Errorf("expected a not found status, got %v", err)
+	}
+}
+
+// redirectBlobs redirects blob downloads to the same path at another URL, like registries serving blobs from a CDN.
+func redirectBlobs(to string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/sha256:") {
+			http.Redirect(w, r, to+r.URL.Path, http.StatusTemporaryRedirect)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestBlobRedirect(t *testing.T) {
+	f := newFakeRegistry()
+	d := f.putBlob("app", "layer")
+
+	cdn := httptest.NewServer(f)
+	defer cdn.Close()
+	srv := httptest.NewServer(redirectBlobs(cdn.URL, http.NotFoundHandler()))
+	defer srv.Close()
+
+	c, _ := New(registryfrontend.Registry{Name: "a", Url: srv.URL})
+
+	t.Run("blob", blobContent(c, "app", d, "layer"))
+}
+
+func blobContent(c registryfrontend.Client, repository string, d digest.Digest, expected string) func(*testing.T) {
+	return func(t *testing.T) {
+		rc, _, err := c.Blob(context.Background(), repository, d)
+		if err != nil {
+			t.Fatalf("%+v", err)
+		}
+		defer rc.Close()
+
+		content, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expected {
+			t.Errorf("expected %q, got %q", expected, content)
+		}
+	}
+}
+
+func TestNestedIndexes(t *testing.T) {
+	f := newFakeRegistry()
+
+	config := f.putBlob("app", `{"architecture":"amd64"}`)
+	ref := f.putManifest("app", "", "application/vnd.oci.image.manifest.v1+json", fmt.Sprintf(`{"config":{"digest":"%s"},"layers":[]}`, config))
+
+	for _, tag := range []string{"1", "2", "3"} {
+		ref = f.putManifest("app", tag, "application/vnd.oci.image.index.v1+json", fmt.Sprintf(`{"manifests":[{"digest":"%s"}]}`, ref))
+	}
+
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	c, _ := New(registryfrontend.Registry{Name: "a", Url: srv.URL})
+
+	if _, err := c.Tag(context.Background(), "app", "2"); err != nil {
+		t.Errorf("expected an index of indexes to be described, got %+v", err)
+	}
+	if _, err := c.Tag(context.Background(), "app", "3"); err == nil {
+		t.Error("expected indexes nested three levels deep to be rejected")
+	}
+	if _, err := Copy(context.Background(), c, "app", "3", c, "mirror", "3"); err == nil {
+		t.Error("expected copying indexes nested three levels deep to be rejected")
+	}
+}
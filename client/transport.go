@@ -1,8 +1,13 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"net/http"
 	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/pkg/errors"
 )
 
 type basicAuthRoundTripper struct {
@@ -13,7 +18,8 @@ type basicAuthRoundTripper struct {
 }
 
 func (t *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
-	if strings.HasPrefix(req.URL.String(), t.url) {
+	// Requests authorized by a token are left alone.
+	if strings.HasPrefix(req.URL.String(), t.url) && req.Header.Get("Authorization") == "" {
 		if t.user != "" || t.password != "" {
 			req.SetBasicAuth(t.user, t.password)
 		}
@@ -22,15 +28,49 @@ func (t *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 	return resp, err
 }
 
-type baseUrlRoundTripper struct {
-	scheme string
-	host   string
-	inner  http.RoundTripper
+// ValidateTLSOptions returns an error if the certificates or key of the options cannot be used.
+func ValidateTLSOptions(opts registryfrontend.TLSOptions) error {
+	_, err := newTransport(opts)
+	return err
 }
 
-func (b *baseUrlRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
-	r.URL.Scheme = b.scheme
-	r.URL.Host = b.host
+// newTransport creates a transport for a single registry, trusting its CA and presenting its client certificate.
+// Without any TLS options, the shared http.DefaultTransport is used.
+func newTransport(opts registryfrontend.TLSOptions) (http.RoundTripper, error) {
+	if opts == (registryfrontend.TLSOptions{}) {
+		return http.DefaultTransport, nil
+	}
+
+	c := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CACert != "" {
+		pool, err := x509.SystemCertPool()
+
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM([]byte(opts.CACert)) {
+			return nil, errors.New("no valid certificates in CA bundle")
+		}
+
+		c.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(opts.ClientCert), []byte(opts.ClientKey))
+
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid client certificate or key")
+		}
+
+		c.Certificates = []tls.Certificate{cert}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = c
 
-	return b.inner.RoundTrip(r)
+	return t, nil
 }
@@ -7,41 +7,117 @@ import (
 	"github.com/mikaellindemann/registryfrontend"
 	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"time"
 )
 
 type V2Client struct {
-	name string
-	url  string
-	c    http.Client
+	name     string
+	url      string
+	c        http.Client
+	insecure bool
+	kind     string
 }
 
 func MakeV2(name, baseUri string) (*V2Client, error) {
-	u, err := url.Parse(baseUri)
+	if _, err := url.Parse(baseUri); err != nil {
+		return nil, err
+	}
+
+	return newV2(name, baseUri, http.DefaultTransport), nil
+}
+
+func MakeV2BasicAuth(name, baseUri, user, password string) (*V2Client, error) {
+	if _, err := url.Parse(baseUri); err != nil {
+		return nil, err
+	}
+
+	return newV2(name, baseUri, &basicAuthRoundTripper{baseUri, user, password, http.DefaultTransport}), nil
+}
+
+// New creates a client for the registry, using its credentials, TLS options and timeout.
+// Registries opting in with DockerConfig use the credentials of the provider set by SetCredentialProvider,
+// if they have no user or password.
+// Repositories are listed from the allowlist of the registry if it has one, or else as its kind does,
+// e.g. through the Docker Hub API.
+func New(r registryfrontend.Registry) (registryfrontend.Client, error) {
+	k, ok := kinds[r.Kind]
+
+	if !ok {
+		return nil, errors.Errorf("registry %s has unknown kind %q", r.Name, r.Kind)
+	}
+
+	return newClient(r, k, resolveCredentials(r))
+}
+
+// newClient creates a client for the registry of kind k, using the resolved credentials.
+func newClient(r registryfrontend.Registry, k kind, c Credentials) (registryfrontend.Client, error) {
+	// The APIs of providers are authenticated with the same credentials as the registry.
+	r.User, r.Password = c.User, c.Password
+
+	v, err := newV2Client(r, c)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return newV2(name, baseUri, &baseUrlRoundTripper{u.Scheme, u.Host, http.DefaultTransport}), nil
+	switch {
+	case r.Kind == "harbor":
+		return newHarborClient(v, r, k)
+	case len(r.Repositories) > 0:
+		repositories := append([]string(nil), r.Repositories...)
+		sort.Strings(repositories)
+
+		return &hostedClient{V2Client: v, list: func(context.Context) ([]string, error) {
+			return repositories, nil
+		}}, nil
+	case k.list != nil:
+		api, err := newAPIClient(r, k)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &hostedClient{V2Client: v, list: func(ctx context.Context) ([]string, error) {
+			return k.list(ctx, api, r)
+		}}, nil
+	}
+
+	return v, nil
 }
 
-func MakeV2BasicAuth(name, baseUri, user, password string) (*V2Client, error) {
-	u, err := url.Parse(baseUri)
+func newV2Client(r registryfrontend.Registry, c Credentials) (*V2Client, error) {
+	u, err := url.Parse(r.Url)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return newV2(name, baseUri, &baseUrlRoundTripper{
-		u.Scheme,
-		u.Host,
-		&basicAuthRoundTripper{baseUri, user, password, http.DefaultTransport},
-	}), nil
+	t, err := newTransport(r.TLS)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "registry %s", r.Name)
+	}
+
+	auth := &http.Client{Transport: t, Timeout: r.Timeout}
+
+	if c.User != "" && c.IdentityToken == "" {
+		t = &basicAuthRoundTripper{r.Url, c.User, c.Password, t}
+	}
+
+	t = newTokenRoundTripper(u.Host, c, t, auth)
+
+	v := newV2(r.Name, r.Url, t)
+	v.c.Timeout = r.Timeout
+	v.insecure = r.TLS.InsecureSkipVerify
+	v.kind = r.Kind
+
+	return v, nil
 }
 
 func newV2(name, url string, tripper http.RoundTripper) *V2Client {
@@ -54,6 +130,24 @@ func newV2(name, url string, tripper http.RoundTripper) *V2Client {
 	}
 }
 
+// newRequest creates a request for ref, a path or URL resolved against the URL of the registry.
+// Redirects, e.g. of blobs to a CDN, are followed to wherever they point.
+func (v *V2Client) newRequest(method, ref string, body io.Reader) (*http.Request, error) {
+	base, err := url.Parse(v.url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := base.Parse(ref)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return http.NewRequest(method, u.String(), body)
+}
+
 func (v *V2Client) Name() string {
 	return v.name
 }
@@ -62,6 +156,10 @@ func (v *V2Client) URL() string {
 	return v.url
 }
 
+func (v *V2Client) Kind() string {
+	return v.kind
+}
+
 func (v *V2Client) Repositories(ctx context.Context) ([]string, error) {
 	return v.RepositoriesN(ctx, -1, "")
 }
@@ -80,7 +178,7 @@ func (v *V2Client) RepositoriesN(ctx context.Context, n int, last string) ([]str
 		u = fmt.Sprintf("/v2/_catalog?n=%d&last=%s", n, last)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+	req, err := v.newRequest(http.MethodGet, u, nil)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create registry request")
@@ -95,7 +193,7 @@ func (v *V2Client) RepositoriesN(ctx context.Context, n int, last string) ([]str
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+		return nil, statusError(resp)
 	}
 
 	content, err := ioutil.ReadAll(resp.Body)
@@ -134,7 +232,7 @@ func (v *V2Client) TagsN(ctx context.Context, repository string, n int, last str
 		u = fmt.Sprintf("/v2/%s/tags/list?n=%d&last=%s", repository, n, last)
 	}
 
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+	req, err := v.newRequest(http.MethodGet, u, nil)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create registry request")
@@ -148,7 +246,7 @@ func (v *V2Client) TagsN(ctx context.Context, repository string, n int, last str
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+		return nil, statusError(resp)
 	}
 
 	content, err := ioutil.ReadAll(resp.Body)
@@ -176,9 +274,28 @@ type history struct {
 	V1Compatibility string `json:"v1Compatibility"`
 }
 
+// manifestDto holds the fields of all supported manifest formats used by the frontend:
+// schema 1 manifests, schema 2 and OCI image manifests, and manifest lists and OCI indexes.
 type manifestDto struct {
-	FSLayers []fsLayer `json:"fsLayers"`
-	History  []history `json:"history"`
+	FSLayers     []fsLayer    `json:"fsLayers"`
+	History      []history    `json:"history"`
+	ArtifactType string       `json:"artifactType"`
+	Config       *descriptor  `json:"config"`
+	Layers       []descriptor `json:"layers"`
+	Manifests    []descriptor `json:"manifests"`
+}
+
+type descriptor struct {
+	MediaType string        `json:"mediaType"`
+	Digest    digest.Digest `json:"digest"`
+	Size      int64         `json:"size"`
+	URLs      []string      `json:"urls"`
+	Platform  *platform     `json:"platform"`
+}
+
+type platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
 }
 
 type config struct {
@@ -188,49 +305,95 @@ type config struct {
 	User         string
 }
 
+// compatibilityInfo is the image configuration, as embedded in schema 1 manifests or referenced by newer manifests.
 type compatibilityInfo struct {
 	Created       time.Time `json:"created"`
 	Config        config    `json:"config"`
 	DockerVersion string    `json:"docker_version"`
 }
 
+// maxIndexDepth is how many levels of indexes are followed to reach an image, e.g. an index of indexes is followed.
+const maxIndexDepth = 2
+
+// Tag describes the image referenced by a tag or digest.
+// For manifest lists and indexes, the linux/amd64 image is described, or the first image if there is none.
+// Other artifacts, e.g. Helm charts, result in ErrNotImage, and are described by Artifact instead.
 func (v *V2Client) Tag(ctx context.Context, repository, tag string) (*registryfrontend.TagInfo, error) {
-	u := fmt.Sprintf("/v2/%s/manifests/%s", repository, tag)
+	return v.tag(ctx, repository, tag, 0)
+}
 
-	req, err := http.NewRequest(http.MethodGet, u, nil)
+// tag describes the image referenced by tag, which is referenced by depth levels of indexes.
+func (v *V2Client) tag(ctx context.Context, repository, tag string, depth int) (*registryfrontend.TagInfo, error) {
+	m, err := v.Manifest(ctx, repository, tag)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create registry request")
+		return nil, err
 	}
 
-	req = req.WithContext(ctx)
-	resp, err := v.c.Do(req)
+	dto := manifestDto{}
+
+	err = json.Unmarshal(m.Content, &dto)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "failed fetching tags")
+		return nil, errors.Wrap(err, "could not parse registry response")
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("unexpected status code %d", resp.StatusCode)
+	switch {
+	case isArtifact(dto):
+		return nil, errors.WithStack(ErrNotImage)
+	case len(dto.Manifests) > 0 && depth >= maxIndexDepth:
+		return nil, errors.Errorf("%s:%s nests indexes more than %d levels deep", repository, tag, maxIndexDepth)
+	case len(dto.Manifests) > 0:
+		return v.tag(ctx, repository, defaultPlatform(dto.Manifests).String(), depth+1)
+	case dto.Config != nil:
+		return v.imageInfo(ctx, repository, dto)
+	case len(dto.History) > 0:
+		return v.schema1Info(ctx, repository, dto)
 	}
 
-	content, err := ioutil.ReadAll(resp.Body)
+	return nil, errors.Errorf("unsupported manifest type %q", m.MediaType)
+}
+
+func defaultPlatform(manifests []descriptor) digest.Digest {
+	for _, d := range manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == "amd64" {
+			return d.Digest
+		}
+	}
+	return manifests[0].Digest
+}
+
+// imageInfo describes a schema 2 or OCI image, whose layer sizes are part of the manifest.
+func (v *V2Client) imageInfo(ctx context.Context, repository string, dto manifestDto) (*registryfrontend.TagInfo, error) {
+	content, _, err := v.Blob(ctx, repository, dto.Config.Digest)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "could not read registry response")
+		return nil, errors.Wrap(err, "failed fetching image configuration")
 	}
+	defer content.Close()
 
-	dto := manifestDto{}
+	info := compatibilityInfo{}
 
-	err = json.Unmarshal(content, &dto)
+	err = json.NewDecoder(content).Decode(&info)
 
 	if err != nil {
-		return nil, errors.Wrap(err, "could not parse registry response")
+		return nil, errors.Wrap(err, "could not parse image configuration")
 	}
 
+	totalSize := int64(0)
+
+	for _, l := range dto.Layers {
+		totalSize += l.Size
+	}
+
+	return newTagInfo(info, len(dto.Layers), totalSize), nil
+}
+
+// schema1Info describes a schema 1 image, whose layer sizes are requested from the registry.
+func (v *V2Client) schema1Info(ctx context.Context, repository string, dto manifestDto) (*registryfrontend.TagInfo, error) {
 	info := compatibilityInfo{}
 
-	err = json.Unmarshal([]byte(dto.History[0].V1Compatibility), &info)
+	err := json.Unmarshal([]byte(dto.History[0].V1Compatibility), &info)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "could not parse tag information")
@@ -248,6 +411,10 @@ func (v *V2Client) Tag(ctx context.Context, repository, tag string) (*registryfr
 		totalSize += s
 	}
 
+	return newTagInfo(info, len(dto.FSLayers), totalSize), nil
+}
+
+func newTagInfo(info compatibilityInfo, layers int, size int64) *registryfrontend.TagInfo {
 	var keys = func(m map[string]interface{}) []string {
 		res := make([]string, 0, len(m))
 		for k := range m {
@@ -261,17 +428,17 @@ func (v *V2Client) Tag(ctx context.Context, repository, tag string) (*registryfr
 		DockerVersion: info.DockerVersion,
 		EntryPoint:    info.Config.EntryPoint,
 		ExposedPorts:  keys(info.Config.ExposedPorts),
-		Layers:        len(dto.FSLayers),
-		Size:          totalSize,
+		Layers:        layers,
+		Size:          size,
 		User:          info.Config.User,
 		Volumes:       keys(info.Config.Volumes),
-	}, nil
+	}
 }
 
 func (v *V2Client) BlobSize(ctx context.Context, repository string, d digest.Digest) (int64, error) {
 	u := fmt.Sprintf("/v2/%s/blobs/%s", repository, d.String())
 
-	req, err := http.NewRequest(http.MethodHead, u, nil)
+	req, err := v.newRequest(http.MethodHead, u, nil)
 
 	if err != nil {
 		return 0, errors.Wrap(err, "failed to create registry request")
@@ -285,7 +452,7 @@ func (v *V2Client) BlobSize(ctx context.Context, repository string, d digest.Dig
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return 0, errors.Errorf("unexpected status code %d", resp.StatusCode)
+		return 0, statusError(resp)
 	}
 
 	c := resp.Header.Get("content-length")
@@ -294,3 +461,108 @@ func (v *V2Client) BlobSize(ctx context.Context, repository string, d digest.Dig
 
 	return s, errors.Wrap(err, "failed to parse size of blob")
 }
+
+// manifestMediaTypes are accepted when fetching manifests or resolving the digest of a tag,
+// so the registry returns the manifest it actually stores instead of converting it to schema 1.
+var manifestMediaTypes = []string{
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.oci.image.index.v1+json",
+}
+
+// Digest resolves a tag to the digest of its manifest, using a HEAD request so the manifest is not downloaded.
+func (v *V2Client) Digest(ctx context.Context, repository, reference string) (digest.Digest, error) {
+	u := fmt.Sprintf("/v2/%s/manifests/%s", repository, reference)
+
+	req, err := v.newRequest(http.MethodHead, u, nil)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create registry request")
+	}
+
+	for _, mt := range manifestMediaTypes {
+		req.Header.Add("Accept", mt)
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed fetching manifest")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+
+	d, err := digest.Parse(resp.Header.Get("Docker-Content-Digest"))
+
+	return d, errors.Wrap(err, "registry did not return a valid manifest digest")
+}
+
+// DeleteTag deletes the manifest the tag points to.
+// Note that this deletes every other tag pointing to the same manifest as well.
+// The registry must have deletion enabled.
+func (v *V2Client) DeleteTag(ctx context.Context, repository, tag string) (digest.Digest, error) {
+	d, err := v.Digest(ctx, repository, tag)
+
+	if err != nil {
+		return "", err
+	}
+
+	u := fmt.Sprintf("/v2/%s/manifests/%s", repository, d.String())
+
+	req, err := v.newRequest(http.MethodDelete, u, nil)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create registry request")
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return "", errors.Wrap(err, "failed deleting manifest")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return "", statusError(resp)
+	}
+
+	return d, nil
+}
+
+// TLS requests the base endpoint of the registry, and returns the certificate the registry presented.
+// The registry does not have to accept the request, e.g. when authentication fails, the certificate is still returned.
+func (v *V2Client) TLS(ctx context.Context) (*registryfrontend.TLSInfo, error) {
+	req, err := v.newRequest(http.MethodGet, "/v2/", nil)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create registry request")
+	}
+
+	req = req.WithContext(ctx)
+	resp, err := v.c.Do(req)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed connecting to registry")
+	}
+	defer resp.Body.Close()
+
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+
+	cert := resp.TLS.PeerCertificates[0]
+
+	return &registryfrontend.TLSInfo{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		Verified:  !v.insecure,
+	}, nil
+}
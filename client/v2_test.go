@@ -0,0 +1,56 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+)
+
+func TestTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	ca := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}))
+
+	t.Run("untrusted", tlsInfo(registryfrontend.Registry{Name: "a", Url: srv.URL}, false, false))
+	t.Run("custom CA", tlsInfo(registryfrontend.Registry{Name: "a", Url: srv.URL, TLS: registryfrontend.TLSOptions{CACert: ca}}, true, true))
+	t.Run("insecure", tlsInfo(registryfrontend.Registry{Name: "a", Url: srv.URL, TLS: registryfrontend.TLSOptions{InsecureSkipVerify: true}}, true, false))
+
+	if err := ValidateTLSOptions(registryfrontend.TLSOptions{CACert: "not a certificate"}); err == nil {
+		t.Error("expected an error for an invalid CA bundle")
+	}
+	if err := ValidateTLSOptions(registryfrontend.TLSOptions{ClientCert: ca}); err == nil {
+		t.Error("expected an error for a client certificate without a key")
+	}
+}
+
+func tlsInfo(reg registryfrontend.Registry, ok, verified bool) func(*testing.T) {
+	return func(t *testing.T) {
+		c, err := New(reg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := c.TLS(context.Background())
+
+		if !ok {
+			if err == nil {
+				t.Error("expected the certificate to be rejected")
+			}
+			return
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info == nil || info.NotAfter.IsZero() || info.Verified != verified {
+			t.Errorf("unexpected TLS details %+v", info)
+		}
+	}
+}
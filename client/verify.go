@@ -0,0 +1,311 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// verifyManifest checks the content of a manifest against the digest it was requested by,
+// or else the digest reported by the registry, and returns the digest of the manifest.
+func verifyManifest(reference, reported string, content []byte) (digest.Digest, error) {
+	expected, err := digest.Parse(reference)
+
+	if err != nil {
+		if expected, err = digest.Parse(reported); err != nil {
+			// Without a digest to verify against, the manifest is identified by its content.
+			return digest.FromBytes(content), nil
+		}
+	}
+
+	if !expected.Algorithm().Available() {
+		return "", errors.Errorf("unsupported digest algorithm %s", expected.Algorithm())
+	}
+
+	actual := expected.Algorithm().FromBytes(content)
+
+	if actual == expected {
+		return expected, nil
+	}
+
+	// The digest of a signed schema 1 manifest is the digest of its content without the signatures.
+	if payload, ok := schema1Payload(content); ok && expected.Algorithm().FromBytes(payload) == expected {
+		return expected, nil
+	}
+
+	return "", errors.WithStack(&DigestError{Expected: expected, Actual: actual})
+}
+
+// schema1Payload returns the content of a signed schema 1 manifest without its signatures,
+// as described by the protected header of the first signature.
+func schema1Payload(content []byte) ([]byte, bool) {
+	var jws struct {
+		Signatures []struct {
+			Protected string `json:"protected"`
+		} `json:"signatures"`
+	}
+
+	if json.Unmarshal(content, &jws) != nil || len(jws.Signatures) == 0 {
+		return nil, false
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jws.Signatures[0].Protected, "="))
+
+	if err != nil {
+		return nil, false
+	}
+
+	var header struct {
+		FormatLength int    `json:"formatLength"`
+		FormatTail   string `json:"formatTail"`
+	}
+
+	if json.Unmarshal(protected, &header) != nil || header.FormatLength < 0 || header.FormatLength > len(content) {
+		return nil, false
+	}
+
+	tail, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(header.FormatTail, "="))
+
+	if err != nil {
+		return nil, false
+	}
+
+	return append(append([]byte{}, content[:header.FormatLength]...), tail...), true
+}
+
+// VerifyBlob wraps the content of a blob, so reading it to the end fails with a DigestError if it does not match d,
+// or with an error if its size differs from size. A negative size is not checked.
+func VerifyBlob(content io.ReadCloser, d digest.Digest, size int64) (io.ReadCloser, error) {
+	if err := d.Validate(); err != nil {
+		return nil, errors.Wrapf(err, "cannot verify blob %s", d)
+	}
+
+	return &verifyingReader{ReadCloser: content, d: d, h: d.Algorithm().Hash(), size: size}, nil
+}
+
+type verifyingReader struct {
+	io.ReadCloser
+	d    digest.Digest
+	h    hash.Hash
+	size int64
+	n    int64
+}
+
+func (r *verifyingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.h.Write(p[:n])
+	r.n += int64(n)
+
+	if err != io.EOF {
+		return n, err
+	}
+
+	if r.size >= 0 && r.n != r.size {
+		return n, errors.Errorf("blob %s has size %d, expected %d", r.d, r.n, r.size)
+	}
+
+	if actual := digest.NewDigest(r.d.Algorithm(), r.h); actual != r.d {
+		return n, errors.WithStack(&DigestError{Expected: r.d, Actual: actual})
+	}
+
+	return n, io.EOF
+}
+
+// ReadBlob reads the blob of a descriptor into memory, verifying it against the digest and size of the descriptor.
+// Blobs larger than max bytes are not read.
+func ReadBlob(ctx context.Context, c registryfrontend.Client, repository string, desc registryfrontend.Descriptor, max int64) ([]byte, error) {
+	if desc.Size > max {
+		return nil, errors.Errorf("%s is too large", desc.Digest)
+	}
+
+	content, _, err := c.Blob(ctx, repository, desc.Digest)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed fetching %s", desc.Digest)
+	}
+	defer content.Close()
+
+	size := desc.Size
+
+	if size <= 0 {
+		size = -1
+	}
+
+	verified, err := VerifyBlob(content, desc.Digest, size)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(io.LimitReader(verified, max+1))
+
+	if err != nil {
+		return nil, err
+	} else if int64(len(b)) > max {
+		return nil, errors.Errorf("%s is too large", desc.Digest)
+	}
+
+	return b, nil
+}
+
+// Problem is a missing or corrupt manifest or blob found by Verify.
+type Problem struct {
+	// Tag is the first tag the manifest or blob was found through.
+	Tag string
+	// Kind is either manifest or blob.
+	Kind    string
+	Digest  digest.Digest
+	Message string
+}
+
+// Report is the result of verifying a repository.
+type Report struct {
+	Tags      int
+	Manifests int
+	Blobs     int
+	Problems  []Problem
+}
+
+type verifier struct {
+	c          registryfrontend.Client
+	repository string
+	report     *Report
+	// seen are the verified manifests, and sizes the sizes of the checked blobs, or -1 if missing.
+	seen  map[digest.Digest]bool
+	sizes map[digest.Digest]int64
+}
+
+// Verify checks the manifest of every tag of the repository against its digest,
+// and that every blob referenced by the manifests exists with the expected size.
+// Blobs are not downloaded, so their content is not verified.
+// Problems with individual manifests and blobs are reported, while other errors, e.g. failing to list the tags, are returned.
+func Verify(ctx context.Context, c registryfrontend.Client, repository string) (*Report, error) {
+	tags, err := c.Tags(ctx, repository)
+
+	if err != nil {
+		return nil, err
+	}
+
+	v := &verifier{c: c, repository: repository, report: &Report{Tags: len(tags)},
+		seen: make(map[digest.Digest]bool), sizes: make(map[digest.Digest]int64)}
+
+	for _, tag := range tags {
+		if err := v.manifest(ctx, tag, tag); err != nil {
+			return nil, err
+		}
+	}
+
+	return v.report, nil
+}
+
+func (v *verifier) problem(tag, kind string, d digest.Digest, format string, args ...interface{}) {
+	v.report.Problems = append(v.report.Problems, Problem{Tag: tag, Kind: kind, Digest: d, Message: fmt.Sprintf(format, args...)})
+}
+
+// manifest verifies the manifest referenced by tag or digest, and everything it references.
+func (v *verifier) manifest(ctx context.Context, tag, reference string) error {
+	d, _ := digest.Parse(reference)
+
+	if v.seen[d] {
+		return nil
+	}
+
+	m, err := v.c.Manifest(ctx, v.repository, reference)
+
+	switch {
+	case StatusCode(err) == http.StatusNotFound:
+		v.problem(tag, "manifest", d, "missing")
+		return nil
+	case isDigestError(err):
+		v.problem(tag, "manifest", errors.Cause(err).(*DigestError).Expected, "corrupt, %v", errors.Cause(err))
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed fetching manifest of %s", tag)
+	}
+
+	if v.seen[m.Digest] {
+		return nil
+	}
+
+	v.seen[m.Digest] = true
+	v.report.Manifests++
+
+	dto := manifestDto{}
+
+	if err := json.Unmarshal(m.Content, &dto); err != nil {
+		v.problem(tag, "manifest", m.Digest, "corrupt, %v", err)
+		return nil
+	}
+
+	for _, child := range dto.Manifests {
+		if err := v.manifest(ctx, tag, child.Digest.String()); err != nil {
+			return err
+		}
+	}
+
+	blobs := dto.Layers
+
+	if dto.Config != nil {
+		blobs = append(blobs, *dto.Config)
+	}
+
+	for _, l := range dto.FSLayers {
+		blobs = append(blobs, descriptor{Digest: l.BlobSum})
+	}
+
+	for _, b := range blobs {
+		if err := v.blob(ctx, tag, b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *verifier) blob(ctx context.Context, tag string, b descriptor) error {
+	// Foreign layers are not stored by the registry.
+	if len(b.URLs) > 0 {
+		return nil
+	}
+
+	size, ok := v.sizes[b.Digest]
+
+	if !ok {
+		v.report.Blobs++
+
+		s, err := v.c.BlobSize(ctx, v.repository, b.Digest)
+
+		switch {
+		case StatusCode(err) == http.StatusNotFound:
+			v.problem(tag, "blob", b.Digest, "missing")
+			s = -1
+		case err != nil:
+			return errors.Wrapf(err, "failed checking blob %s", b.Digest)
+		}
+
+		size = s
+		v.sizes[b.Digest] = size
+	}
+
+	// The same blob may be referenced with different sizes by different manifests.
+	if size >= 0 && b.Size > 0 && size != b.Size {
+		v.problem(tag, "blob", b.Digest, "size is %d, expected %d", size, b.Size)
+	}
+
+	return nil
+}
+
+func isDigestError(err error) bool {
+	_, ok := errors.Cause(err).(*DigestError)
+	return ok
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestVerify(t *testing.T) {
+	f := newFakeRegistry()
+
+	config := f.putBlob("app", `{}`)
+	layer := f.putBlob("app", "layer")
+	missing := digest.FromString("missing")
+
+	f.putManifest("app", "1.0", "application/vnd.oci.image.manifest.v1+json", fmt.Sprintf(
+		`{"config":{"digest":"%s","size":2},"layers":[{"digest":"%s","size":5},{"digest":"%s","size":7}]}`, config, layer, missing))
+	f.putManifest("app", "2.0", "application/vnd.oci.image.manifest.v1+json", fmt.Sprintf(
+		`{"config":{"digest":"%s","size":2},"layers":[{"digest":"%s","size":6}]}`, config, layer))
+
+	// The index references a manifest stored with different content than its digest.
+	corrupt := digest.FromString("original")
+	f.manifests["app@"+corrupt.String()] = &registryfrontend.Manifest{MediaType: "application/vnd.oci.image.manifest.v1+json", Content: []byte("tampered")}
+	f.putManifest("app", "3.0", "application/vnd.oci.image.index.v1+json", fmt.Sprintf(`{"manifests":[{"digest":"%s"}]}`, corrupt))
+
+	f.putTags("app", "1.0", "2.0", "3.0")
+
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	c, _ := New(registryfrontend.Registry{Name: "reg", Url: srv.URL})
+
+	report, err := Verify(context.Background(), c, "app")
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+
+	if report.Tags != 3 || report.Manifests != 3 || report.Blobs != 3 {
+		t.Errorf("unexpected counts %+v", report)
+	}
+
+	var problems []string
+	for _, p := range report.Problems {
+		problems = append(problems, fmt.Sprintf("%s %s %s %s", p.Tag, p.Kind, p.Digest, strings.SplitN(p.Message, ",", 2)[0]))
+	}
+
+	expected := []string{
+		fmt.Sprintf("1.0 blob %s missing", missing),
+		fmt.Sprintf("2.0 blob %s size is 5", layer),
+		fmt.Sprintf("3.0 manifest %s corrupt", corrupt),
+	}
+
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected problems\n%s\ngot\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+func TestSchema1Payload(t *testing.T) {
+	signed := func(formatLength int) []byte {
+		protected := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"formatLength":%d,"formatTail":"fQ"}`, formatLength)))
+		return []byte(fmt.Sprintf(`{"name":"app","signatures":[{"protected":"%s"}]}`, protected))
+	}
+
+	if payload, ok := schema1Payload(signed(13)); !ok || string(payload) != `{"name":"app"}` {
+		t.Errorf("unexpected payload %q", payload)
+	}
+	for _, formatLength := range []int{-1, 1000} {
+		if _, ok := schema1Payload(signed(formatLength)); ok {
+			t.Errorf("expected format length %d to be rejected", formatLength)
+		}
+	}
+}
+
+func TestVerifyBlob(t *testing.T) {
+	d := digest.FromString("content")
+
+	for content, ok := range map[string]bool{"content": true, "tampered": false, "content!": false} {
+		r, err := VerifyBlob(ioutil.NopCloser(strings.NewReader(content)), d, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := ioutil.ReadAll(r); (err == nil) != ok {
+			t.Errorf("%s: unexpected result %v", content, err)
+		}
+	}
+
+	r, _ := VerifyBlob(ioutil.NopCloser(strings.NewReader("content")), d, 8)
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("expected an error for the wrong size")
+	}
+}
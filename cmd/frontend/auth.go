@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/sirupsen/logrus"
+)
+
+// authMethods configures the enabled authentication methods.
+// If no authentication method is configured, nil is returned and the frontend is open to everyone.
+func authMethods(log *logrus.Logger, c config.Auth) (*auth.Methods, error) {
+	m := auth.Methods{}
+	enabled := false
+
+	if path := c.HtpasswdFile; path != "" {
+		h, err := auth.LoadHtpasswd(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.Password = h
+		enabled = true
+		log.WithField("file", path).Infoln("Password authentication enabled")
+	}
+
+	if header := c.Header.User; header != "" {
+		h, err := auth.NewHeader(header, c.Header.Groups, c.Header.TrustedProxies)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.Request = append(m.Request, h)
+		enabled = true
+		log.WithField("header", header).Infoln("Reverse proxy authentication enabled")
+	}
+
+	if path := c.ClientCert.CAFile; path != "" {
+		m.Request = append(m.Request, auth.ClientCert{Email: c.ClientCert.Username == "email"})
+		enabled = true
+		log.WithField("file", path).Infoln("Client certificate authentication enabled")
+	}
+
+	if issuer := c.OIDC.Issuer; issuer != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		p, err := auth.NewOIDCProvider(ctx, auth.OIDCConfig{
+			Issuer:        issuer,
+			ClientID:      c.OIDC.ClientID,
+			ClientSecret:  c.OIDC.ClientSecret,
+			RedirectURL:   c.OIDC.RedirectURL,
+			Scopes:        c.OIDC.Scopes,
+			UsernameClaim: c.OIDC.UsernameClaim,
+			GroupsClaim:   c.OIDC.GroupsClaim,
+		}, nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.OIDC = p
+		enabled = true
+		log.WithField("issuer", issuer).Infoln("OpenID Connect authentication enabled")
+	}
+
+	if !enabled {
+		return nil, nil
+	}
+
+	return &m, nil
+}
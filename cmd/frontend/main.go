@@ -1,72 +1,240 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
-	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/mikaellindemann/registryfrontend/cosign"
 	"github.com/mikaellindemann/registryfrontend/http"
-	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/mikaellindemann/registryfrontend/vuln"
 	"github.com/mikaellindemann/templateloader"
 
 	"github.com/sirupsen/logrus"
 )
 
+// devFilesDir is where the templates and static files are read from in development.
+const devFilesDir = "http"
+
 func main() {
-	log := &logrus.Logger{
-		Level: logrus.DebugLevel,
-		Out:   os.Stderr,
-		Hooks: make(logrus.LevelHooks),
-		Formatter: &logrus.JSONFormatter{
-			FieldMap: logrus.FieldMap{
-				logrus.FieldKeyTime: "@timestamp",
-				logrus.FieldKeyMsg:  "message",
-			},
-		},
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configFile, os.Environ())
+
+	if err != nil {
+		logrus.Fatalf("%v", err)
+	}
+
+	log := newLogger(cfg.Log)
+
+	if path := cfg.Credentials.DockerConfigFile; path != "" {
+		dockerConfig, err := client.LoadDockerConfig(path)
+
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+
+		client.SetCredentialProvider(dockerConfig, func(err error) {
+			log.Warnf("%+v", err)
+		})
+		log.WithField("file", path).Infoln("Registry credentials are looked up in the Docker config")
+	}
+
+	st, err := openStorage(log, cfg.Storage)
+
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	rs := newRegistrySync(log, st)
+	status := rs.apply(cfg)
+	status.Source = *configFile
+
+	if status.Err != nil {
+		log.Fatalf("%v", status.Err)
 	}
 
-	st := storage.NewInMemoryStorage()
+	t := newLoader(log, cfg.Environment)
+
+	opts := []http.Option{http.WithAddress(cfg.Listen), http.WithBasePath(cfg.BasePath)}
 
-	addRemoveDisabled := false
-	if _, ok := os.LookupEnv("REGISTRY_DISABLE_ADD_REMOVE"); ok {
-		addRemoveDisabled = true
+	if cfg.TrustForwardedHeaders {
+		opts = append(opts, http.WithForwardedHeaders())
 	}
 
-	name := os.Getenv("REGISTRY_NAME")
-	url := os.Getenv("REGISTRY_URL")
-	user := os.Getenv("REGISTRY_AUTH_BASIC_USER")
-	password := os.Getenv("REGISTRY_AUTH_BASIC_PASSWORD")
+	methods, err := authMethods(log, cfg.Auth)
+
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	if methods != nil {
+		opts = append(opts, http.WithAuthentication(*methods, auth.NewSessionStore(time.Duration(cfg.Auth.SessionTTL))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	tlsOpts, err := tlsOptions(ctx, log, cfg)
 
-	if name != "" && url != "" {
-		st.Add(registryfrontend.Registry{
-			Name:     name,
-			Url:      url,
-			User:     user,
-			Password: password,
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	opts = append(opts, tlsOpts...)
+
+	if path := cfg.Authorization.PolicyFile; path != "" {
+		policy, err := authz.OpenPolicyFile(path)
+
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+
+		go policy.Watch(ctx, 10*time.Second, func(err error) {
+			if err != nil {
+				log.WithField("file", path).Errorf("Failed reloading authorization policy: %+v", err)
+				return
+			}
+			log.WithField("file", path).Infoln("Reloaded authorization policy")
 		})
+
+		opts = append(opts, http.WithAuthorization(policy))
+		log.WithField("file", path).Infoln("Authorization policy enabled")
+	}
+
+	if path := cfg.Credentials.ImportFile; path != "" {
+		opts = append(opts, http.WithImportFile(path))
 	}
 
-	var t templateloader.Loader
+	if path := cfg.Audit.File; path != "" {
+		auditLog, err := audit.Open(path)
 
-	if strings.ToUpper(os.Getenv("ENVIRONMENT")) == "DEVELOPMENT" {
-		t = templateloader.NewOnRequestLoader()
-		log.Debugln("Using the on-request-loader")
-	} else {
-		t = templateloader.NewPreloader()
-		log.Debugln("Preloading templates")
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		defer auditLog.Close()
+
+		opts = append(opts, http.WithAuditLog(auditLog))
+		log.WithField("file", path).Infoln("Audit log enabled")
+	}
+
+	if len(cfg.Cosign.PublicKeys) > 0 {
+		keys, err := cosign.LoadPublicKeys(cfg.Cosign.PublicKeys)
+
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+
+		opts = append(opts, http.WithCosignKeys(keys))
+		log.WithField("keys", len(keys)).Infoln("Signature verification enabled")
 	}
 
-	s := http.NewServer(log, t, st, !addRemoveDisabled)
+	if path := cfg.Vulnerabilities.Directory; path != "" {
+		dir, err := vuln.OpenDirectory(path)
+
+		if err != nil {
+			log.WithField("directory", path).Warnf("Failed reading vulnerability reports: %+v", err)
+		}
+
+		go dir.Watch(ctx, 30*time.Second, func(err error) {
+			if err != nil {
+				log.WithField("directory", path).Errorf("Failed reloading vulnerability reports: %+v", err)
+				return
+			}
+			log.WithField("directory", path).Infoln("Reloaded vulnerability reports")
+		})
+
+		opts = append(opts, http.WithVulnerabilityReports(dir))
+		log.WithField("directory", path).Infoln("Vulnerability reports enabled")
+	}
+
+	s := http.NewServer(log, t, st, !cfg.Features.DisableAddRemove, opts...)
+	s.SetReloadStatus(status)
 	s.Start()
 
-	stop := make(chan os.Signal)
+	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
 
-	<-stop
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for {
+		select {
+		case <-hup:
+			log.Infoln("Reloading configuration")
+			status := reload(log, *configFile, rs, s)
+
+			if status.Err != nil {
+				log.Errorf("Failed reloading configuration: %+v", status.Err)
+			} else {
+				log.Infoln("Reloaded configuration")
+			}
+
+			s.SetReloadStatus(status)
+		case <-stop:
+			if err := s.Shutdown(); err != nil {
+				panic(err)
+			}
+			return
+		}
+	}
+}
+
+// newLoader returns a loader reading the templates and static files from the source tree on every request in development,
+// and otherwise a loader using the files embedded in the binary.
+func newLoader(log *logrus.Logger, environment string) templateloader.Loader {
+	if strings.ToUpper(environment) == "DEVELOPMENT" {
+		if _, err := os.Stat(devFilesDir); err == nil {
+			log.WithField("dir", devFilesDir).Debugln("Using the on-request-loader")
+			return http.NewFiles(os.DirFS(devFilesDir), true)
+		}
 
-	if err := s.Shutdown(); err != nil {
-		panic(err)
+		log.WithField("dir", devFilesDir).Warnln("Using embedded templates, as the source tree was not found")
 	}
+
+	log.Debugln("Using embedded templates")
+	return http.EmbeddedFiles()
+}
+
+func newLogger(c config.Log) *logrus.Logger {
+	log := &logrus.Logger{
+		Out:   os.Stderr,
+		Hooks: make(logrus.LevelHooks),
+	}
+
+	configureLogger(log, c)
+	log.AddHook(secret.RedactHook{})
+
+	return log
+}
+
+// configureLogger sets the level and format of log, which is safe while the logger is in use.
+func configureLogger(log *logrus.Logger, c config.Log) {
+	// The configuration has been validated, so the level is known to be valid.
+	level, _ := logrus.ParseLevel(c.Level)
+
+	var formatter logrus.Formatter = &logrus.JSONFormatter{
+		FieldMap: logrus.FieldMap{
+			logrus.FieldKeyTime: "@timestamp",
+			logrus.FieldKeyMsg:  "message",
+		},
+	}
+
+	if c.Format == "text" {
+		formatter = &logrus.TextFormatter{FullTimestamp: true}
+	}
+
+	log.SetLevel(level)
+	log.SetFormatter(formatter)
 }
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/mikaellindemann/registryfrontend/http"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// registrySync adds the registries of the configuration to the storage.
+// It keeps track of the added registries, so that registries removed from the configuration
+// are removed from the storage as well, while registries added through the frontend are left alone.
+type registrySync struct {
+	log     *logrus.Logger
+	st      registryfrontend.Storage
+	managed map[string]bool
+}
+
+func newRegistrySync(log *logrus.Logger, st registryfrontend.Storage) *registrySync {
+	return &registrySync{
+		log:     log,
+		st:      st,
+		managed: make(map[string]bool),
+	}
+}
+
+// apply adds, updates and removes registries, so that the storage matches the configuration.
+// If a registry of the configuration file is invalid, nothing is changed.
+// Invalid registries from the environment are rejected, without affecting the other registries.
+func (rs *registrySync) apply(cfg config.Config) http.ReloadStatus {
+	status := http.ReloadStatus{Time: time.Now()}
+
+	var names []string
+	regs := make(map[string]registryfrontend.Registry)
+
+	// Registries from the environment replace registries with the same name from the configuration file.
+	add := func(reg registryfrontend.Registry) {
+		if _, ok := regs[reg.Name]; !ok {
+			names = append(names, reg.Name)
+		}
+		regs[reg.Name] = reg
+	}
+
+	for i, rc := range cfg.Registries {
+		reg, err := rc.Registry(i)
+
+		if err != nil {
+			status.Err = err
+			return status
+		}
+
+		add(reg)
+	}
+
+	for i, rc := range cfg.EnvRegistries {
+		reg, err := rc.Registry(i)
+
+		if err != nil {
+			rs.log.WithFields(logrus.Fields{"registry": rc.Name, "env": rc.Env}).Errorf("Rejected registry from environment: %v", err)
+			status.Rejected = append(status.Rejected, rc.Env)
+			continue
+		}
+
+		add(reg)
+	}
+
+	var errs []string
+	managed := make(map[string]bool, len(names))
+
+	for _, name := range names {
+		l := rs.log.WithField("registry", name)
+		reg := regs[name]
+		existing, err := rs.st.Lookup(name)
+
+		switch {
+		case err == storage.ErrRegistryNotFound:
+			err = rs.st.Add(reg)
+			if err == nil {
+				status.Added = append(status.Added, name)
+				l.Infoln("Added registry from configuration")
+			}
+		case err != nil:
+			// Reported below.
+		case !reflect.DeepEqual(existing, reg):
+			err = rs.st.Update(reg)
+			if err == nil {
+				status.Updated = append(status.Updated, name)
+				l.Infoln("Updated registry from configuration")
+			}
+		}
+
+		if err != nil {
+			l.Errorf("Failed adding registry: %+v", err)
+			errs = append(errs, name+": "+err.Error())
+			continue
+		}
+
+		managed[name] = true
+	}
+
+	for name := range rs.managed {
+		if _, ok := regs[name]; ok {
+			continue
+		}
+
+		l := rs.log.WithField("registry", name)
+		reg, err := rs.st.Lookup(name)
+
+		if err == storage.ErrRegistryNotFound {
+			continue
+		}
+
+		if err == nil {
+			err = rs.st.Remove(reg)
+		}
+
+		if err != nil {
+			l.Errorf("Failed removing registry: %+v", err)
+			errs = append(errs, name+": "+err.Error())
+			managed[name] = true
+			continue
+		}
+
+		status.Removed = append(status.Removed, name)
+		l.Infoln("Removed registry no longer in configuration")
+	}
+
+	rs.managed = managed
+
+	if len(errs) > 0 {
+		status.Err = errors.Errorf("failed updating registries: %v", errs)
+	}
+
+	return status
+}
+
+// reload reads the configuration again, and applies the settings that can be changed while running:
+// the registries, the templates and the log level and format.
+// Other settings, e.g. the listen address, require a restart.
+func reload(log *logrus.Logger, configFile string, rs *registrySync, s *http.Server) http.ReloadStatus {
+	cfg, err := config.Load(configFile, os.Environ())
+
+	if err != nil {
+		return http.ReloadStatus{Time: time.Now(), Source: configFile, Err: err}
+	}
+
+	configureLogger(log, cfg.Log)
+
+	status := rs.apply(cfg)
+	status.Source = configFile
+
+	if err := s.Reload(newLoader(log, cfg.Environment)); err != nil {
+		if status.Err != nil {
+			err = errors.Wrap(err, status.Err.Error())
+		}
+		status.Err = err
+	}
+
+	return status
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// openStorage returns the storage backend selected by the configuration.
+// Passwords stored by the file backend are encrypted with the configured keys.
+func openStorage(log *logrus.Logger, c config.Storage) (registryfrontend.Storage, error) {
+	if c.Backend != "file" {
+		return storage.NewSynchronizedStorage(storage.NewInMemoryStorage()), nil
+	}
+
+	keys, err := c.Keyring()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithField("file", c.File).Infoln("Persisting registries to file")
+
+	return storage.NewFileStorage(c.File, keys)
+}
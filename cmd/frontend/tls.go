@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"io/ioutil"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/mikaellindemann/registryfrontend/http"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// tlsOptions configures https serving, if a certificate is configured.
+// The certificate is reloaded when it changes, until ctx is cancelled.
+func tlsOptions(ctx context.Context, log *logrus.Logger, c config.Config) ([]http.Option, error) {
+	if c.TLS.CertFile == "" {
+		return nil, nil
+	}
+
+	cert, err := http.OpenCertificateFile(c.TLS.CertFile, c.TLS.KeyFile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	l := log.WithField("file", c.TLS.CertFile)
+
+	go cert.Watch(ctx, 10*time.Second, func(err error) {
+		if err != nil {
+			l.Errorf("Failed reloading certificate: %+v", err)
+			return
+		}
+		l.Infoln("Reloaded certificate")
+	})
+
+	opts := []http.Option{http.WithTLS(cert)}
+	l.Infoln("TLS enabled")
+
+	if path := c.Auth.ClientCert.CAFile; path != "" {
+		pem, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading client CA file")
+		}
+
+		cas := x509.NewCertPool()
+
+		if !cas.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificates in client CA file %s", path)
+		}
+
+		opts = append(opts, http.WithClientCAs(cas, c.Auth.ClientCert.Required))
+	}
+
+	if addr := c.TLS.RedirectListen; addr != "" {
+		opts = append(opts, http.WithRedirect(addr))
+	}
+
+	return opts, nil
+}
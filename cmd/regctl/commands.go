@@ -0,0 +1,495 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type app struct {
+	opts   *options
+	stdout io.Writer
+	stderr io.Writer
+
+	st registryfrontend.Storage
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return usageError("no command given")
+	}
+
+	switch cmd := strings.Join(args[:min(2, len(args))], " "); {
+	case cmd == "ls registries":
+		return a.withArgs(args[2:], 0, func(_ []string) error { return a.listRegistries() })
+	case cmd == "ls repos":
+		return a.withArgs(args[2:], 1, func(args []string) error { return a.listRepositories(ctx, args[0]) })
+	case cmd == "ls tags":
+		return a.withArgs(args[2:], 1, func(args []string) error { return a.listTags(ctx, args[0]) })
+	case args[0] == "inspect":
+		return a.withArgs(args[1:], 1, func(args []string) error { return a.inspect(ctx, args[0]) })
+	case args[0] == "delete":
+		return a.withArgs(args[1:], 1, func(args []string) error { return a.delete(ctx, args[0]) })
+	case args[0] == "copy":
+		return a.withArgs(args[1:], 2, func(args []string) error { return a.copy(ctx, args[0], args[1]) })
+	case args[0] == "verify":
+		return a.withArgs(args[1:], 1, func(args []string) error { return a.verify(ctx, args[0]) })
+	case args[0] == "completion":
+		return a.withArgs(args[1:], 1, func(args []string) error { return completionScript(a.stdout, args[0]) })
+	case args[0] == completeCommand:
+		a.complete(ctx, args[1:])
+		return nil
+	}
+
+	return usageError(fmt.Sprintf("unknown command %q", strings.Join(args, " ")))
+}
+
+func (a *app) withArgs(args []string, n int, f func(args []string) error) error {
+	if len(args) != n {
+		return usageError(fmt.Sprintf("expected %d arguments, got %d", n, len(args)))
+	}
+	return f(args)
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// storage returns the registries, reading the configuration on first use.
+func (a *app) storage() (registryfrontend.Storage, error) {
+	if a.st != nil {
+		return a.st, nil
+	}
+
+	st, err := openRegistries(a.opts.config, a.stderr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.st = st
+	return st, nil
+}
+
+func (a *app) registry(name string) (registryfrontend.Client, error) {
+	st, err := a.storage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := st.Registry(name)
+
+	return c, errors.Wrapf(err, "registry %s", name)
+}
+
+type registryRow struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+func (a *app) listRegistries() error {
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	clients, err := a.registryClients()
+
+	if err != nil {
+		return err
+	}
+
+	var rows []interface{}
+
+	for _, c := range clients {
+		rows = append(rows, registryRow{Name: c.Name(), URL: c.URL()})
+	}
+
+	return p.list(rows, []string{"NAME", "URL"}, func(v interface{}) []string {
+		r := v.(registryRow)
+		return []string{r.Name, r.URL}
+	})
+}
+
+// registryClients returns the clients of all registries, sorted by name.
+func (a *app) registryClients() ([]registryfrontend.Client, error) {
+	st, err := a.storage()
+
+	if err != nil {
+		return nil, err
+	}
+
+	clients, err := st.Registries()
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(clients, func(i, j int) bool { return clients[i].Name() < clients[j].Name() })
+
+	return clients, nil
+}
+
+type repositoryRow struct {
+	Registry string `json:"registry"`
+	Name     string `json:"name"`
+}
+
+func (a *app) listRepositories(ctx context.Context, name string) error {
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := a.registry(name)
+
+	if err != nil {
+		return err
+	}
+
+	repos, err := c.Repositories(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	var rows []interface{}
+
+	for _, r := range repos {
+		rows = append(rows, repositoryRow{Registry: name, Name: r})
+	}
+
+	return p.list(rows, []string{"REPOSITORY"}, func(v interface{}) []string {
+		return []string{v.(repositoryRow).Name}
+	})
+}
+
+type tagRow struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Name       string `json:"name"`
+}
+
+func (a *app) listTags(ctx context.Context, s string) error {
+	ref, err := repository(s)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := a.registry(ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	tags, err := c.Tags(ctx, ref.Repository)
+
+	if err != nil {
+		return err
+	}
+
+	var rows []interface{}
+
+	for _, t := range tags {
+		rows = append(rows, tagRow{Registry: ref.Registry, Repository: ref.Repository, Name: t})
+	}
+
+	return p.list(rows, []string{"TAG"}, func(v interface{}) []string {
+		return []string{v.(tagRow).Name}
+	})
+}
+
+type imageDetails struct {
+	Registry      string    `json:"registry"`
+	Repository    string    `json:"repository"`
+	Reference     string    `json:"reference"`
+	Created       time.Time `json:"created"`
+	DockerVersion string    `json:"docker_version"`
+	EntryPoint    []string  `json:"entrypoint"`
+	ExposedPorts  []string  `json:"exposed_ports"`
+	Layers        int       `json:"layers"`
+	Size          int64     `json:"size"`
+	User          string    `json:"user"`
+	Volumes       []string  `json:"volumes"`
+}
+
+func (a *app) inspect(ctx context.Context, s string) error {
+	ref, err := image(s)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := a.registry(ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	info, err := c.Tag(ctx, ref.Repository, ref.Reference)
+
+	if errors.Cause(err) == client.ErrNotImage {
+		return a.inspectArtifact(ctx, p, c, ref)
+	} else if err != nil {
+		return err
+	}
+
+	d := imageDetails{
+		Registry:      ref.Registry,
+		Repository:    ref.Repository,
+		Reference:     ref.Reference,
+		Created:       info.Created,
+		DockerVersion: info.DockerVersion,
+		EntryPoint:    info.EntryPoint,
+		ExposedPorts:  info.ExposedPorts,
+		Layers:        info.Layers,
+		Size:          info.Size,
+		User:          info.User,
+		Volumes:       info.Volumes,
+	}
+
+	return p.object(d, [][2]string{
+		{"Image", ref.String()},
+		{"Created", d.Created.Format(time.RFC3339)},
+		{"Docker version", d.DockerVersion},
+		{"Entrypoint", strings.Join(d.EntryPoint, " ")},
+		{"Exposed ports", strings.Join(d.ExposedPorts, ", ")},
+		{"Layers", strconv.Itoa(d.Layers)},
+		{"Size", strconv.FormatInt(d.Size, 10)},
+		{"User", d.User},
+		{"Volumes", strings.Join(d.Volumes, ", ")},
+	})
+}
+
+type artifactDetails struct {
+	Registry     string            `json:"registry"`
+	Repository   string            `json:"repository"`
+	Reference    string            `json:"reference"`
+	Kind         string            `json:"kind"`
+	MediaType    string            `json:"media_type"`
+	ArtifactType string            `json:"artifact_type"`
+	Created      time.Time         `json:"created"`
+	Layers       int               `json:"layers"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations"`
+	// Chart is the metadata of a Helm chart, as in its Chart.yaml.
+	Chart *registryfrontend.Chart `json:"chart,omitempty"`
+}
+
+// inspectArtifact shows the details of manifests that are not images, e.g. Helm charts.
+func (a *app) inspectArtifact(ctx context.Context, p *printer, c registryfrontend.Client, ref reference) error {
+	info, err := c.Artifact(ctx, ref.Repository, ref.Reference)
+
+	if err != nil {
+		return err
+	}
+
+	d := artifactDetails{
+		Registry:     ref.Registry,
+		Repository:   ref.Repository,
+		Reference:    ref.Reference,
+		Kind:         info.Kind,
+		MediaType:    info.MediaType,
+		ArtifactType: info.ArtifactType,
+		Created:      info.Created,
+		Layers:       len(info.Layers),
+		Size:         info.Size,
+		Annotations:  info.Annotations,
+		Chart:        info.Chart,
+	}
+
+	fields := [][2]string{
+		{"Artifact", ref.String()},
+		{"Type", d.Kind},
+		{"Artifact type", d.ArtifactType},
+		{"Media type", d.MediaType},
+		{"Layers", strconv.Itoa(d.Layers)},
+		{"Size", strconv.FormatInt(d.Size, 10)},
+	}
+
+	if c := d.Chart; c != nil {
+		fields = append(fields, [][2]string{
+			{"Chart", c.Name},
+			{"Chart version", c.Version},
+			{"App version", c.AppVersion},
+			{"Description", c.Description},
+		}...)
+	}
+
+	keys := make([]string, 0, len(d.Annotations))
+
+	for k := range d.Annotations {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fields = append(fields, [2]string{k, d.Annotations[k]})
+	}
+
+	return p.object(d, fields)
+}
+
+type result struct {
+	Image  string        `json:"image"`
+	Digest digest.Digest `json:"digest"`
+}
+
+func (a *app) delete(ctx context.Context, s string) error {
+	ref, err := image(s)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := a.registry(ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	d, err := c.DeleteTag(ctx, ref.Repository, ref.Reference)
+
+	if err != nil {
+		return err
+	}
+
+	return p.object(result{Image: ref.String(), Digest: d}, [][2]string{{"Deleted", ref.String()}, {"Digest", d.String()}})
+}
+
+// copy copies the image to the destination, which defaults to the tag or digest of the source if it has none.
+func (a *app) copy(ctx context.Context, from, to string) error {
+	src, err := image(from)
+
+	if err != nil {
+		return err
+	}
+
+	dst, err := parseReference(to)
+
+	if err != nil {
+		return err
+	}
+
+	if dst.Reference == "" {
+		dst.Reference = src.Reference
+	}
+
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	srcClient, err := a.registry(src.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	dstClient, err := a.registry(dst.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	d, err := client.Copy(ctx, srcClient, src.Repository, src.Reference, dstClient, dst.Repository, dst.Reference)
+
+	if err != nil {
+		return err
+	}
+
+	return p.object(result{Image: dst.String(), Digest: d}, [][2]string{{"Copied", dst.String()}, {"Digest", d.String()}})
+}
+
+// errProblems is returned when verify found problems, after printing them.
+var errProblems = errors.New("found missing or corrupt manifests or blobs")
+
+type problemRow struct {
+	Tag     string        `json:"tag"`
+	Kind    string        `json:"kind"`
+	Digest  digest.Digest `json:"digest"`
+	Problem string        `json:"problem"`
+}
+
+func (a *app) verify(ctx context.Context, s string) error {
+	ref, err := repository(s)
+
+	if err != nil {
+		return err
+	}
+
+	p, err := newPrinter(a.stdout, a.opts.output)
+
+	if err != nil {
+		return err
+	}
+
+	c, err := a.registry(ref.Registry)
+
+	if err != nil {
+		return err
+	}
+
+	report, err := client.Verify(ctx, c, ref.Repository)
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.stderr, "Checked %d tags, %d manifests and %d blobs\n", report.Tags, report.Manifests, report.Blobs)
+
+	var rows []interface{}
+
+	for _, pr := range report.Problems {
+		rows = append(rows, problemRow{Tag: pr.Tag, Kind: pr.Kind, Digest: pr.Digest, Problem: pr.Message})
+	}
+
+	if err := p.list(rows, []string{"TAG", "KIND", "DIGEST", "PROBLEM"}, func(v interface{}) []string {
+		r := v.(problemRow)
+		return []string{r.Tag, r.Kind, r.Digest.String(), r.Problem}
+	}); err != nil {
+		return err
+	}
+
+	if len(rows) > 0 {
+		return errProblems
+	}
+
+	return nil
+}
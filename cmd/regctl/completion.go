@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// completeCommand is called by the completion scripts with the command line up to the cursor,
+// and prints the candidates for the word at the cursor, one per line.
+const completeCommand = "__complete"
+
+const bashCompletion = `# bash completion for regctl, load with: source <(regctl completion bash)
+_regctl() {
+	local line="${COMP_LINE:0:COMP_POINT}"
+	local cur="${line##*[[:space:]]}"
+	local IFS=$'\n'
+	COMPREPLY=($("${COMP_WORDS[0]}" __complete "$line" 2>/dev/null))
+
+	local c
+	for c in "${COMPREPLY[@]}"; do
+		if [[ "$c" == */ || "$c" == *: ]]; then
+			compopt -o nospace
+		fi
+	done
+
+	# Words are split at colons, so only the part after the last colon is completed.
+	if [[ "$cur" == *:* && "$COMP_WORDBREAKS" == *:* ]]; then
+		local i
+		for i in "${!COMPREPLY[@]}"; do
+			COMPREPLY[$i]="${COMPREPLY[$i]#"${cur%"${cur##*:}"}"}"
+		done
+	fi
+}
+complete -F _regctl regctl
+`
+
+const zshCompletion = `#compdef regctl
+# zsh completion for regctl, load with: source <(regctl completion zsh)
+_regctl() {
+	local -a candidates
+	candidates=("${(@f)$(${words[1]} __complete "${(j: :)words[1,CURRENT]}" 2>/dev/null)}")
+	compadd -S '' -- ${(M)candidates:#*[/:]}
+	compadd -- ${candidates:#*[/:]}
+}
+compdef _regctl regctl
+`
+
+func completionScript(w io.Writer, shell string) error {
+	switch shell {
+	case "bash":
+		_, err := io.WriteString(w, bashCompletion)
+		return err
+	case "zsh":
+		_, err := io.WriteString(w, zshCompletion)
+		return err
+	}
+
+	return usageError(fmt.Sprintf("unsupported shell %q, must be bash or zsh", shell))
+}
+
+// complete prints the completions of the command line in args.
+// Registries, repositories and tags are completed from the configured registries, errors are ignored.
+func (a *app) complete(ctx context.Context, args []string) {
+	if len(args) != 1 {
+		return
+	}
+
+	words := strings.Fields(args[0])
+
+	if len(words) == 0 {
+		return
+	}
+
+	// The first word is regctl itself, and the last is the word being completed.
+	words = words[1:]
+
+	if len(words) == 0 || strings.HasSuffix(args[0], " ") {
+		words = append(words, "")
+	}
+
+	cur := words[len(words)-1]
+
+	if strings.HasPrefix(cur, "-") {
+		return
+	}
+
+	// Use the configuration file of the command line being completed, if it has one.
+	o := &options{}
+	fs := o.flags(ioutil.Discard)
+	prev, err := parseArgs(fs, words[:len(words)-1])
+
+	if err != nil {
+		return
+	}
+
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "config" {
+			a.opts.config = o.config
+		}
+	})
+
+	a.stderr = ioutil.Discard
+
+	var candidates []string
+
+	switch strings.Join(prev, " ") {
+	case "":
+		candidates = []string{"ls", "inspect", "delete", "copy", "verify", "completion"}
+	case "ls":
+		candidates = []string{"registries", "repos", "tags"}
+	case "ls repos":
+		candidates = a.registryNames("")
+	case "ls tags", "verify":
+		candidates = a.completeReference(ctx, cur, false)
+	case "inspect", "delete", "copy":
+		candidates = a.completeReference(ctx, cur, true)
+	case "completion":
+		candidates = []string{"bash", "zsh"}
+	default:
+		if len(prev) == 2 && prev[0] == "copy" {
+			candidates = a.completeReference(ctx, cur, true)
+		}
+	}
+
+	for _, c := range candidates {
+		if strings.HasPrefix(c, cur) {
+			fmt.Fprintln(a.stdout, c)
+		}
+	}
+}
+
+func (a *app) registryNames(suffix string) []string {
+	clients, err := a.registryClients()
+
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, len(clients))
+
+	for i, c := range clients {
+		names[i] = c.Name() + suffix
+	}
+
+	return names
+}
+
+// completeReference completes a reference to a repository, and to its tags if tags is true.
+func (a *app) completeReference(ctx context.Context, cur string, tags bool) []string {
+	i := strings.Index(cur, "/")
+
+	if i < 0 {
+		return a.registryNames("/")
+	}
+
+	c, err := a.registry(cur[:i])
+
+	if err != nil {
+		return nil
+	}
+
+	repo := cur[i+1:]
+
+	if j := strings.LastIndex(repo, ":"); tags && j > strings.LastIndex(repo, "/") {
+		names, err := c.Tags(ctx, repo[:j])
+
+		if err != nil {
+			return nil
+		}
+
+		for k, t := range names {
+			names[k] = cur[:i+1] + repo[:j] + ":" + t
+		}
+
+		return names
+	}
+
+	repos, err := c.Repositories(ctx)
+
+	if err != nil {
+		return nil
+	}
+
+	suffix := ""
+
+	if tags {
+		suffix = ":"
+	}
+
+	for k, r := range repos {
+		repos[k] = cur[:i+1] + r + suffix
+	}
+
+	return repos
+}
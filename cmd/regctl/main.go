@@ -0,0 +1,211 @@
+// Command regctl lists, inspects, deletes, copies and verifies images in the registries configured for the frontend.
+//
+// It reads the same configuration file and environment variables as the frontend,
+// and includes the registries persisted by the file storage backend.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/pkg/errors"
+)
+
+// Exit codes, so scripts can tell missing images from missing permissions.
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+	exitAuth     = 4
+	exitProblems = 5
+)
+
+const usage = `Usage: regctl [flags] <command>
+
+Commands:
+  ls registries                          list the configured registries
+  ls repos <registry>                    list the repositories of a registry
+  ls tags <registry>/<repository>        list the tags of a repository
+  inspect <registry>/<repository>:<tag>  show the details of an image
+  delete <registry>/<repository>:<tag>   delete the manifest the tag points to
+  copy <source> <destination>            copy an image, e.g. copy a/app:1.0 b/mirror/app
+  verify <registry>/<repository>         check the manifests and blobs of every tag
+  completion bash|zsh                    print a shell completion script
+
+Images can be referenced by digest as well, e.g. a/app@sha256:...
+
+Exit codes: 0 success, 1 error, 2 usage error, 3 not found, 4 authentication or authorization failed,
+5 verify found missing or corrupt manifests or blobs.
+
+Flags:
+`
+
+// usageError is returned for invalid command lines.
+type usageError string
+
+func (e usageError) Error() string {
+	return string(e)
+}
+
+type options struct {
+	config  string
+	output  string
+	timeout time.Duration
+}
+
+func (o *options) flags(output io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("regctl", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&o.config, "config", os.Getenv("CONFIG_FILE"), "path to the YAML configuration file of the frontend")
+	fs.StringVar(&o.output, "o", "table", "output format: table, json or template=<go template>")
+	fs.DurationVar(&o.timeout, "timeout", 5*time.Minute, "timeout of the command")
+	fs.Usage = func() {
+		fmt.Fprint(output, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	o := &options{}
+	fs := o.flags(stderr)
+
+	args, err := parseArgs(fs, args)
+
+	if err == flag.ErrHelp {
+		return exitOK
+	} else if err != nil {
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	a := &app{opts: o, stdout: stdout, stderr: stderr}
+
+	if err := a.run(ctx, args); err != nil {
+		fmt.Fprintf(stderr, "regctl: %v\n", err)
+
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintln(stderr, "Run regctl -h for usage.")
+		}
+
+		return exitCode(err)
+	}
+
+	return exitOK
+}
+
+// parseArgs parses the flags anywhere on the command line, and returns the remaining arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+
+		if fs.NArg() == 0 {
+			return rest, nil
+		}
+
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func exitCode(err error) int {
+	if _, ok := err.(usageError); ok {
+		return exitUsage
+	}
+
+	if err == errProblems {
+		return exitProblems
+	}
+
+	if errors.Cause(err) == storage.ErrRegistryNotFound {
+		return exitNotFound
+	}
+
+	switch client.StatusCode(err) {
+	case http.StatusNotFound:
+		return exitNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return exitAuth
+	}
+
+	return exitError
+}
+
+// reference identifies a repository, or an image in it by tag or digest, e.g. registry/team/app:1.0.
+type reference struct {
+	Registry   string
+	Repository string
+	// Reference is the tag or digest, or empty.
+	Reference string
+}
+
+func parseReference(s string) (reference, error) {
+	i := strings.Index(s, "/")
+
+	if i <= 0 || i == len(s)-1 {
+		return reference{}, usageError(fmt.Sprintf("%q is not of the form <registry>/<repository>", s))
+	}
+
+	ref := reference{Registry: s[:i], Repository: s[i+1:]}
+
+	if j := strings.Index(ref.Repository, "@"); j >= 0 {
+		ref.Repository, ref.Reference = ref.Repository[:j], ref.Repository[j+1:]
+	} else if j := strings.LastIndex(ref.Repository, ":"); j > strings.LastIndex(ref.Repository, "/") {
+		ref.Repository, ref.Reference = ref.Repository[:j], ref.Repository[j+1:]
+	}
+
+	return ref, nil
+}
+
+func (r reference) String() string {
+	s := r.Registry + "/" + r.Repository
+
+	if strings.Contains(r.Reference, ":") {
+		return s + "@" + r.Reference
+	} else if r.Reference != "" {
+		return s + ":" + r.Reference
+	}
+
+	return s
+}
+
+// image parses a reference to an image, which must include a tag or digest.
+func image(s string) (reference, error) {
+	ref, err := parseReference(s)
+
+	if err == nil && ref.Reference == "" {
+		err = usageError(fmt.Sprintf("%q does not include a tag or digest", s))
+	}
+
+	return ref, err
+}
+
+// repository parses a reference to a repository, which must not include a tag or digest.
+func repository(s string) (reference, error) {
+	ref, err := parseReference(s)
+
+	if err == nil && ref.Reference != "" {
+		err = usageError(fmt.Sprintf("%q must not include a tag or digest", s))
+	}
+
+	return ref, err
+}
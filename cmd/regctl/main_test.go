@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories":["team/app","web"]}`)
+		case "/v2/team/app/tags/list":
+			fmt.Fprint(w, `{"name":"team/app","tags":["1.0","latest"]}`)
+		case "/v2/private/tags/list":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "regctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := filepath.Join(dir, "config.yaml")
+	content := fmt.Sprintf("registries:\n  - name: hub\n    url: %s\n  - name: mirror\n    url: %s\n", srv.URL, srv.URL)
+	if err := ioutil.WriteFile(cfg, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("ls registries", runs([]string{"-config", cfg, "ls", "registries"}, exitOK, "NAME    URL\nhub     "+srv.URL+"\nmirror  "+srv.URL+"\n"))
+	t.Run("ls tags json", runs([]string{"ls", "tags", "hub/team/app", "-config", cfg, "-o", "json"}, exitOK, `"name": "latest"`))
+	t.Run("ls repos template", runs([]string{"-config", cfg, "-o", "template={{.Registry}}/{{.Name}}", "ls", "repos", "hub"}, exitOK, "hub/team/app\nhub/web\n"))
+	t.Run("unknown registry", runs([]string{"-config", cfg, "ls", "repos", "other"}, exitNotFound, ""))
+	t.Run("unknown repository", runs([]string{"-config", cfg, "ls", "tags", "hub/missing"}, exitNotFound, ""))
+	t.Run("unauthorized", runs([]string{"-config", cfg, "ls", "tags", "hub/private"}, exitAuth, ""))
+	t.Run("missing tag", runs([]string{"-config", cfg, "inspect", "hub/team/app"}, exitUsage, ""))
+	t.Run("complete registries", runs([]string{"-config", cfg, completeCommand, "regctl inspect m"}, exitOK, "mirror/\n"))
+	t.Run("complete tags", runs([]string{completeCommand, "regctl -config " + cfg + " copy hub/team/app:l"}, exitOK, "hub/team/app:latest\n"))
+}
+
+func runs(args []string, code int, output string) func(*testing.T) {
+	return func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		if c := run(args, &stdout, &stderr); c != code {
+			t.Errorf("expected exit code %d, got %d: %s", code, c, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), output) {
+			t.Errorf("expected output containing %q, got %q", output, stdout.String())
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	for s, want := range map[string]reference{
+		"hub/app":                  {"hub", "app", ""},
+		"hub/team/app:1.0":         {"hub", "team/app", "1.0"},
+		"hub:5000/app":             {"hub:5000", "app", ""},
+		"hub/app@sha256:abc":       {"hub", "app", "sha256:abc"},
+		"hub/team/app:1.0@sha256:": {"hub", "team/app:1.0", "sha256:"},
+	} {
+		ref, err := parseReference(s)
+		if err != nil || ref != want {
+			t.Errorf("%s: expected %+v, got %+v (%v)", s, want, ref, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+)
+
+// printer writes the results of a command as a table, as JSON, or by executing a template for each result.
+type printer struct {
+	w    io.Writer
+	json bool
+	tmpl *template.Template
+}
+
+func newPrinter(w io.Writer, output string) (*printer, error) {
+	p := &printer{w: w}
+
+	switch {
+	case output == "table":
+	case output == "json":
+		p.json = true
+	case strings.HasPrefix(output, "template="):
+		t, err := template.New("output").Parse(strings.TrimPrefix(output, "template="))
+
+		if err != nil {
+			return nil, usageError(fmt.Sprintf("invalid template: %v", err))
+		}
+
+		p.tmpl = t
+	default:
+		return nil, usageError(fmt.Sprintf("unknown output format %q", output))
+	}
+
+	return p, nil
+}
+
+// list prints items as rows of a table with the given header, a JSON array, or a line per item.
+func (p *printer) list(items []interface{}, header []string, row func(interface{}) []string) error {
+	switch {
+	case p.json:
+		if items == nil {
+			items = []interface{}{}
+		}
+		return p.encode(items)
+	case p.tmpl != nil:
+		return p.execute(items)
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+
+	for _, item := range items {
+		fmt.Fprintln(tw, strings.Join(row(item), "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// object prints a single item as a table of its fields, a JSON object, or a single line.
+func (p *printer) object(item interface{}, fields [][2]string) error {
+	switch {
+	case p.json:
+		return p.encode(item)
+	case p.tmpl != nil:
+		return p.execute([]interface{}{item})
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 4, 2, ' ', 0)
+
+	for _, f := range fields {
+		fmt.Fprintf(tw, "%s:\t%s\n", f[0], f[1])
+	}
+
+	return tw.Flush()
+}
+
+func (p *printer) encode(v interface{}) error {
+	enc := json.NewEncoder(p.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (p *printer) execute(items []interface{}) error {
+	for _, item := range items {
+		if err := p.tmpl.Execute(p.w, item); err != nil {
+			return err
+		}
+		fmt.Fprintln(p.w)
+	}
+	return nil
+}
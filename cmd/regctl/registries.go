@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/config"
+	"github.com/mikaellindemann/registryfrontend/storage"
+)
+
+// openRegistries returns the registries known to the frontend: those persisted by the file backend,
+// overridden by those of the configuration file, which are in turn overridden by those of the environment.
+// The storage file is only read, registries are never written back to it.
+func openRegistries(configFile string, stderr io.Writer) (registryfrontend.Storage, error) {
+	cfg, err := config.Load(configFile, os.Environ())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if path := cfg.Credentials.DockerConfigFile; path != "" {
+		dockerConfig, err := client.LoadDockerConfig(path)
+
+		if err != nil {
+			return nil, err
+		}
+
+		client.SetCredentialProvider(dockerConfig, func(err error) {
+			fmt.Fprintf(stderr, "regctl: %v\n", err)
+		})
+	}
+
+	st := storage.NewInMemoryStorage()
+
+	if cfg.Storage.Backend == "file" {
+		if err := copyRegistries(cfg.Storage, st); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, r := range cfg.Registries {
+		reg, err := r.Registry(i)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := st.Add(reg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Like the frontend, invalid registries of the environment are skipped instead of failing.
+	for _, r := range cfg.EnvRegistries {
+		reg, err := r.Registry(0)
+
+		if err == nil {
+			err = st.Add(reg)
+		}
+
+		if err != nil {
+			fmt.Fprintf(stderr, "regctl: skipping registry from %s: %v\n", r.Env, err)
+		}
+	}
+
+	return st, nil
+}
+
+func copyRegistries(c config.Storage, dst registryfrontend.Storage) error {
+	keys, err := c.Keyring()
+
+	if err != nil {
+		return err
+	}
+
+	src, err := storage.ReadFileStorage(c.File, keys)
+
+	if err != nil {
+		return err
+	}
+
+	clients, err := src.Registries()
+
+	if err != nil {
+		return err
+	}
+
+	for _, c := range clients {
+		reg, err := src.Lookup(c.Name())
+
+		if err != nil {
+			return err
+		}
+
+		if err := dst.Add(reg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,261 @@
+// Package config reads the configuration of the frontend from a YAML file,
+// with overrides from environment variables.
+//
+// Every setting that can be overridden has an env tag naming its environment variable.
+// Lists are given as comma separated values, and booleans are true when set to anything
+// but a false value accepted by strconv.ParseBool.
+package config
+
+import (
+	"io/ioutil"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the configuration of the frontend.
+// BasePath serves the frontend below a path, e.g. /registry, and TrustForwardedHeaders
+// honors the X-Forwarded-Prefix, X-Forwarded-Proto and X-Forwarded-For headers set by a reverse proxy.
+type Config struct {
+	Listen                string          `yaml:"listen" env:"LISTEN_ADDRESS"`
+	Environment           string          `yaml:"environment" env:"ENVIRONMENT"`
+	BasePath              string          `yaml:"base_path" env:"BASE_PATH"`
+	TrustForwardedHeaders bool            `yaml:"trust_forwarded_headers" env:"TRUST_FORWARDED_HEADERS"`
+	TLS                   TLS             `yaml:"tls"`
+	Log                   Log             `yaml:"log"`
+	Features              Features        `yaml:"features"`
+	Storage               Storage         `yaml:"storage"`
+	Auth                  Auth            `yaml:"auth"`
+	Authorization         Authorization   `yaml:"authorization"`
+	Audit                 Audit           `yaml:"audit"`
+	Cosign                Cosign          `yaml:"cosign"`
+	Vulnerabilities       Vulnerabilities `yaml:"vulnerabilities"`
+	Credentials           Credentials     `yaml:"credentials"`
+	Registries            []Registry      `yaml:"registries"`
+
+	// EnvRegistries are the registries given by environment variables.
+	// Unlike the registries of the configuration file they are not validated,
+	// invalid registries are instead rejected when added to the storage.
+	EnvRegistries []Registry `yaml:"-"`
+}
+
+// TLS makes the frontend serve https. The certificate and key are reloaded when the files change.
+type TLS struct {
+	CertFile string `yaml:"cert_file" env:"TLS_CERT_FILE"`
+	KeyFile  string `yaml:"key_file" env:"TLS_KEY_FILE"`
+	// RedirectListen is an optional address to listen on for http requests, which are redirected to https.
+	RedirectListen string `yaml:"redirect_listen" env:"TLS_REDIRECT_LISTEN_ADDRESS"`
+}
+
+type Log struct {
+	// Level is one of the logrus levels, e.g. debug, info or warn.
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+	// Format is either json or text.
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+}
+
+type Features struct {
+	DisableAddRemove bool `yaml:"disable_add_remove" env:"REGISTRY_DISABLE_ADD_REMOVE"`
+}
+
+type Storage struct {
+	// Backend is either memory or file. Defaults to file when File is set.
+	Backend string `yaml:"backend" env:"STORAGE_BACKEND"`
+	File    string `yaml:"file" env:"STORAGE_FILE"`
+	Key     string `yaml:"key" env:"CREDENTIALS_KEY"`
+	KeyFile string `yaml:"key_file" env:"CREDENTIALS_KEY_FILE"`
+}
+
+// Keyring returns the keys encrypting the credentials persisted by the file backend.
+func (s Storage) Keyring() (*secret.Keyring, error) {
+	if s.Key != "" {
+		return secret.ParseKeyring(s.Key)
+	}
+	return secret.LoadKeyring(s.KeyFile)
+}
+
+type Auth struct {
+	HtpasswdFile string     `yaml:"htpasswd_file" env:"AUTH_HTPASSWD_FILE"`
+	Header       HeaderAuth `yaml:"header"`
+	OIDC         OIDCAuth   `yaml:"oidc"`
+	ClientCert   ClientCert `yaml:"client_cert"`
+	SessionTTL   Duration   `yaml:"session_ttl" env:"AUTH_SESSION_TTL"`
+}
+
+// ClientCert authenticates users by client certificates issued by the CAs in CAFile.
+// It requires the frontend to serve https.
+type ClientCert struct {
+	CAFile string `yaml:"ca_file" env:"AUTH_CLIENT_CERT_CA_FILE"`
+	// Required rejects connections without a valid client certificate.
+	// Otherwise users without a certificate can use the other authentication methods.
+	Required bool `yaml:"required" env:"AUTH_CLIENT_CERT_REQUIRED"`
+	// Username is the certificate field used as user name, either cn (the subject common name) or email.
+	Username string `yaml:"username" env:"AUTH_CLIENT_CERT_USERNAME"`
+}
+
+type HeaderAuth struct {
+	User           string   `yaml:"user" env:"AUTH_HEADER_USER"`
+	Groups         string   `yaml:"groups" env:"AUTH_HEADER_GROUPS"`
+	TrustedProxies []string `yaml:"trusted_proxies" env:"AUTH_HEADER_TRUSTED_PROXIES"`
+}
+
+type OIDCAuth struct {
+	Issuer        string   `yaml:"issuer" env:"AUTH_OIDC_ISSUER"`
+	ClientID      string   `yaml:"client_id" env:"AUTH_OIDC_CLIENT_ID"`
+	ClientSecret  string   `yaml:"client_secret" env:"AUTH_OIDC_CLIENT_SECRET"`
+	RedirectURL   string   `yaml:"redirect_url" env:"AUTH_OIDC_REDIRECT_URL"`
+	Scopes        []string `yaml:"scopes" env:"AUTH_OIDC_SCOPES"`
+	UsernameClaim string   `yaml:"username_claim" env:"AUTH_OIDC_USERNAME_CLAIM"`
+	GroupsClaim   string   `yaml:"groups_claim" env:"AUTH_OIDC_GROUPS_CLAIM"`
+}
+
+type Authorization struct {
+	PolicyFile string `yaml:"policy_file" env:"AUTHZ_POLICY_FILE"`
+}
+
+type Audit struct {
+	File string `yaml:"file" env:"AUDIT_LOG_FILE"`
+}
+
+// Cosign configures the public keys image signatures are verified against.
+type Cosign struct {
+	PublicKeys []string `yaml:"public_keys" env:"COSIGN_PUBLIC_KEYS"`
+}
+
+// Vulnerabilities configures where vulnerability reports of scanners such as Trivy and Grype are read from.
+type Vulnerabilities struct {
+	Directory string `yaml:"directory" env:"VULNERABILITY_REPORTS_DIR"`
+}
+
+// Credentials configures where the credentials of registries without a user or password are looked up.
+type Credentials struct {
+	// DockerConfigFile is a configuration file of the Docker CLI, e.g. ~/.docker/config.json,
+	// with the credentials of registries or the credential helpers storing them.
+	DockerConfigFile string `yaml:"docker_config_file" env:"DOCKER_CONFIG_FILE"`
+	// ImportFile is a Docker configuration offered for import in the frontend, e.g. a mounted Kubernetes imagePullSecret.
+	ImportFile string `yaml:"import_file" env:"DOCKER_CONFIG_IMPORT_FILE"`
+}
+
+type Registry struct {
+	Name    string       `yaml:"name"`
+	URL     string       `yaml:"url"`
+	Auth    RegistryAuth `yaml:"auth"`
+	TLS     RegistryTLS  `yaml:"tls"`
+	Timeout Duration     `yaml:"timeout"`
+	// Kind is the kind of hosted registry, e.g. dockerhub, ghcr, quay or gitlab. The URL defaults to the registry of the kind.
+	Kind      string `yaml:"kind"`
+	Namespace string `yaml:"namespace"`
+	API       string `yaml:"api"`
+	// Repositories are listed instead of asking the registry, for registries that do not list their repositories.
+	Repositories []string `yaml:"repositories"`
+
+	// Env is the prefix of the environment variables the registry was read from, e.g. REGISTRY_1.
+	Env string `yaml:"-"`
+
+	// envErrs are the environment variables of the registry that could not be parsed.
+	envErrs []FieldError
+}
+
+type RegistryAuth struct {
+	User         string `yaml:"user"`
+	UserFile     string `yaml:"user_file"`
+	Password     string `yaml:"password"`
+	PasswordFile string `yaml:"password_file"`
+	// DockerConfig uses the credentials of credentials.docker_config_file for the host of the registry,
+	// if it has no user or password.
+	DockerConfig bool `yaml:"docker_config"`
+}
+
+type RegistryTLS struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// Duration is a time.Duration written like "30s" or "12h".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(v)
+	return nil
+}
+
+// Default returns the configuration used when no configuration file is given.
+func Default() Config {
+	return Config{
+		Listen: ":8080",
+		Log: Log{
+			Level:  "debug",
+			Format: "json",
+		},
+		Auth: Auth{
+			SessionTTL: Duration(12 * time.Hour),
+		},
+	}
+}
+
+// Parse reads a configuration in YAML format on top of the defaults.
+// Unknown keys are reported as errors.
+func Parse(content []byte) (Config, error) {
+	c := Default()
+
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return c, errors.Wrap(err, "could not parse configuration")
+	}
+
+	return c, nil
+}
+
+// Load reads, applies environment overrides to, and validates the configuration file at path.
+// If path is empty, the defaults are used.
+func Load(path string, environ []string) (Config, error) {
+	c := Default()
+
+	if path != "" {
+		content, err := ioutil.ReadFile(path)
+
+		if err != nil {
+			return c, errors.Wrap(err, "failed reading configuration file")
+		}
+
+		if c, err = Parse(content); err != nil {
+			return c, errors.Wrap(err, path)
+		}
+	}
+
+	if err := c.ApplyEnv(environ); err != nil {
+		return c, err
+	}
+
+	c.setDefaults()
+
+	return c, c.Validate()
+}
+
+func (c *Config) setDefaults() {
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = "memory"
+		if c.Storage.File != "" {
+			c.Storage.Backend = "file"
+		}
+	}
+
+	if c.Auth.ClientCert.Username == "" {
+		c.Auth.ClientCert.Username = "cn"
+	}
+}
@@ -0,0 +1,210 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testConfig = `
+listen: ":9090"
+log:
+  level: info
+registries:
+  - name: internal
+    url: https://registry.internal
+    auth:
+      user: ci
+      password: hunter2
+    timeout: 10s
+  - name: bad name
+    url: registry.internal
+`
+
+func TestParseAndEnv(t *testing.T) {
+	c, err := Parse([]byte(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = c.ApplyEnv([]string{
+		"LOG_LEVEL=warn",
+		"REGISTRY_DISABLE_ADD_REMOVE=",
+		"AUTH_HEADER_TRUSTED_PROXIES=10.0.0.0/8, 127.0.0.1",
+		"AUTH_SESSION_TTL=1h",
+		"REGISTRY_NAME=internal",
+		"REGISTRY_URL=https://other.internal",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Listen != ":9090" || c.Log.Level != "warn" || c.Log.Format != "json" {
+		t.Errorf("unexpected settings %+v", c)
+	}
+	if !c.Features.DisableAddRemove {
+		t.Error("expected add/remove to be disabled")
+	}
+	if len(c.Auth.Header.TrustedProxies) != 2 || time.Duration(c.Auth.SessionTTL) != time.Hour {
+		t.Errorf("unexpected auth settings %+v", c.Auth)
+	}
+	if len(c.EnvRegistries) != 1 || c.EnvRegistries[0].URL != "https://other.internal" {
+		t.Errorf("expected the registry from REGISTRY_URL, got %+v", c.EnvRegistries)
+	}
+	if time.Duration(c.Registries[1].Timeout) != 0 {
+		t.Errorf("unexpected timeout %v", c.Registries[1].Timeout)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c, err := Parse([]byte(testConfig + "storage:\n  backend: file\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.setDefaults()
+
+	err = c.Validate()
+	verr, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("expected a ValidationError, got %v", err)
+	}
+
+	keys := map[string]bool{}
+	for _, fe := range verr {
+		keys[fe.Key] = true
+	}
+
+	for _, k := range []string{"registries[1].name", "registries[1].url", "storage.file", "storage.key"} {
+		if !keys[k] {
+			t.Errorf("expected an error for %s, got %v", k, err)
+		}
+	}
+	if keys["registries[0].name"] || keys["registries[0].url"] {
+		t.Errorf("unexpected error for the valid registry: %v", err)
+	}
+}
+
+func TestInvalidEnvBool(t *testing.T) {
+	c := Default()
+	if err := c.ApplyEnv([]string{"TRUST_FORWARDED_HEADERS=nope"}); err == nil {
+		t.Error("expected an error for the invalid boolean")
+	}
+}
+
+func TestParseUnknownKey(t *testing.T) {
+	if _, err := Parse([]byte("listen: :8080\nregistries:\n  - name: a\n    uri: http://a\n")); err == nil {
+		t.Error("expected an error for the unknown key")
+	}
+}
+
+func TestIndexedEnvRegistries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	password := filepath.Join(dir, "password")
+	if err := ioutil.WriteFile(password, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Default()
+	err = c.ApplyEnv([]string{
+		"REGISTRY_10_NAME=last",
+		"REGISTRY_10_URL=https://last.internal",
+		"REGISTRY_2_NAME=second",
+		"REGISTRY_2_URL=https://second.internal",
+		"REGISTRY_2_AUTH_BASIC_USER=frontend",
+		"REGISTRY_2_AUTH_BASIC_PASSWORD_FILE=" + password,
+		"REGISTRY_2_TIMEOUT=5s",
+		"REGISTRY_2_AUTH_DOCKER_CONFIG=true",
+		"REGISTRY_3_NAME=in valid",
+		"REGISTRY_3_URL=https://invalid.internal",
+		"REGISTRY_4_URL=https://unnamed.internal",
+		"REGISTRY_5_NAME=missing",
+		"REGISTRY_5_URL=https://missing.internal",
+		"REGISTRY_5_AUTH_BASIC_PASSWORD_FILE=" + filepath.Join(dir, "missing"),
+		"REGISTRY_6_NAME=typo",
+		"REGISTRY_6_URL=https://typo.internal",
+		"REGISTRY_6_TLS_INSECURE_SKIP_VERIFY=off",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var envs []string
+	for _, r := range c.EnvRegistries {
+		envs = append(envs, r.Env)
+	}
+	if strings.Join(envs, ",") != "REGISTRY_2,REGISTRY_3,REGISTRY_4,REGISTRY_5,REGISTRY_6,REGISTRY_10" {
+		t.Fatalf("unexpected registries %v", envs)
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		reg, err := c.EnvRegistries[0].Registry(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reg.User != "frontend" || reg.Password != "s3cret" || reg.Timeout != 5*time.Second || !reg.DockerConfig {
+			t.Errorf("unexpected registry %#v", reg)
+		}
+	})
+
+	for i, key := range []string{"REGISTRY_3_NAME", "REGISTRY_4_NAME", "REGISTRY_5_AUTH_BASIC_PASSWORD_FILE", "REGISTRY_6_TLS_INSECURE_SKIP_VERIFY"} {
+		t.Run(key, rejected(c.EnvRegistries[i+1], key))
+	}
+}
+
+func rejected(r Registry, key string) func(*testing.T) {
+	return func(t *testing.T) {
+		_, err := r.Registry(0)
+		if err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("expected an error for %s, got %v", key, err)
+		}
+	}
+}
+
+func TestHostedRegistries(t *testing.T) {
+	c := Default()
+	err := c.ApplyEnv([]string{
+		"REGISTRY_1_NAME=hub",
+		"REGISTRY_1_KIND=dockerhub",
+		"REGISTRY_1_NAMESPACE=acme",
+		"REGISTRY_2_NAME=lab",
+		"REGISTRY_2_KIND=gitlab",
+		"REGISTRY_2_URL=https://registry.gitlab.example.com",
+		"REGISTRY_2_REPOSITORIES=group/app, group/worker",
+		"REGISTRY_3_NAME=ghcr",
+		"REGISTRY_3_KIND=ghcr",
+		"REGISTRY_4_NAME=other",
+		"REGISTRY_4_KIND=ecr",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("default URL", func(t *testing.T) {
+		reg, err := c.EnvRegistries[0].Registry(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reg.Url != "https://registry-1.docker.io" || reg.Kind != "dockerhub" || reg.Namespace != "acme" {
+			t.Errorf("unexpected registry %#v", reg)
+		}
+	})
+	t.Run("repositories", func(t *testing.T) {
+		reg, err := c.EnvRegistries[1].Registry(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(reg.Repositories, ",") != "group/app,group/worker" {
+			t.Errorf("unexpected repositories %v", reg.Repositories)
+		}
+	})
+	t.Run("REGISTRY_3_NAMESPACE", rejected(c.EnvRegistries[2], "REGISTRY_3_NAMESPACE"))
+	t.Run("REGISTRY_4_KIND", rejected(c.EnvRegistries[3], "REGISTRY_4_KIND"))
+}
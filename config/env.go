@@ -0,0 +1,190 @@
+package config
+
+import (
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ApplyEnv overrides settings with the environment variables named by their env tags.
+// environ is in the format returned by os.Environ.
+func (c *Config) ApplyEnv(environ []string) error {
+	env := make(map[string]string, len(environ))
+
+	for _, kv := range environ {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(c).Elem(), env); err != nil {
+		return err
+	}
+
+	c.applyEnvRegistries(env)
+	return nil
+}
+
+var durationType = reflect.TypeOf(Duration(0))
+
+func applyEnv(v reflect.Value, env map[string]string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+
+		if f.Type.Kind() == reflect.Struct {
+			if err := applyEnv(fv, env); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := f.Tag.Get("env")
+		value, ok := env[name]
+
+		if name == "" || !ok {
+			continue
+		}
+
+		switch {
+		case f.Type == durationType:
+			d, err := time.ParseDuration(value)
+
+			if err != nil {
+				return errors.Wrapf(err, "environment variable %s", name)
+			}
+
+			fv.SetInt(int64(d))
+		case f.Type.Kind() == reflect.String:
+			fv.SetString(value)
+		case f.Type.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+
+			// Setting REGISTRY_DISABLE_ADD_REMOVE to anything else than a boolean, e.g. empty, has always disabled it.
+			if err != nil && name != "REGISTRY_DISABLE_ADD_REMOVE" {
+				return errors.Wrapf(err, "environment variable %s", name)
+			}
+
+			fv.SetBool(err != nil || b)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.String:
+			fv.Set(reflect.ValueOf(splitList(value)))
+		}
+	}
+
+	return nil
+}
+
+var indexedRegistry = regexp.MustCompile(`^REGISTRY_(\d+)_`)
+
+// applyEnvRegistries adds the registry configured by REGISTRY_NAME and REGISTRY_URL,
+// followed by the registries configured by REGISTRY_<N>_NAME, REGISTRY_<N>_URL and so on, ordered by N.
+func (c *Config) applyEnvRegistries(env map[string]string) {
+	if r := envRegistry(env, "REGISTRY"); r.Name != "" && (r.URL != "" || r.Kind != "") {
+		c.EnvRegistries = append(c.EnvRegistries, r)
+	}
+
+	var indices []int
+	seen := make(map[int]bool)
+
+	for name := range env {
+		m := indexedRegistry.FindStringSubmatch(name)
+
+		if m == nil {
+			continue
+		}
+
+		if n, err := strconv.Atoi(m[1]); err == nil && !seen[n] {
+			seen[n] = true
+			indices = append(indices, n)
+		}
+	}
+
+	sort.Ints(indices)
+
+	// Unlike the single registry above, incomplete registries are kept, so that they are reported as rejected.
+	for _, n := range indices {
+		c.EnvRegistries = append(c.EnvRegistries, envRegistry(env, "REGISTRY_"+strconv.Itoa(n)))
+	}
+}
+
+// envRegistry reads the registry configured by the environment variables starting with prefix.
+func envRegistry(env map[string]string, prefix string) Registry {
+	get := func(field string) string {
+		return env[prefix+"_"+envKeys[field]]
+	}
+
+	r := Registry{
+		Name: get("name"),
+		URL:  get("url"),
+		Auth: RegistryAuth{
+			User:         env[prefix+"_AUTH_BASIC_USER"],
+			UserFile:     get("auth.user_file"),
+			Password:     env[prefix+"_AUTH_BASIC_PASSWORD"],
+			PasswordFile: get("auth.password_file"),
+		},
+		TLS: RegistryTLS{
+			CAFile:   get("tls.ca_file"),
+			CertFile: get("tls.cert_file"),
+			KeyFile:  get("tls.key_file"),
+		},
+		Kind:      get("kind"),
+		Namespace: get("namespace"),
+		API:       get("api"),
+		Env:       prefix,
+	}
+
+	if v := get("repositories"); v != "" {
+		r.Repositories = splitList(v)
+	}
+
+	if v := get("auth.docker_config"); v != "" {
+		b, err := strconv.ParseBool(v)
+
+		if err != nil {
+			r.envErrs = append(r.envErrs, FieldError{Key: r.key(0, "auth.docker_config"), Message: err.Error()})
+		}
+
+		r.Auth.DockerConfig = err == nil && b
+	}
+
+	if v := get("tls.insecure_skip_verify"); v != "" {
+		b, err := strconv.ParseBool(v)
+
+		if err != nil {
+			r.envErrs = append(r.envErrs, FieldError{Key: r.key(0, "tls.insecure_skip_verify"), Message: err.Error()})
+		}
+
+		r.TLS.InsecureSkipVerify = err == nil && b
+	}
+
+	if v := get("timeout"); v != "" {
+		d, err := time.ParseDuration(v)
+
+		if err != nil {
+			r.envErrs = append(r.envErrs, FieldError{Key: r.key(0, "timeout"), Message: err.Error()})
+		}
+
+		r.Timeout = Duration(d)
+	}
+
+	return r
+}
+
+func splitList(s string) []string {
+	res := []string{}
+
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+
+	return res
+}
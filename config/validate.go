@@ -0,0 +1,270 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// FieldError describes an invalid setting, identified by its key, e.g. registries[1].url.
+type FieldError struct {
+	Key     string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+// ValidationError lists every invalid setting of a configuration.
+type ValidationError []FieldError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+
+	return "invalid configuration:\n  " + strings.Join(msgs, "\n  ")
+}
+
+// Validate checks the configuration, returning a ValidationError if any settings are invalid.
+func (c Config) Validate() error {
+	var errs ValidationError
+
+	add := func(key, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Listen == "" {
+		add("listen", "must not be empty")
+	}
+
+	if p := c.BasePath; p != "" && (!strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.ContainsAny(p, "\\?#")) {
+		add("base_path", "must be a path starting with /, was %q", p)
+	}
+
+	if _, err := logrus.ParseLevel(c.Log.Level); err != nil {
+		add("log.level", "unknown level %q", c.Log.Level)
+	}
+
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		add("log.format", "must be json or text, was %q", c.Log.Format)
+	}
+
+	switch c.Storage.Backend {
+	case "memory":
+	case "file":
+		if c.Storage.File == "" {
+			add("storage.file", "is required by the file backend")
+		}
+		if c.Storage.Key == "" && c.Storage.KeyFile == "" {
+			add("storage.key", "storage.key or storage.key_file is required to encrypt stored credentials")
+		}
+	default:
+		add("storage.backend", "must be memory or file, was %q", c.Storage.Backend)
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		add("tls", "cert_file and key_file must be given together")
+	}
+
+	if c.TLS.RedirectListen != "" && c.TLS.CertFile == "" {
+		add("tls.redirect_listen", "requires tls.cert_file and tls.key_file")
+	}
+
+	if c.Auth.ClientCert.CAFile != "" {
+		if c.TLS.CertFile == "" {
+			add("auth.client_cert.ca_file", "requires tls.cert_file and tls.key_file")
+		}
+		if u := c.Auth.ClientCert.Username; u != "cn" && u != "email" {
+			add("auth.client_cert.username", "must be cn or email, was %q", u)
+		}
+	}
+
+	if c.Auth.SessionTTL <= 0 {
+		add("auth.session_ttl", "must be positive")
+	}
+
+	if c.Auth.OIDC.Issuer != "" {
+		if c.Auth.OIDC.ClientID == "" {
+			add("auth.oidc.client_id", "is required when auth.oidc.issuer is set")
+		}
+		if c.Auth.OIDC.RedirectURL == "" {
+			add("auth.oidc.redirect_url", "is required when auth.oidc.issuer is set")
+		}
+	}
+
+	names := make(map[string]int)
+
+	for i, r := range c.Registries {
+		if j, ok := names[r.Name]; ok {
+			add(r.key(i, "name"), "%q is already used by registries[%d]", r.Name, j)
+		} else {
+			names[r.Name] = i
+		}
+
+		errs = append(errs, r.validate(i)...)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// envKeys names the environment variables of the registry settings, without the REGISTRY_<N>_ prefix.
+var envKeys = map[string]string{
+	"name":                     "NAME",
+	"url":                      "URL",
+	"timeout":                  "TIMEOUT",
+	"auth.user_file":           "AUTH_BASIC_USER_FILE",
+	"auth.password_file":       "AUTH_BASIC_PASSWORD_FILE",
+	"auth.docker_config":       "AUTH_DOCKER_CONFIG",
+	"tls":                      "TLS_CERT_FILE",
+	"tls.ca_file":              "TLS_CA_FILE",
+	"tls.cert_file":            "TLS_CERT_FILE",
+	"tls.key_file":             "TLS_KEY_FILE",
+	"tls.insecure_skip_verify": "TLS_INSECURE_SKIP_VERIFY",
+	"kind":                     "KIND",
+	"namespace":                "NAMESPACE",
+	"api":                      "API",
+	"repositories":             "REPOSITORIES",
+}
+
+// key returns the key of a registry setting, e.g. registries[1].url,
+// or the environment variable for registries given by environment variables, e.g. REGISTRY_1_URL.
+func (r Registry) key(i int, field string) string {
+	if r.Env != "" {
+		return r.Env + "_" + envKeys[field]
+	}
+	return fmt.Sprintf("registries[%d].%s", i, field)
+}
+
+func (r Registry) validate(i int) []FieldError {
+	errs := append([]FieldError(nil), r.envErrs...)
+
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Key: r.key(i, field), Message: fmt.Sprintf(format, args...)})
+	}
+
+	if storage.ValidateName(r.Name) != nil {
+		add("name", "%q may only contain the characters a-z, A-Z, 0-9, - and _", r.Name)
+	}
+
+	kindErr := client.ValidateKind(r.Kind)
+
+	if kindErr != nil {
+		add("kind", "%v", kindErr)
+	}
+
+	// Hosted registries default to the URL of the registry of their kind.
+	if defaulted := r.URL == "" && kindErr == nil && client.DefaultURL(r.Kind) != ""; !defaulted && !isHTTPURL(r.URL) {
+		add("url", "must be an absolute http or https URL, was %q", r.URL)
+	}
+
+	if r.API != "" && !isHTTPURL(r.API) {
+		add("api", "must be an absolute http or https URL, was %q", r.API)
+	}
+
+	if r.Kind != "" && r.Namespace == "" && r.Auth.User == "" && r.Auth.UserFile == "" && len(r.Repositories) == 0 {
+		add("namespace", "is required to list the repositories of %s registries without a user or repositories", r.Kind)
+	}
+
+	if r.Auth.User != "" && r.Auth.UserFile != "" {
+		add("auth.user_file", "cannot be combined with auth.user")
+	}
+
+	if r.Auth.Password != "" && r.Auth.PasswordFile != "" {
+		add("auth.password_file", "cannot be combined with auth.password")
+	}
+
+	if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		add("tls", "cert_file and key_file must be given together")
+	}
+
+	if r.Timeout < 0 {
+		add("timeout", "must not be negative")
+	}
+
+	return errs
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// Registry reads the files referenced by the registry configuration,
+// and returns the registry to add to the storage.
+// i is the index of the registry, used to point to the offending key on errors.
+func (r Registry) Registry(i int) (registryfrontend.Registry, error) {
+	if errs := r.validate(i); len(errs) > 0 {
+		return registryfrontend.Registry{}, ValidationError(errs)
+	}
+
+	reg := registryfrontend.Registry{
+		Name:     r.Name,
+		Url:      r.URL,
+		User:     r.Auth.User,
+		Password: r.Auth.Password,
+		Timeout:  time.Duration(r.Timeout),
+		TLS: registryfrontend.TLSOptions{
+			InsecureSkipVerify: r.TLS.InsecureSkipVerify,
+		},
+		Kind:         r.Kind,
+		Namespace:    r.Namespace,
+		API:          r.API,
+		Repositories: r.Repositories,
+		DockerConfig: r.Auth.DockerConfig,
+	}
+
+	if reg.Url == "" {
+		reg.Url = client.DefaultURL(r.Kind)
+	}
+
+	files := []struct {
+		key  string
+		path string
+		dst  *string
+	}{
+		{"auth.user_file", r.Auth.UserFile, &reg.User},
+		{"auth.password_file", r.Auth.PasswordFile, &reg.Password},
+		{"tls.ca_file", r.TLS.CAFile, &reg.TLS.CACert},
+		{"tls.cert_file", r.TLS.CertFile, &reg.TLS.ClientCert},
+		{"tls.key_file", r.TLS.KeyFile, &reg.TLS.ClientKey},
+	}
+
+	for _, f := range files {
+		if f.path == "" {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(f.path)
+
+		if err != nil {
+			return reg, FieldError{Key: r.key(i, f.key), Message: err.Error()}
+		}
+
+		*f.dst = string(content)
+	}
+
+	if r.Auth.UserFile != "" {
+		reg.User = strings.TrimRight(reg.User, "\r\n")
+	}
+
+	if r.Auth.PasswordFile != "" {
+		reg.Password = strings.TrimRight(reg.Password, "\r\n")
+	}
+
+	return reg, nil
+}
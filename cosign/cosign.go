@@ -0,0 +1,300 @@
+// Package cosign finds the cosign signatures and attestations of images, and verifies them offline against public keys.
+//
+// Signatures and attestations are found by the tags cosign pushes them to, e.g. sha256-<hash>.sig and sha256-<hash>.att,
+// and by the referrers API of OCI 1.1 registries.
+// Keyless signatures are listed with the identity of their certificate, but cannot be verified offline.
+package cosign
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+)
+
+const (
+	simpleSigningMediaType  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	signatureAnnotation     = "dev.cosignproject.cosign/signature"
+	certificateAnnotation   = "dev.sigstore.cosign/certificate"
+	signatureArtifactType   = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	attestationArtifactType = "application/vnd.dev.cosign.artifact.att.v1+json"
+
+	// maxBlobSize limits the size of signature payloads and attestations read into memory.
+	maxBlobSize = 4 << 20
+)
+
+// artifactTag matches the tags cosign pushes signatures, attestations and SBOMs to.
+var artifactTag = regexp.MustCompile(`^sha256-[a-f0-9]{64}\.(sig|att|sbom)$`)
+
+// IsArtifactTag reports whether tag is one cosign pushes signatures, attestations or SBOMs to, e.g. sha256-<hash>.sig.
+func IsArtifactTag(tag string) bool {
+	return artifactTag.MatchString(tag)
+}
+
+// Status summarizes the signatures of an image.
+type Status string
+
+const (
+	// Verified images have at least one signature verified by a configured key.
+	Verified   Status = "verified"
+	Unverified Status = "unverified"
+	Unsigned   Status = "unsigned"
+)
+
+type Signature struct {
+	// Source is the tag the signature was found by, or referrers if it was found by the referrers API.
+	Source string
+	// Identity is the image reference the signature was created for.
+	Identity string
+	// Signer is the identity in the certificate of keyless signatures.
+	Signer string
+	// Key is the name of the key that verified the signature.
+	Key      string
+	Verified bool
+	// Problem explains why the signature is not verified.
+	Problem string
+}
+
+type Attestation struct {
+	Source        string
+	PredicateType string
+	Key           string
+	Verified      bool
+	Problem       string
+}
+
+type Result struct {
+	Signatures   []Signature
+	Attestations []Attestation
+}
+
+// Status is Verified if any signature is verified, Unverified if there are signatures that could not be verified,
+// and Unsigned if there are no signatures.
+func (r *Result) Status() Status {
+	for _, s := range r.Signatures {
+		if s.Verified {
+			return Verified
+		}
+	}
+
+	if len(r.Signatures) > 0 {
+		return Unverified
+	}
+
+	return Unsigned
+}
+
+// Verifier finds signatures and verifies them against its keys.
+type Verifier struct {
+	keys []PublicKey
+}
+
+func NewVerifier(keys []PublicKey) *Verifier {
+	return &Verifier{keys: keys}
+}
+
+// Discover finds and verifies the signatures of the image with digest d, and its attestations if attestations is true.
+func (v *Verifier) Discover(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest, attestations bool) (*Result, error) {
+	suffixes, types := []string{".sig"}, map[string]bool{signatureArtifactType: true}
+
+	if attestations {
+		suffixes, types[attestationArtifactType] = append(suffixes, ".att"), true
+	}
+
+	layers, err := client.Attached(ctx, c, repository, d, suffixes, types)
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+
+	for _, l := range layers {
+		switch {
+		case l.MediaType == simpleSigningMediaType:
+			res.Signatures = append(res.Signatures, v.signature(ctx, c, repository, d, l.Source, l.Descriptor))
+		case l.MediaType == client.DSSEMediaType && attestations:
+			res.Attestations = append(res.Attestations, v.attestation(ctx, c, repository, d, l.Source, l.Descriptor))
+		}
+	}
+
+	return res, nil
+}
+
+type simpleSigning struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest digest.Digest `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+func (v *Verifier) signature(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest, src string, layer registryfrontend.Descriptor) Signature {
+	s := Signature{Source: src}
+
+	if cert := layer.Annotations[certificateAnnotation]; cert != "" {
+		s.Signer = certificateIdentity(cert)
+	}
+
+	payload, err := client.ReadBlob(ctx, c, repository, layer, maxBlobSize)
+
+	if err != nil {
+		s.Problem = err.Error()
+		return s
+	}
+
+	p := simpleSigning{}
+
+	if err := json.Unmarshal(payload, &p); err != nil {
+		s.Problem = "invalid signature payload"
+		return s
+	}
+
+	s.Identity = p.Critical.Identity.DockerReference
+
+	if p.Critical.Image.DockerManifestDigest != d {
+		s.Problem = fmt.Sprintf("signs a different image, %s", p.Critical.Image.DockerManifestDigest)
+		return s
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(layer.Annotations[signatureAnnotation])
+
+	if err != nil || len(sig) == 0 {
+		s.Problem = "the signature is missing"
+		return s
+	}
+
+	s.Key, s.Verified = v.verify(payload, sig)
+
+	if !s.Verified {
+		s.Problem = v.problem(s.Signer)
+	}
+
+	return s
+}
+
+type envelope struct {
+	PayloadType string `json:"payloadType"`
+	Payload     string `json:"payload"`
+	Signatures  []struct {
+		Sig string `json:"sig"`
+	} `json:"signatures"`
+}
+
+type statement struct {
+	PredicateType string `json:"predicateType"`
+	Subject       []struct {
+		Digest map[string]string `json:"digest"`
+	} `json:"subject"`
+}
+
+func (v *Verifier) attestation(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest, src string, layer registryfrontend.Descriptor) Attestation {
+	a := Attestation{Source: src, PredicateType: layer.Annotations["predicateType"]}
+
+	content, err := client.ReadBlob(ctx, c, repository, layer, maxBlobSize)
+
+	if err != nil {
+		a.Problem = err.Error()
+		return a
+	}
+
+	env := envelope{}
+	st := statement{}
+
+	if err := json.Unmarshal(content, &env); err != nil {
+		a.Problem = "invalid attestation envelope"
+		return a
+	}
+
+	body, err := base64.StdEncoding.DecodeString(env.Payload)
+
+	if err != nil || json.Unmarshal(body, &st) != nil {
+		a.Problem = "invalid attestation statement"
+		return a
+	}
+
+	a.PredicateType = st.PredicateType
+
+	subject := false
+
+	for _, s := range st.Subject {
+		subject = subject || s.Digest[d.Algorithm().String()] == d.Encoded()
+	}
+
+	if !subject {
+		a.Problem = "attests a different image"
+		return a
+	}
+
+	// DSSE signs the pre-authentication encoding of the payload type and payload.
+	pae := []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(env.PayloadType), env.PayloadType, len(body), body))
+
+	for _, s := range env.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+
+		if err != nil {
+			continue
+		}
+
+		if a.Key, a.Verified = v.verify(pae, sig); a.Verified {
+			return a
+		}
+	}
+
+	a.Problem = v.problem(certificateIdentity(layer.Annotations[certificateAnnotation]))
+	return a
+}
+
+// verify returns the name of the key the signature was created by.
+func (v *Verifier) verify(payload, sig []byte) (string, bool) {
+	for _, k := range v.keys {
+		if k.verify(payload, sig) {
+			return k.Name, true
+		}
+	}
+	return "", false
+}
+
+func (v *Verifier) problem(signer string) string {
+	switch {
+	case signer != "":
+		return "keyless signatures cannot be verified offline"
+	case len(v.keys) == 0:
+		return "no public keys are configured"
+	}
+	return "not signed by any of the configured keys"
+}
+
+// certificateIdentity returns the email address or URI of a keyless signing certificate, or its subject.
+func certificateIdentity(certPEM string) string {
+	block, _ := pem.Decode([]byte(certPEM))
+
+	if block == nil {
+		return ""
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+
+	if err != nil {
+		return ""
+	}
+
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+
+	return cert.Subject.String()
+}
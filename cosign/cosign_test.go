@@ -0,0 +1,161 @@
+package cosign
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// fakeRegistry serves manifests and blobs of the repository app, keyed by reference or digest.
+type fakeRegistry struct {
+	manifests map[string][]byte
+	blobs     map[digest.Digest][]byte
+	referrers map[digest.Digest][]registryfrontend.Descriptor
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/v2/app/")
+
+	switch {
+	case strings.HasPrefix(p, "manifests/"):
+		m, ok := f.manifests[strings.TrimPrefix(p, "manifests/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+		w.Write(m)
+	case strings.HasPrefix(p, "blobs/"):
+		b, ok := f.blobs[digest.Digest(strings.TrimPrefix(p, "blobs/"))]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	case strings.HasPrefix(p, "referrers/"):
+		json.NewEncoder(w).Encode(map[string]interface{}{"manifests": f.referrers[digest.Digest(strings.TrimPrefix(p, "referrers/"))]})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// push stores a manifest with a layer holding content, tagged with tag if given, and returns its descriptor.
+func (f *fakeRegistry) push(tag, mediaType string, content []byte, annotations map[string]string) registryfrontend.Descriptor {
+	layer := registryfrontend.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(content), Size: int64(len(content)), Annotations: annotations}
+	f.blobs[layer.Digest] = content
+
+	m, _ := json.Marshal(map[string]interface{}{"layers": []registryfrontend.Descriptor{layer}})
+	d := digest.FromBytes(m)
+	f.manifests[d.String()] = m
+
+	if tag != "" {
+		f.manifests[tag] = m
+	}
+
+	return registryfrontend.Descriptor{Digest: d, Size: int64(len(m))}
+}
+
+func newKey(t *testing.T, name string) (*ecdsa.PrivateKey, PublicKey) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, _ := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	pub, err := ParsePublicKey(name, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return priv, pub
+}
+
+func sign(priv *ecdsa.PrivateKey, payload []byte) string {
+	h := sha256.Sum256(payload)
+	sig, _ := ecdsa.SignASN1(rand.Reader, priv, h[:])
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+func TestDiscover(t *testing.T) {
+	signed, unknown, unsigned := digest.FromString("signed"), digest.FromString("unknown"), digest.FromString("unsigned")
+	priv, pub := newKey(t, "cosign.pub")
+	other, _ := newKey(t, "other.pub")
+
+	f := &fakeRegistry{manifests: map[string][]byte{}, blobs: map[digest.Digest][]byte{}, referrers: map[digest.Digest][]registryfrontend.Descriptor{}}
+
+	payload := func(d digest.Digest) []byte {
+		return []byte(fmt.Sprintf(`{"critical":{"identity":{"docker-reference":"registry/app"},"image":{"docker-manifest-digest":"%s"},"type":"cosign container image signature"}}`, d))
+	}
+
+	f.push("sha256-"+signed.Encoded()+".sig", simpleSigningMediaType, payload(signed), map[string]string{signatureAnnotation: sign(priv, payload(signed))})
+	f.push("sha256-"+unknown.Encoded()+".sig", simpleSigningMediaType, payload(unknown), map[string]string{signatureAnnotation: sign(other, payload(unknown))})
+
+	// The attestation is found by the referrers API.
+	statement := fmt.Sprintf(`{"predicateType":"https://slsa.dev/provenance/v0.2","subject":[{"digest":{"sha256":"%s"}}]}`, signed.Encoded())
+	pae := fmt.Sprintf("DSSEv1 %d %s %d %s", len("application/vnd.in-toto+json"), "application/vnd.in-toto+json", len(statement), statement)
+	env, _ := json.Marshal(map[string]interface{}{
+		"payloadType": "application/vnd.in-toto+json",
+		"payload":     base64.StdEncoding.EncodeToString([]byte(statement)),
+		"signatures":  []map[string]string{{"sig": sign(priv, []byte(pae))}},
+	})
+	att := f.push("", client.DSSEMediaType, env, nil)
+	att.ArtifactType = attestationArtifactType
+	f.referrers[signed] = []registryfrontend.Descriptor{att}
+
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	c, _ := client.New(registryfrontend.Registry{Name: "test", Url: srv.URL})
+	v := NewVerifier([]PublicKey{pub})
+
+	discover := func(d digest.Digest, status Status, check func(*testing.T, *Result)) func(*testing.T) {
+		return func(t *testing.T) {
+			res, err := v.Discover(context.Background(), c, "app", d, true)
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if res.Status() != status {
+				t.Errorf("expected status %s, got %s: %+v", status, res.Status(), res)
+			}
+			if check != nil {
+				check(t, res)
+			}
+		}
+	}
+
+	t.Run("verified", discover(signed, Verified, func(t *testing.T, res *Result) {
+		if s := res.Signatures[0]; s.Key != "cosign.pub" || s.Identity != "registry/app" {
+			t.Errorf("unexpected signature %+v", s)
+		}
+		if len(res.Attestations) != 1 || !res.Attestations[0].Verified || res.Attestations[0].PredicateType != "https://slsa.dev/provenance/v0.2" {
+			t.Errorf("expected a verified provenance attestation, got %+v", res.Attestations)
+		}
+	}))
+	t.Run("unknown key", discover(unknown, Unverified, func(t *testing.T, res *Result) {
+		if p := res.Signatures[0].Problem; p != "not signed by any of the configured keys" {
+			t.Errorf("unexpected problem %q", p)
+		}
+	}))
+	t.Run("unsigned", discover(unsigned, Unsigned, nil))
+
+	t.Run("artifact tags", func(t *testing.T) {
+		if !IsArtifactTag("sha256-"+signed.Encoded()+".att") || IsArtifactTag("latest") {
+			t.Error("expected only the attestation tag to be an artifact tag")
+		}
+	})
+}
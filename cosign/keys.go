@@ -0,0 +1,84 @@
+package cosign
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// PublicKey is a key signatures are verified against, e.g. a cosign.pub file.
+type PublicKey struct {
+	// Name identifies the key to users, e.g. its file name.
+	Name string
+	key  crypto.PublicKey
+}
+
+// ParsePublicKey parses a PEM encoded ECDSA, RSA or Ed25519 public key, as written by cosign generate-key-pair.
+func ParsePublicKey(name string, content []byte) (PublicKey, error) {
+	block, _ := pem.Decode(content)
+
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return PublicKey{}, errors.Errorf("%s is not a PEM encoded public key", name)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+	if err != nil {
+		return PublicKey{}, errors.Wrapf(err, "could not parse public key %s", name)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey, ed25519.PublicKey:
+	default:
+		return PublicKey{}, errors.Errorf("unsupported public key type %T in %s", key, name)
+	}
+
+	return PublicKey{Name: name, key: key}, nil
+}
+
+// LoadPublicKeys reads the public keys from the files, named by their file names.
+func LoadPublicKeys(paths []string) ([]PublicKey, error) {
+	keys := make([]PublicKey, 0, len(paths))
+
+	for _, p := range paths {
+		content, err := ioutil.ReadFile(p)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "failed reading public key")
+		}
+
+		k, err := ParsePublicKey(filepath.Base(p), content)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, nil
+}
+
+// verify checks the signature of payload, which cosign signs with SHA-256 for ECDSA and RSA keys.
+func (k PublicKey) verify(payload, sig []byte) bool {
+	h := sha256.Sum256(payload)
+
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, h[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig) == nil || rsa.VerifyPSS(key, crypto.SHA256, h[:], sig, nil) == nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, sig)
+	}
+
+	return false
+}
@@ -1,6 +1,6 @@
 module github.com/mikaellindemann/registryfrontend
 
-go 1.14
+go 1.16
 
 require (
 	github.com/gorilla/mux v1.7.4
@@ -9,4 +9,6 @@ require (
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.6.0
 	github.com/stretchr/testify v1.3.0 // indirect
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
+	gopkg.in/yaml.v2 v2.3.0
 )
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// describe describes the image referenced by a tag or digest. If it is an artifact rather than an image,
+// the artifact is described as well, and the image details are limited to its creation time, size and layers.
+func describe(ctx context.Context, reg registryfrontend.Client, repository, reference string) (*registryfrontend.TagInfo, *registryfrontend.ArtifactInfo, error) {
+	ti, err := reg.Tag(ctx, repository, reference)
+
+	if errors.Cause(err) != client.ErrNotImage {
+		return ti, nil, err
+	}
+
+	a, err := reg.Artifact(ctx, repository, reference)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &registryfrontend.TagInfo{Created: a.Created, Size: a.Size, Layers: len(a.Layers)}, a, nil
+}
+
+// referrers lists the manifests referring to the manifest with digest d, e.g. its SBOMs and signatures.
+// Errors are logged, and result in no referrers.
+func (s *Server) referrers(ctx context.Context, reg registryfrontend.Client, registry, repository string, d digest.Digest) []viewmodels.Descriptor {
+	if d == "" {
+		return nil
+	}
+
+	rs, err := reg.Referrers(ctx, repository, d)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repository, "digest": d}).Warnf("Failed listing referrers: %+v", err)
+		return nil
+	}
+
+	return descriptorViews(rs)
+}
+
+func artifactView(a *registryfrontend.ArtifactInfo) *viewmodels.Artifact {
+	view := &viewmodels.Artifact{
+		Kind:         a.Kind,
+		MediaType:    a.MediaType,
+		ArtifactType: a.ArtifactType,
+		Layers:       descriptorViews(a.Layers),
+		Annotations:  make([]viewmodels.Annotation, 0, len(a.Annotations)),
+	}
+
+	if a.Subject != nil {
+		view.SubjectDigest = a.Subject.Digest.String()
+	}
+
+	for k, v := range a.Annotations {
+		view.Annotations = append(view.Annotations, viewmodels.Annotation{Key: k, Value: v})
+	}
+
+	sort.Slice(view.Annotations, func(i, j int) bool {
+		return view.Annotations[i].Key < view.Annotations[j].Key
+	})
+
+	return view
+}
+
+func descriptorViews(ds []registryfrontend.Descriptor) []viewmodels.Descriptor {
+	views := make([]viewmodels.Descriptor, 0, len(ds))
+
+	for _, d := range ds {
+		views = append(views, viewmodels.Descriptor{
+			Kind:         client.ArtifactKind(d.ArtifactType, d.MediaType),
+			MediaType:    d.MediaType,
+			ArtifactType: d.ArtifactType,
+			Digest:       d.Digest.String(),
+			ShortDigest:  shortDigest(d.Digest),
+			Size:         sizeToString(d.Size),
+			Title:        d.Annotations["org.opencontainers.image.title"],
+		})
+	}
+
+	return views
+}
@@ -0,0 +1,222 @@
+package http
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/pkg/errors"
+)
+
+// WithAuditLog records every mutating action in the audit log,
+// and makes the log available to admins at /admin/audit.
+func WithAuditLog(l *audit.Log) Option {
+	return func(s *Server) {
+		s.auditLog = l
+	}
+}
+
+// audit records the outcome of an action taken by the requesting user.
+func (s *Server) audit(r *http.Request, e audit.Entry, err error) {
+	if s.auditLog == nil {
+		return
+	}
+
+	if e.Actor == "" {
+		e.Actor = "anonymous"
+		if u := auth.UserFromContext(r.Context()); u != nil {
+			e.Actor = u.Name
+		}
+	}
+
+	e.SourceIP = s.sourceIP(r)
+
+	switch {
+	case err == nil:
+		e.Outcome = audit.Success
+	case err == errAccessDenied:
+		e.Outcome = audit.Denied
+	default:
+		e.Outcome = audit.Failure
+		e.Error = err.Error()
+	}
+
+	if err := s.auditLog.Record(e); err != nil {
+		s.l.WithField("action", e.Action).Errorf("%+v", err)
+	}
+}
+
+// sourceIP returns the address of the client, which is the last address of X-Forwarded-For if forwarded headers are trusted.
+// That is the address the proxy appended; the addresses before it are sent by the client and can be anything.
+func (s *Server) sourceIP(r *http.Request) string {
+	if xff := r.Header.Values("X-Forwarded-For"); s.forwarded && len(xff) > 0 {
+		addrs := strings.Split(xff[len(xff)-1], ",")
+
+		if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// isAdmin reports whether the requesting user may see administrative pages.
+func (s *Server) isAdmin(r *http.Request) bool {
+	return s.authz == nil || s.authz.IsAdmin(auth.UserFromContext(r.Context()))
+}
+
+// administeredEntries filters out the entries of registries the requesting user is not admin of.
+// Entries not concerning a registry, e.g. logins, are kept.
+func (s *Server) administeredEntries(r *http.Request, entries []audit.Entry) []audit.Entry {
+	res := make([]audit.Entry, 0, len(entries))
+
+	for _, e := range entries {
+		if e.Registry == "" || s.canAdminister(r, e.Registry) {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+const auditDateFormat = "2006-01-02"
+
+// auditActions are the actions recorded by the handlers.
+var auditActions = []string{"add_registry", "update_registry", "remove_registry", "import_add_registry", "import_update_registry", "export_registries", "delete_tag", "login", "logout"}
+
+func auditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+
+	f := audit.Filter{
+		Actor:      q.Get("actor"),
+		Action:     q.Get("action"),
+		Registry:   q.Get("registry"),
+		Repository: q.Get("repository"),
+		Outcome:    q.Get("outcome"),
+	}
+
+	var err error
+
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = time.ParseInLocation(auditDateFormat, v, time.Local); err != nil {
+			return f, errors.Wrap(err, "invalid since date")
+		}
+	}
+
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = time.ParseInLocation(auditDateFormat, v, time.Local); err != nil {
+			return f, errors.Wrap(err, "invalid until date")
+		}
+		// Include the whole day.
+		f.Until = f.Until.AddDate(0, 0, 1)
+	}
+
+	return f, nil
+}
+
+func (s *Server) auditOverview() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if !s.isAdmin(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			f, err := auditFilter(r)
+
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			entries, err := s.auditLog.Query(f)
+
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			entries = s.administeredEntries(r, entries)
+
+			vm := viewmodels.Audit{
+				Layout:     newLayout(w, r, "Audit log"),
+				AdminMenu:  s.adminMenu(),
+				Actor:      f.Actor,
+				Action:     f.Action,
+				Registry:   f.Registry,
+				Repository: f.Repository,
+				Outcome:    f.Outcome,
+				Since:      r.URL.Query().Get("since"),
+				Until:      r.URL.Query().Get("until"),
+				Query:      r.URL.RawQuery,
+				Actions:    auditActions,
+				Outcomes:   []string{audit.Success, audit.Failure, audit.Denied},
+			}
+
+			for _, e := range entries {
+				vm.Entries = append(vm.Entries, viewmodels.AuditEntry{
+					Time:       e.Time.Local().Format("January 2 2006 15:04:05"),
+					Actor:      e.Actor,
+					SourceIP:   e.SourceIP,
+					Action:     e.Action,
+					Registry:   e.Registry,
+					Repository: e.Repository,
+					Tag:        e.Tag,
+					Digest:     e.Digest,
+					Parameters: e.Parameters,
+					Outcome:    e.Outcome,
+					Error:      e.Error,
+				})
+			}
+
+			err = t.Execute(w, vm)
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/audit.tmpl", "templates/layout.tmpl", "templates/menu/menu-admin.tmpl",
+	)
+}
+
+func (s *Server) auditExport(w http.ResponseWriter, r *http.Request) {
+	if !s.isAdmin(r) {
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
+
+	f, err := auditFilter(r)
+
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	entries, err := s.auditLog.Query(f)
+
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	entries = s.administeredEntries(r, entries)
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+
+	if err := audit.WriteCSV(w, entries); err != nil {
+		s.l.Errorf("%+v", errors.Wrap(err, "failed writing audit export"))
+	}
+}
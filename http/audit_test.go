@@ -0,0 +1,58 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func TestAuditAdministeredRegistries(t *testing.T) {
+	l, err := audit.Open(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, e := range []audit.Entry{
+		{Actor: "alice", Action: "update_registry", Registry: "internal", Outcome: audit.Success},
+		{Actor: "bob", Action: "update_registry", Registry: "acme", Outcome: audit.Success},
+		{Actor: "carol", Action: "login", Outcome: audit.Success},
+	} {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	policy, err := authz.ParsePolicy([]byte("rules:\n  - users: [\"*\"]\n    registries: [internal]\n    role: admin\n  - users: [\"*\"]\n    registries: [acme]\n    role: viewer\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(logrus.New(), testFiles(), storage.NewInMemoryStorage(), false, WithAuthorization(policy), WithAuditLog(l))
+
+	visible := func(body string) bool {
+		return strings.Contains(body, "alice") && strings.Contains(body, "carol") && !strings.Contains(body, "bob")
+	}
+
+	t.Run("overview", page(s, "/admin/audit", http.StatusOK, visible))
+	t.Run("export", page(s, "/admin/audit.csv", http.StatusOK, visible))
+}
+
+func TestSourceIP(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "10.0.0.1:4711"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+
+	if ip := NewServer(logrus.New(), testFiles(), storage.NewInMemoryStorage(), false).sourceIP(r); ip != "10.0.0.1" {
+		t.Errorf("expected the remote address, got %s", ip)
+	}
+	if ip := NewServer(logrus.New(), testFiles(), storage.NewInMemoryStorage(), false, WithForwardedHeaders()).sourceIP(r); ip != "10.0.0.2" {
+		t.Errorf("expected the address appended by the proxy, got %s", ip)
+	}
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+)
+
+const sessionCookie = "registryfrontend_session"
+
+// publicPaths can be reached without being logged in.
+var publicPaths = map[string]bool{
+	"/login":               true,
+	"/login/oidc":          true,
+	"/login/oidc/callback": true,
+}
+
+// WithAuthentication requires users to log in using one of the enabled methods.
+// Sessions of users logging in through the login page are kept in sessions.
+func WithAuthentication(m auth.Methods, sessions *auth.SessionStore) Option {
+	return func(s *Server) {
+		s.authn = &m
+		s.sessions = sessions
+	}
+}
+
+// authenticate identifies the user of each request, and redirects anonymous users to the login page.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authn == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if u := s.identify(r); u != nil {
+			next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), u)))
+			return
+		}
+
+		if publicPaths[r.URL.Path] || strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		http.Redirect(w, r, loginPath(r, r.URL.RequestURI()), http.StatusFound)
+	})
+}
+
+func (s *Server) identify(r *http.Request) *auth.User {
+	for _, a := range s.authn.Request {
+		if u, err := a.AuthenticateRequest(r); err == nil {
+			return u
+		}
+	}
+
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if sess, ok := s.sessions.Get(c.Value); ok {
+			return &sess.User
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) login() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			vm := viewmodels.Login{
+				Layout:          newLayout(w, r, "Log in"),
+				PasswordEnabled: s.authn.Password != nil,
+				OIDCEnabled:     s.authn.OIDC != nil,
+				Next:            safeRedirect(r.FormValue("next")),
+			}
+
+			if r.Method == http.MethodPost && s.authn.Password != nil {
+				u, err := s.authn.Password.Authenticate(r.PostFormValue("user"), r.PostFormValue("password"))
+				s.audit(r, audit.Entry{Action: "login", Actor: r.PostFormValue("user"), Parameters: map[string]string{"method": "password"}}, err)
+
+				if err == nil {
+					s.startSession(w, r, *u, vm.Next)
+					return
+				}
+
+				s.l.WithField("user", r.PostFormValue("user")).Infof("Failed login: %v", err)
+				vm.Error = "Invalid username or password."
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+
+			err := t.Execute(w, vm)
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/login.tmpl", "templates/layout.tmpl", "templates/menu/menu-login.tmpl",
+	)
+}
+
+func (s *Server) loginOIDC(w http.ResponseWriter, r *http.Request) {
+	if s.authn.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	state, nonce, err := s.sessions.BeginLogin(safeRedirect(r.FormValue("next")))
+
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	http.Redirect(w, r, s.authn.OIDC.AuthCodeURL(state, nonce), http.StatusFound)
+}
+
+func (s *Server) loginOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if s.authn.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	nonce, next, ok := s.sessions.CompleteLogin(r.FormValue("state"))
+
+	if !ok {
+		setFlash(w, r, "warning", "The login expired, please try again.")
+		http.Redirect(w, r, pathTo(r, "/login"), http.StatusFound)
+		return
+	}
+
+	if e := r.FormValue("error"); e != "" {
+		s.l.WithField("error", e).Infof("OpenID Connect login failed: %s", r.FormValue("error_description"))
+		setFlash(w, r, "danger", "The login failed.")
+		http.Redirect(w, r, loginPath(r, next), http.StatusFound)
+		return
+	}
+
+	u, err := s.authn.OIDC.Exchange(r.Context(), r.FormValue("code"), nonce)
+
+	entry := audit.Entry{Action: "login", Parameters: map[string]string{"method": "oidc"}}
+	if u != nil {
+		entry.Actor = u.Name
+	}
+	s.audit(r, entry, err)
+
+	if err != nil {
+		s.l.Errorf("OpenID Connect login failed: %+v", err)
+		setFlash(w, r, "danger", "The login failed.")
+		http.Redirect(w, r, loginPath(r, next), http.StatusFound)
+		return
+	}
+
+	s.startSession(w, r, *u, next)
+}
+
+func (s *Server) startSession(w http.ResponseWriter, r *http.Request, u auth.User, next string) {
+	sess, err := s.sessions.Create(u)
+
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.l.WithField("user", u.Name).Info("User logged in")
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    sess.ID,
+		Path:     cookiePath(r),
+		Expires:  sess.Expires,
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, pathTo(r, next), http.StatusFound)
+}
+
+func (s *Server) logout(w http.ResponseWriter, r *http.Request) {
+	s.audit(r, audit.Entry{Action: "logout"}, nil)
+
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		s.sessions.Delete(c.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     cookiePath(r),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	http.Redirect(w, r, pathTo(r, "/login"), http.StatusFound)
+}
+
+// safeRedirect only allows redirects to paths on this site, to avoid being used as an open redirect.
+func safeRedirect(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/pkg/errors"
+)
+
+var errAccessDenied = errors.New("access denied by authorization policy")
+
+// WithAuthorization restricts what users can see and do according to a policy.
+// Without a policy every user can view everything, registries can be added and removed if enabled,
+// and tags cannot be deleted.
+func WithAuthorization(a authz.Authorizer) Option {
+	return func(s *Server) {
+		s.authz = a
+	}
+}
+
+// role returns the role of the requesting user in the repository.
+func (s *Server) role(r *http.Request, registry, repository string) authz.Role {
+	if s.authz == nil {
+		return authz.Viewer
+	}
+
+	return s.authz.Role(auth.UserFromContext(r.Context()), registry, repository)
+}
+
+// canView reports whether the requesting user can see the registry.
+func (s *Server) canView(r *http.Request, registry string) bool {
+	return s.authz == nil || s.authz.CanView(auth.UserFromContext(r.Context()), registry)
+}
+
+// canAdd reports whether the requesting user can add registries.
+func (s *Server) canAdd(r *http.Request) bool {
+	return s.addRemoveEnabled && (s.authz == nil || s.authz.IsAdmin(auth.UserFromContext(r.Context())))
+}
+
+// canManage reports whether the requesting user can add or remove the registry.
+func (s *Server) canManage(r *http.Request, registry string) bool {
+	return s.addRemoveEnabled && s.canAdminister(r, registry)
+}
+
+// canAdminister reports whether the requesting user is admin of the registry,
+// whether or not registries can be added and removed.
+func (s *Server) canAdminister(r *http.Request, registry string) bool {
+	return s.authz == nil || s.authz.RegistryRole(auth.UserFromContext(r.Context()), registry) >= authz.Admin
+}
+
+// visibleRepositories filters out the repositories the requesting user is not allowed to see.
+func (s *Server) visibleRepositories(r *http.Request, registry string, repos []string) []string {
+	if s.authz == nil {
+		return repos
+	}
+
+	res := make([]string, 0, len(repos))
+
+	for _, repo := range repos {
+		if s.role(r, registry, repo) >= authz.Viewer {
+			res = append(res, repo)
+		}
+	}
+
+	return res
+}
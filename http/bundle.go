@@ -0,0 +1,195 @@
+package http
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// maxBundleSize limits the size of uploaded bundles.
+const maxBundleSize = 10 << 20
+
+func (s *Server) exportRegistriesGet() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if !s.isAdmin(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			vm := viewmodels.RegistryExport{
+				Layout:             newLayout(w, r, "Export registries"),
+				CanExportPasswords: s.canExportCredentials(r),
+			}
+
+			if err := t.Execute(w, vm); err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/registryexport.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
+	)
+}
+
+// canExportCredentials reports whether the requesting user may export the credentials of the registries they administer.
+// Without an authorization policy everybody is an admin, so credentials are never exported then,
+// like they are never shown when editing registries.
+func (s *Server) canExportCredentials(r *http.Request) bool {
+	return s.authz != nil && s.authz.IsAdmin(auth.UserFromContext(r.Context()))
+}
+
+// exportRegistriesPost downloads a bundle of the registries the user administers in JSON or YAML.
+// The credentials are in plain text, encrypted with the passphrase of the form, or omitted.
+func (s *Server) exportRegistriesPost(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	format := r.Form.Get("format")
+	credentials := r.Form.Get("credentials")
+	opts := storage.ExportOptions{
+		OmitCredentials: credentials == "omit",
+		Include: func(name string) bool {
+			return s.canAdminister(r, name)
+		},
+	}
+
+	if credentials == "encrypt" {
+		opts.Passphrase = r.Form.Get("passphrase")
+
+		if opts.Passphrase == "" {
+			setFlash(w, r, "danger", "A passphrase is required to encrypt the credentials.")
+			http.Redirect(w, r, pathTo(r, "/export_registries"), http.StatusFound)
+			return
+		}
+	}
+
+	entry := audit.Entry{Action: "export_registries", Parameters: map[string]string{"format": format, "credentials": credentials}}
+
+	if !s.isAdmin(r) || (!opts.OmitCredentials && !s.canExportCredentials(r)) {
+		s.audit(r, entry, errAccessDenied)
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
+
+	b, err := storage.Export(s.s, opts)
+
+	var content []byte
+
+	if err == nil {
+		if format == "yaml" {
+			content, err = yaml.Marshal(b)
+		} else {
+			format = "json"
+			content, err = json.MarshalIndent(b, "", "  ")
+		}
+	}
+
+	s.audit(r, entry, err)
+
+	if err != nil {
+		s.error(w, r, http.StatusInternalServerError, errors.Wrap(err, "failed exporting registries"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/"+format)
+	w.Header().Set("Content-Disposition", `attachment; filename="registries.`+format+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	if _, err := w.Write(content); err != nil {
+		s.l.Errorf("%+v", errors.Wrap(err, "failed writing registry export"))
+	}
+}
+
+// importBundle previews the registries of an uploaded or pasted bundle on POST,
+// and adds the selected registries, or overwrites the registries they conflict with, when the preview is applied.
+func (s *Server) importBundle() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if !s.canAdd(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			vm := viewmodels.RegistryImport{
+				Layout: newLayout(w, r, "Import registries"),
+				Bundle: true,
+			}
+
+			if r.Method == http.MethodPost {
+				if err := r.ParseMultipartForm(maxBundleSize); err != nil && err != http.ErrNotMultipart {
+					s.error(w, r, http.StatusBadRequest, err)
+					return
+				}
+
+				payload, err := bundlePayload(r)
+
+				if err != nil {
+					s.error(w, r, http.StatusBadRequest, err)
+					return
+				}
+
+				vm.Payload = payload
+
+				changes, err := s.planBundle(payload, r.Form.Get("passphrase"))
+
+				if err != nil {
+					vm.Error = err.Error()
+				} else if r.Form.Get("apply") != "" {
+					s.applyImport(w, r, changes, r.Form["include"], "bundle")
+					return
+				}
+
+				vm.Previewed = err == nil
+				vm.Changes = registryChanges(changes)
+			}
+
+			if err := t.Execute(w, vm); err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/registryimport.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
+	)
+}
+
+// bundlePayload returns the uploaded bundle, or else the pasted one.
+func bundlePayload(r *http.Request) (string, error) {
+	f, _, err := r.FormFile("file")
+
+	if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+		return r.Form.Get("payload"), nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+
+	return string(content), errors.Wrap(err, "failed reading uploaded bundle")
+}
+
+func (s *Server) planBundle(payload, passphrase string) ([]storage.Change, error) {
+	b, err := storage.ParseBundle([]byte(payload))
+
+	if err != nil {
+		return nil, err
+	}
+
+	regs, err := b.Open(passphrase)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.PlanBundle(s.s, b, regs)
+}
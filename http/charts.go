@@ -0,0 +1,61 @@
+package http
+
+import (
+	"context"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/sirupsen/logrus"
+)
+
+// chartFiles reads the README and values of a Helm chart. Errors are logged, and result in no files.
+func (s *Server) chartFiles(ctx context.Context, reg registryfrontend.Client, registry, repository string, a *registryfrontend.ArtifactInfo) *client.ChartFiles {
+	files, err := client.ReadChartFiles(ctx, reg, repository, a)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repository, "chart": a.Chart.Name}).Warnf("Failed reading chart files: %+v", err)
+		return nil
+	}
+
+	return files
+}
+
+// chartView describes the chart of an artifact, which is nil if it is not a Helm chart. files may be nil.
+func chartView(a *registryfrontend.ArtifactInfo, files *client.ChartFiles) *viewmodels.Chart {
+	if a == nil || a.Chart == nil {
+		return nil
+	}
+
+	c := a.Chart
+	view := &viewmodels.Chart{
+		Name:        c.Name,
+		Version:     c.Version,
+		AppVersion:  c.AppVersion,
+		Description: c.Description,
+		Type:        c.Type,
+		KubeVersion: c.KubeVersion,
+		Home:        c.Home,
+		Keywords:    c.Keywords,
+		Sources:     c.Sources,
+		Deprecated:  c.Deprecated,
+	}
+
+	if view.Type == "" {
+		view.Type = "application"
+	}
+
+	for _, m := range c.Maintainers {
+		view.Maintainers = append(view.Maintainers, viewmodels.ChartMaintainer{Name: m.Name, Email: m.Email, URL: m.URL})
+	}
+
+	for _, d := range c.Dependencies {
+		view.Dependencies = append(view.Dependencies, viewmodels.ChartDependency{Name: d.Name, Version: d.Version, Repository: d.Repository})
+	}
+
+	if files != nil {
+		view.Readme, view.Values = files.Readme, files.Values
+	}
+
+	return view
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/pkg/errors"
+)
+
+const (
+	csrfCookie = "registryfrontend_csrf"
+	csrfField  = "csrf_token"
+	csrfHeader = "X-CSRF-Token"
+)
+
+type csrfKey struct{}
+
+var errCSRF = errors.New("missing or invalid CSRF token")
+
+// csrf issues a token to every client in a cookie, and requires state-changing requests
+// to echo it in the csrf_token form field or the X-CSRF-Token header.
+// As other sites can neither read the cookie nor set it, they are unable to forge such requests.
+func (s *Server) csrf(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := ""
+
+		if c, err := r.Cookie(csrfCookie); err == nil && c.Value != "" {
+			token = c.Value
+		} else {
+			t, err := auth.RandomString(32)
+
+			if err != nil {
+				s.error(w, r, http.StatusInternalServerError, err)
+				return
+			}
+
+			token = t
+			http.SetCookie(w, &http.Cookie{
+				Name:     csrfCookie,
+				Value:    token,
+				Path:     cookiePath(r),
+				HttpOnly: true,
+				Secure:   isSecure(r),
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		r = r.WithContext(context.WithValue(r.Context(), csrfKey{}, token))
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			submitted := r.Header.Get(csrfHeader)
+			if submitted == "" {
+				submitted = r.PostFormValue(csrfField)
+			}
+
+			if subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+				s.error(w, r, http.StatusForbidden, errCSRF)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// csrfToken returns the token that must be included in forms rendered for the request.
+func csrfToken(r *http.Request) string {
+	t, _ := r.Context().Value(csrfKey{}).(string)
+	return t
+}
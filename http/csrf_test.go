@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func testCSRF(method, cookie, submitted string, expected int) func(*testing.T) {
+	return func(t *testing.T) {
+		t.Helper()
+		t.Parallel()
+		s := &Server{l: logrus.New()}
+		h := s.csrf(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		r := httptest.NewRequest(method, "/remove_registry", strings.NewReader(url.Values{csrfField: {submitted}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: csrfCookie, Value: cookie})
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != expected {
+			t.Errorf("expected status %d was %d", expected, w.Code)
+		}
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	t.Run("GET without token", testCSRF(http.MethodGet, "", "", http.StatusOK))
+	t.Run("POST with matching token", testCSRF(http.MethodPost, "token", "token", http.StatusOK))
+	t.Run("POST with wrong token", testCSRF(http.MethodPost, "token", "other", http.StatusForbidden))
+	t.Run("POST without token", testCSRF(http.MethodPost, "token", "", http.StatusForbidden))
+	t.Run("POST without cookie", testCSRF(http.MethodPost, "", "token", http.StatusForbidden))
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/sirupsen/logrus"
+)
+
+type errorKey struct{}
+
+// errorMessages are shown to users instead of the actual error, which may reveal internal details.
+var errorMessages = map[int]string{
+	http.StatusBadRequest:          "The request was invalid.",
+	http.StatusForbidden:           "You are not allowed to do this.",
+	http.StatusNotFound:            "The page you were looking for could not be found.",
+	http.StatusMethodNotAllowed:    "The request method is not allowed here.",
+	http.StatusBadGateway:          "The registry could not be reached, or returned an error.",
+	http.StatusInternalServerError: "Something went wrong. The error has been logged.",
+}
+
+func (s *Server) errorPage() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			vm := r.Context().Value(errorKey{}).(viewmodels.Error)
+
+			w.WriteHeader(vm.Status)
+			err := t.Execute(w, vm)
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/error.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
+	)
+}
+
+// error logs err, and shows an error page with a generic message for the status code.
+func (s *Server) error(w http.ResponseWriter, r *http.Request, status int, err error) {
+	fields := logrus.Fields{
+		"status": status,
+		"method": r.Method,
+		"path":   r.URL.Path,
+	}
+	if u := auth.UserFromContext(r.Context()); u != nil {
+		fields["user"] = u.Name
+	}
+
+	entry := s.l.WithFields(fields)
+	if status >= http.StatusInternalServerError {
+		entry.Errorf("%+v", err)
+	} else {
+		entry.Infof("%v", err)
+	}
+
+	msg, ok := errorMessages[status]
+	if !ok {
+		msg = http.StatusText(status)
+	}
+
+	vm := viewmodels.Error{
+		Layout:  newLayout(w, r, http.StatusText(status)),
+		Status:  status,
+		Message: msg,
+	}
+
+	rs, ok := s.routes.Load().(routes)
+
+	if !ok || rs.errorHandler == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	rs.errorHandler(w, r.WithContext(context.WithValue(r.Context(), errorKey{}, vm)))
+}
@@ -0,0 +1,183 @@
+package http
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/templateloader"
+	"github.com/pkg/errors"
+)
+
+//go:generate go run ./internal/fetchassets static
+
+//go:embed templates static
+var embedded embed.FS
+
+// Files loads the templates and serves the static files of the frontend, either embedded in the binary,
+// or read from disk on every request during development.
+// When used as the template loader of the Server, the static files are served at /static.
+type Files struct {
+	fsys      fs.FS
+	onRequest bool
+
+	mu     sync.Mutex
+	hashes map[string]string
+}
+
+var _ templateloader.Loader = &Files{}
+
+// EmbeddedFiles returns the templates and static files embedded in the binary.
+func EmbeddedFiles() *Files {
+	return NewFiles(embedded, false)
+}
+
+// NewFiles reads the templates and static files from the templates and static directories of fsys,
+// e.g. os.DirFS("http") to use the files of the source tree.
+// If onRequest is set, the files are read again on every request, so changes are visible without restarting.
+func NewFiles(fsys fs.FS, onRequest bool) *Files {
+	return &Files{fsys: fsys, onRequest: onRequest, hashes: make(map[string]string)}
+}
+
+// vendoredAsset is a third party asset vendored into the static directory, and where it was downloaded from.
+type vendoredAsset struct {
+	URL       string `json:"url"`
+	Integrity string `json:"integrity"`
+}
+
+// Asset is the URL of a static file, as used in templates.
+type Asset struct {
+	URL string
+}
+
+func (f *Files) parse(name string, templateFiles []string) (*template.Template, error) {
+	if err := f.checkAssets(); err != nil {
+		return nil, errors.Wrap(err, "failed loading template")
+	}
+
+	t, err := template.New(name).Funcs(template.FuncMap{"asset": f.asset}).ParseFS(f.fsys, templateFiles...)
+	return t, errors.Wrap(err, "failed loading template")
+}
+
+// checkAssets fails if a third party asset listed in static/assets.json has not been vendored into the static directory.
+func (f *Files) checkAssets() error {
+	manifest, err := fs.ReadFile(f.fsys, "static/assets.json")
+
+	if err != nil {
+		return errors.Wrap(err, "failed reading asset manifest")
+	}
+
+	assets := map[string]vendoredAsset{}
+
+	if err := json.Unmarshal(manifest, &assets); err != nil {
+		return errors.Wrap(err, "could not parse asset manifest")
+	}
+
+	for name := range assets {
+		if _, err := f.hash(name); err != nil {
+			return errors.Wrapf(err, "asset %s has not been vendored, run go generate ./http", name)
+		}
+	}
+
+	return nil
+}
+
+func (f *Files) Load(name string, h templateloader.HandlerFunc, templateFiles ...string) (http.HandlerFunc, error) {
+	if f.onRequest {
+		return func(w http.ResponseWriter, r *http.Request) {
+			t, err := f.parse(name, templateFiles)
+
+			if err != nil {
+				http.Error(w, fmt.Sprintf("%+v", err), http.StatusInternalServerError)
+				return
+			}
+
+			h(t, w, r)
+		}, nil
+	}
+
+	t, err := f.parse(name, templateFiles)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		h(t, w, r)
+	}, err
+}
+
+// hash returns a hash of the content of the static file, which changes whenever the file changes.
+func (f *Files) hash(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if h, ok := f.hashes[name]; ok && !f.onRequest {
+		return h, nil
+	}
+
+	content, err := fs.ReadFile(f.fsys, path.Join("static", name))
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(content)
+	h := hex.EncodeToString(sum[:8])
+	f.hashes[name] = h
+
+	return h, nil
+}
+
+// asset returns the URL of the static file name below basePath.
+// The URL contains a hash of the content, so it can be cached forever.
+func (f *Files) asset(basePath, name string) (Asset, error) {
+	h, err := f.hash(name)
+
+	if err != nil {
+		return Asset{}, errors.Wrapf(err, "unknown asset %s", name)
+	}
+
+	return Asset{URL: basePath + "/static/" + h + "/" + name}, nil
+}
+
+// ServeHTTP serves the static file at /static/<hash>/<name>.
+// Files with the current hash are cached forever, while old hashes, e.g. from pages rendered before an upgrade,
+// are served but not cached.
+func (f *Files) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, "/static/")
+	i := strings.Index(p, "/")
+
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	requested, name := p[:i], path.Clean(p[i+1:])
+
+	if name == "assets.json" || strings.HasPrefix(name, "../") || name == ".." {
+		http.NotFound(w, r)
+		return
+	}
+
+	content, err := fs.ReadFile(f.fsys, path.Join("static", name))
+
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if h, err := f.hash(name); err == nil && h == requested {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(content))
+}
@@ -0,0 +1,90 @@
+package http
+
+import (
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+// testFiles returns the embedded templates with stand-ins for the vendored third party assets,
+// so the tests do not depend on them.
+func testFiles() *Files {
+	fsys := fstest.MapFS{}
+
+	err := fs.WalkDir(embedded, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := fs.ReadFile(embedded, name)
+		fsys[name] = &fstest.MapFile{Data: content}
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	assets := map[string]vendoredAsset{}
+	if err := json.Unmarshal(fsys["static/assets.json"].Data, &assets); err != nil {
+		panic(err)
+	}
+	for name := range assets {
+		fsys["static/"+name] = &fstest.MapFile{Data: []byte("/* " + name + " */")}
+	}
+
+	return NewFiles(fsys, false)
+}
+
+func testStatic(f *Files, path string, expectedStatus int, expectedCache string) func(*testing.T) {
+	return func(t *testing.T) {
+		w := httptest.NewRecorder()
+		f.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		if w.Code != expectedStatus {
+			t.Fatalf("expected status %d was %d", expectedStatus, w.Code)
+		}
+		if cache := w.Header().Get("Cache-Control"); cache != expectedCache {
+			t.Errorf("expected Cache-Control %q was %q", expectedCache, cache)
+		}
+	}
+}
+
+func TestFiles(t *testing.T) {
+	f := NewFiles(fstest.MapFS{
+		"static/assets.json":  {Data: []byte(`{"js/lib.js": {"url": "https://cdn.example.com/lib.js", "integrity": "sha384-abc"}}`)},
+		"static/css/app.css":  {Data: []byte("body {}")},
+		"static/js/lib.js":    {Data: []byte("lib()")},
+		"templates/page.tmpl": {Data: []byte(`{{(asset "" "js/lib.js").URL}}`)},
+	}, false)
+
+	vendored, err := f.asset("/tools", "css/app.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vendored.URL != "/tools/static/"+f.hashes["css/app.css"]+"/css/app.css" {
+		t.Errorf("unexpected asset %+v", vendored)
+	}
+
+	if _, err := f.parse("page", []string{"templates/page.tmpl"}); err != nil {
+		t.Errorf("unexpected error loading template: %v", err)
+	}
+
+	if _, err := f.asset("", "js/unknown.js"); err == nil {
+		t.Error("expected an error for an unknown asset")
+	}
+
+	missing := NewFiles(fstest.MapFS{
+		"static/assets.json":  {Data: []byte(`{"js/lib.js": {"url": "https://cdn.example.com/lib.js", "integrity": "sha384-abc"}}`)},
+		"templates/page.tmpl": {Data: []byte(`{{(asset "" "js/lib.js").URL}}`)},
+	}, false)
+	if _, err := missing.parse("page", []string{"templates/page.tmpl"}); err == nil {
+		t.Error("expected an error loading a template without the vendored assets")
+	}
+
+	t.Run("current hash", testStatic(f, "/static/"+f.hashes["css/app.css"]+"/css/app.css", http.StatusOK, "public, max-age=31536000, immutable"))
+	t.Run("old hash", testStatic(f, "/static/0123456789abcdef/css/app.css", http.StatusOK, "no-cache"))
+	t.Run("missing file", testStatic(f, "/static/0123456789abcdef/css/other.css", http.StatusNotFound, ""))
+	t.Run("manifest", testStatic(f, "/static/0123456789abcdef/assets.json", http.StatusNotFound, ""))
+}
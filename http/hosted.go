@@ -0,0 +1,56 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+)
+
+// hostedFromForm reads the kind, namespace, API and repositories of the registry form.
+// The URL defaults to the registry of the kind, e.g. https://ghcr.io.
+func hostedFromForm(r *http.Request, reg *registryfrontend.Registry) {
+	reg.Kind = r.Form.Get("kind")
+	reg.Namespace = strings.TrimSpace(r.Form.Get("namespace"))
+	reg.API = strings.TrimSpace(r.Form.Get("api"))
+	reg.Repositories = strings.Fields(r.Form.Get("repositories"))
+
+	if reg.Url == "" {
+		reg.Url = client.DefaultURL(reg.Kind)
+	}
+}
+
+func addHostedParameters(params map[string]string, reg registryfrontend.Registry) {
+	if reg.Kind != "" {
+		params["kind"] = reg.Kind
+	}
+	if reg.Namespace != "" {
+		params["namespace"] = reg.Namespace
+	}
+	if reg.API != "" {
+		params["api"] = reg.API
+	}
+	if len(reg.Repositories) > 0 {
+		params["repositories"] = strings.Join(reg.Repositories, ",")
+	}
+}
+
+func registryKinds() []viewmodels.RegistryKind {
+	var kinds []viewmodels.RegistryKind
+
+	for _, k := range client.Kinds() {
+		kinds = append(kinds, viewmodels.RegistryKind{Value: k, Name: client.KindName(k)})
+	}
+
+	return kinds
+}
+
+// kindName names the kind of a hosted registry, and is empty for self-hosted registries.
+func kindName(k string) string {
+	if k == "" {
+		return ""
+	}
+	return client.KindName(k)
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func TestHostedOverview(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/tags/list"):
+			fmt.Fprint(w, `{"tags":[]}`)
+		case r.URL.Path == "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories":["app","db","worker"]}`)
+		case r.URL.Path == "/api/v1/repository":
+			fmt.Fprint(w, `{"repositories":[{"namespace":"acme","name":"app"},{"namespace":"acme","name":"tools"}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	st := storage.NewInMemoryStorage()
+	for _, reg := range []registryfrontend.Registry{
+		{Name: "internal", Url: srv.URL},
+		{Name: "quay", Url: srv.URL, Kind: "quay", Namespace: "acme", API: srv.URL},
+	} {
+		if err := st.Add(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer(logrus.New(), testFiles(), st, false)
+
+	t.Run("overview", page(s, "/", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Quay") && strings.Count(body, "true") == 2
+	}))
+	t.Run("repositories", page(s, "/registry/quay", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "acme/tools") && !strings.Contains(body, "worker")
+	}))
+}
+
+func TestHarborProjects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v2.0/repositories":
+			fmt.Fprint(w, `[{"name":"library/app"},{"name":"private/db"}]`)
+		case "/api/v2.0/projects":
+			fmt.Fprint(w, `[{"project_id":1,"name":"library","repo_count":1,"metadata":{"public":"true"}},{"project_id":2,"name":"private","repo_count":1}]`)
+		case "/api/v2.0/quotas":
+			fmt.Fprint(w, `[{"ref":{"id":1},"hard":{"storage":1000},"used":{"storage":950}}]`)
+		case "/api/v2.0/projects/library/repositories/app":
+			fmt.Fprint(w, `{"name":"library/app","description":"The app","pull_count":1234}`)
+		case "/v2/library/app/tags/list":
+			fmt.Fprint(w, `{"tags":["1.0"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	st := storage.NewInMemoryStorage()
+	if err := st.Add(registryfrontend.Registry{Name: "harbor", Url: srv.URL, Kind: "harbor"}); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := authz.ParsePolicy([]byte(`
+rules:
+  - users: ["*"]
+    registries: [harbor]
+    repositories: ["library/*"]
+    role: viewer
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(logrus.New(), testFiles(), st, false, WithAuthorization(policy))
+
+	t.Run("projects", page(s, "/registry/harbor", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "950 B of 1000 B") && strings.Contains(body, "bg-danger") &&
+			strings.Contains(body, "The app") && strings.Contains(body, "1234") && !strings.Contains(body, "private")
+	}))
+}
@@ -0,0 +1,169 @@
+package http
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/pkg/errors"
+)
+
+// WithImportFile offers the Docker configuration at path, e.g. a mounted Kubernetes imagePullSecret,
+// for import on the import page, besides pasted configurations.
+func WithImportFile(path string) Option {
+	return func(s *Server) {
+		s.importFile = path
+	}
+}
+
+// importRegistries previews the registries of a Docker configuration on POST,
+// and adds or updates the selected registries when the preview is applied.
+func (s *Server) importRegistries() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if !s.canAdd(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			vm := viewmodels.RegistryImport{
+				Layout:  newLayout(w, r, "Import registries"),
+				HasFile: s.importFile != "",
+			}
+
+			if r.Method == http.MethodPost {
+				if err := r.ParseForm(); err != nil {
+					s.error(w, r, http.StatusBadRequest, err)
+					return
+				}
+
+				vm.Payload = r.Form.Get("payload")
+				vm.FromFile = s.importFile != "" && r.Form.Get("source") == "file"
+
+				changes, err := s.planImport(vm.Payload, vm.FromFile)
+
+				if err != nil {
+					vm.Error = err.Error()
+				} else if r.Form.Get("apply") != "" {
+					s.applyImport(w, r, changes, r.Form["include"], "pull_secret")
+					return
+				}
+
+				vm.Previewed = err == nil
+				vm.Changes = registryChanges(changes)
+			}
+
+			if err := t.Execute(w, vm); err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/registryimport.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
+	)
+}
+
+// planImport reads the Docker configuration from the import file or the pasted payload, and plans importing its logins.
+func (s *Server) planImport(payload string, fromFile bool) ([]storage.Change, error) {
+	content := []byte(payload)
+
+	if fromFile {
+		var err error
+
+		if content, err = ioutil.ReadFile(s.importFile); err != nil {
+			s.l.WithField("file", s.importFile).Errorf("%+v", errors.Wrap(err, "failed reading import file"))
+			return nil, errors.New("the mounted file could not be read")
+		}
+	}
+
+	d, err := client.ParsePullSecret(content)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.PlanLogins(s.s, d.Logins())
+}
+
+func registryChanges(changes []storage.Change) []viewmodels.RegistryChange {
+	res := make([]viewmodels.RegistryChange, 0, len(changes))
+
+	for _, c := range changes {
+		res = append(res, viewmodels.RegistryChange{
+			Name:        c.Registry.Name,
+			URL:         c.Registry.Url,
+			Kind:        kindName(c.Registry.Kind),
+			User:        c.Registry.User,
+			HasPassword: c.Registry.Password != "",
+			Action:      string(c.Action),
+			Warning:     c.Warning,
+		})
+	}
+
+	return res
+}
+
+// applyImport adds or updates the registries of the changes whose names are included, auditing each of them
+// with the source of the import, e.g. pull_secret.
+func (s *Server) applyImport(w http.ResponseWriter, r *http.Request, changes []storage.Change, include []string, source string) {
+	included := make(map[string]bool, len(include))
+
+	for _, name := range include {
+		included[name] = true
+	}
+
+	var imported, failed int
+
+	for _, c := range changes {
+		if c.Action == storage.ActionUnchanged || c.Action == storage.ActionInvalid || !included[c.Registry.Name] {
+			continue
+		}
+
+		reg := c.Registry
+		entry := audit.Entry{
+			Action:     "import_add_registry",
+			Registry:   reg.Name,
+			Parameters: map[string]string{"url": reg.Url, "user": reg.User, "source": source},
+		}
+		addTLSParameters(entry.Parameters, reg.TLS)
+		addHostedParameters(entry.Parameters, reg)
+
+		if !s.canManage(r, reg.Name) {
+			s.audit(r, entry, errAccessDenied)
+			failed++
+			continue
+		}
+
+		var err error
+
+		if c.Action == storage.ActionUpdate || c.Action == storage.ActionConflict {
+			entry.Action = "import_update_registry"
+			entry.Parameters["password"] = audit.SecretSet
+			err = s.s.Update(reg)
+		} else {
+			err = s.s.Add(reg)
+		}
+
+		s.audit(r, entry, err)
+
+		if err != nil {
+			s.l.WithField("registry", reg.Name).Errorf("%+v", errors.Wrap(err, "failed importing registry"))
+			failed++
+			continue
+		}
+
+		imported++
+	}
+
+	if failed > 0 {
+		setFlash(w, r, "danger", fmt.Sprintf("Imported %d registries, %d could not be imported.", imported, failed))
+	} else {
+		setFlash(w, r, "success", fmt.Sprintf("Imported %d registries.", imported))
+	}
+
+	http.Redirect(w, r, pathTo(r, "/"), http.StatusFound)
+}
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/sirupsen/logrus"
+)
+
+func TestImportRegistries(t *testing.T) {
+	st := storage.NewInMemoryStorage()
+	if err := st.Add(registryfrontend.Registry{Name: "internal", Url: "https://registry.internal", User: "ci", Password: "s3cret"}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(logrus.New(), testFiles(), st, true)
+	payload := `{"auths":{"registry.internal":{"username":"ci","password":"s3cret"},"ghcr.io":{"username":"ci","password":"token"},"quay.io":{"username":"bot","password":"robot"}}}`
+
+	t.Run("form", page(s, "/import_registries", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Docker configuration") && !strings.Contains(body, "The mounted file")
+	}))
+	t.Run("preview", post(s, "/import_registries", url.Values{"payload": {payload}}, http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Unchanged") && strings.Count(body, `name="include"`) == 2 && strings.Contains(body, "Import")
+	}))
+	t.Run("invalid", post(s, "/import_registries", url.Values{"payload": {"{"}}, http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "alert-danger") && !strings.Contains(body, `name="apply"`)
+	}))
+	t.Run("apply", post(s, "/import_registries", url.Values{"payload": {payload}, "apply": {"1"}, "include": {"ghcr"}}, http.StatusFound, nil))
+
+	if reg, err := st.Lookup("ghcr"); err != nil || reg.Kind != "ghcr" || reg.Password != "token" {
+		t.Errorf("expected ghcr to be imported, got %+v %v", reg, err)
+	}
+	if _, err := st.Lookup("quay"); err == nil {
+		t.Error("expected quay not to be imported, as it was not included")
+	}
+}
+
+func post(s *Server, path string, form url.Values, status int, check func(body string) bool) func(*testing.T) {
+	return func(t *testing.T) {
+		form.Set(csrfField, "token")
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(&http.Cookie{Name: csrfCookie, Value: "token"})
+
+		w := httptest.NewRecorder()
+		s.h.Handler.ServeHTTP(w, r)
+
+		body, _ := ioutil.ReadAll(w.Body)
+
+		if w.Code != status {
+			t.Fatalf("expected status %d, got %d:\n%s", status, w.Code, body)
+		}
+		if check != nil && !check(string(body)) {
+			t.Errorf("unexpected page:\n%s", body)
+		}
+	}
+}
+
+func TestRegistryBundle(t *testing.T) {
+	src := storage.NewInMemoryStorage()
+	for _, reg := range []registryfrontend.Registry{
+		{Name: "internal", Url: "https://registry.internal", User: "ci", Password: "s3cret"},
+		{Name: "hub", Url: "https://registry-1.docker.io", Kind: "dockerhub", Namespace: "acme"},
+	} {
+		if err := src.Add(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var bundle string
+	s := NewServer(logrus.New(), testFiles(), src, false)
+
+	t.Run("export form", page(s, "/export_registries", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Leave out") && !strings.Contains(body, "Plain text")
+	}))
+	t.Run("credentials need a policy", post(s, "/export_registries", url.Values{"credentials": {"plain"}}, http.StatusForbidden, nil))
+	t.Run("export", post(s, "/export_registries", url.Values{"credentials": {"omit"}, "format": {"yaml"}}, http.StatusOK, func(body string) bool {
+		bundle = body
+		return strings.Contains(body, "credentials_omitted: true") && strings.Contains(body, "name: internal") && !strings.Contains(body, "s3cret")
+	}))
+
+	policy, err := authz.ParsePolicy([]byte("rules:\n  - users: [\"*\"]\n    registries: [internal]\n    role: admin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewServer(logrus.New(), testFiles(), src, false, WithAuthorization(policy))
+
+	t.Run("only administered registries", post(a, "/export_registries", url.Values{"credentials": {"plain"}}, http.StatusOK, func(body string) bool {
+		return strings.Contains(body, `"password": "s3cret"`) && !strings.Contains(body, "acme")
+	}))
+
+	dst := storage.NewInMemoryStorage()
+	if err := dst.Add(registryfrontend.Registry{Name: "internal", Url: "https://old.internal", User: "bot", Password: "kept"}); err != nil {
+		t.Fatal(err)
+	}
+	d := NewServer(logrus.New(), testFiles(), dst, true)
+
+	t.Run("preview", post(d, "/import_bundle", url.Values{"payload": {bundle}}, http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Name taken") && strings.Contains(body, "Overwrite") && strings.Count(body, `type="checkbox" name="include"`) == 1
+	}))
+	t.Run("apply", post(d, "/import_bundle", url.Values{"payload": {bundle}, "apply": {"1"}, "include": {"", "internal"}}, http.StatusFound, nil))
+
+	var encrypted string
+	t.Run("encrypted", post(a, "/export_registries", url.Values{"credentials": {"encrypt"}, "passphrase": {"correct horse"}}, http.StatusOK, func(body string) bool {
+		encrypted = body
+		return strings.Contains(body, "encrypted_password")
+	}))
+	t.Run("passphrase not sent back", post(d, "/import_bundle", url.Values{"payload": {encrypted}, "passphrase": {"correct horse"}}, http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Name taken") && !strings.Contains(body, "correct horse")
+	}))
+
+	if reg, err := dst.Lookup("internal"); err != nil || reg.Url != "https://registry.internal" || reg.Password != "kept" {
+		t.Errorf("expected internal to be overwritten, keeping its credentials, got %+v %v", reg, err)
+	}
+	if _, err := dst.Lookup("hub"); err == nil {
+		t.Error("expected hub not to be imported, as it was not included")
+	}
+}
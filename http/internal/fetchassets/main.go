@@ -0,0 +1,118 @@
+// Command fetchassets downloads the third party assets listed in http/static/assets.json into http/static,
+// verifying them against their subresource integrity hashes. Run it using go generate ./http.
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type asset struct {
+	URL       string `json:"url"`
+	Integrity string `json:"integrity"`
+}
+
+func main() {
+	dir := "static"
+
+	if len(os.Args) > 1 {
+		dir = os.Args[1]
+	}
+
+	if err := fetchAll(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func fetchAll(dir string) error {
+	content, err := ioutil.ReadFile(filepath.Join(dir, "assets.json"))
+
+	if err != nil {
+		return errors.Wrap(err, "failed reading asset manifest")
+	}
+
+	assets := map[string]asset{}
+
+	if err := json.Unmarshal(content, &assets); err != nil {
+		return errors.Wrap(err, "could not parse asset manifest")
+	}
+
+	for name, a := range assets {
+		if err := fetch(filepath.Join(dir, filepath.FromSlash(name)), a); err != nil {
+			return errors.Wrap(err, name)
+		}
+		fmt.Println("fetched", name)
+	}
+
+	return nil
+}
+
+func fetch(path string, a asset) error {
+	resp, err := http.Get(a.URL)
+
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+
+	if err != nil {
+		return err
+	}
+
+	if err := verify(body, a.Integrity); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, body, 0644)
+}
+
+func verify(body []byte, integrity string) error {
+	i := strings.Index(integrity, "-")
+
+	if i < 0 {
+		return errors.Errorf("invalid integrity %q", integrity)
+	}
+
+	var h hash.Hash
+
+	switch integrity[:i] {
+	case "sha256":
+		h = sha256.New()
+	case "sha384":
+		h = sha512.New384()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return errors.Errorf("unsupported integrity algorithm %q", integrity[:i])
+	}
+
+	h.Write(body)
+
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != integrity[i+1:] {
+		return errors.New("content does not match the integrity hash")
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+)
+
+const flashCookie = "registryfrontend_flash"
+
+// setFlash stores a message to be shown on the next page rendered for the client,
+// typically the page a form submission redirects to.
+func setFlash(w http.ResponseWriter, r *http.Request, kind, message string) {
+	b, _ := json.Marshal(viewmodels.Flash{Kind: kind, Message: message})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     flashCookie,
+		Value:    base64.RawURLEncoding.EncodeToString(b),
+		Path:     cookiePath(r),
+		HttpOnly: true,
+		Secure:   isSecure(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// popFlash returns the pending flash message, if any, and removes it.
+func popFlash(w http.ResponseWriter, r *http.Request) *viewmodels.Flash {
+	c, err := r.Cookie(flashCookie)
+
+	if err != nil {
+		return nil
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: flashCookie, Path: cookiePath(r), MaxAge: -1})
+
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+
+	if err != nil {
+		return nil
+	}
+
+	f := &viewmodels.Flash{}
+
+	if err := json.Unmarshal(b, f); err != nil {
+		return nil
+	}
+
+	return f
+}
+
+// newLayout creates the values used by the layout template for the request.
+func newLayout(w http.ResponseWriter, r *http.Request, title string) viewmodels.Layout {
+	l := viewmodels.Layout{
+		Title:     title,
+		BasePath:  basePath(r),
+		CSRFToken: csrfToken(r),
+		Flash:     popFlash(w, r),
+	}
+
+	if u := auth.UserFromContext(r.Context()); u != nil {
+		l.User = u.Name
+	}
+
+	return l
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type prefixKey struct{}
+type secureKey struct{}
+
+// WithBasePath serves the frontend below path, e.g. /registry, instead of at the root.
+func WithBasePath(path string) Option {
+	return func(s *Server) {
+		s.basePath = cleanPrefix(path)
+	}
+}
+
+// WithForwardedHeaders trusts the X-Forwarded-Prefix, X-Forwarded-Proto and X-Forwarded-For headers set by a reverse proxy.
+// X-Forwarded-Prefix is the path the proxy removed from the request, and is added to every link and redirect.
+// The last address of X-Forwarded-For, appended by the proxy, is recorded as the source IP in the audit log.
+// Only enable this when every request passes through a proxy that sets or removes these headers.
+func WithForwardedHeaders() Option {
+	return func(s *Server) {
+		s.forwarded = true
+	}
+}
+
+// cleanPrefix returns the path without a trailing slash, or "" if it is not a local path.
+func cleanPrefix(p string) string {
+	p = strings.TrimRight(p, "/")
+
+	if !strings.HasPrefix(p, "/") || strings.HasPrefix(p, "//") || strings.ContainsAny(p, "\\?#") {
+		return ""
+	}
+
+	return p
+}
+
+// mount removes the base path from requests, and records the prefix of the links of the frontend in the context.
+func (s *Server) mount(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := s.basePath
+
+		if s.basePath != "" {
+			if r.URL.Path == s.basePath {
+				http.Redirect(w, r, s.basePath+"/", http.StatusMovedPermanently)
+				return
+			}
+
+			if !strings.HasPrefix(r.URL.Path, s.basePath+"/") {
+				http.NotFound(w, r)
+				return
+			}
+
+			u := *r.URL
+			u.Path = strings.TrimPrefix(r.URL.Path, s.basePath)
+			u.RawPath = ""
+
+			r2 := *r
+			r2.URL = &u
+			r = &r2
+		}
+
+		ctx := r.Context()
+
+		if s.forwarded {
+			prefix = cleanPrefix(r.Header.Get("X-Forwarded-Prefix")) + prefix
+
+			if strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+				ctx = context.WithValue(ctx, secureKey{}, true)
+			}
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, prefixKey{}, prefix)))
+	})
+}
+
+// basePath returns the path the frontend is reached at by the client, without a trailing slash.
+func basePath(r *http.Request) string {
+	p, _ := r.Context().Value(prefixKey{}).(string)
+	return p
+}
+
+// pathTo returns the path of a page of the frontend, as seen by the client.
+func pathTo(r *http.Request, p string) string {
+	return basePath(r) + p
+}
+
+// cookiePath limits cookies to the frontend, when it shares the host with other applications.
+func cookiePath(r *http.Request) string {
+	return basePath(r) + "/"
+}
+
+// isSecure reports whether the client connected using https, either directly or through a reverse proxy.
+func isSecure(r *http.Request) bool {
+	secure, _ := r.Context().Value(secureKey{}).(bool)
+	return r.TLS != nil || secure
+}
+
+// loginPath returns the path of the login page, returning to the requested page after logging in.
+func loginPath(r *http.Request, next string) string {
+	return pathTo(r, "/login?next="+url.QueryEscape(next))
+}
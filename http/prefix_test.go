@@ -0,0 +1,45 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testMount(s *Server, path, forwardedPrefix string, expectedStatus int, expectedPath, expectedPrefix string) func(*testing.T) {
+	return func(t *testing.T) {
+		var gotPath, prefix string
+
+		h := s.mount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath = r.URL.Path
+			prefix = basePath(r)
+		}))
+
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if forwardedPrefix != "" {
+			r.Header.Set("X-Forwarded-Prefix", forwardedPrefix)
+		}
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+
+		if w.Code != expectedStatus {
+			t.Fatalf("expected status %d was %d", expectedStatus, w.Code)
+		}
+		if gotPath != expectedPath || prefix != expectedPrefix {
+			t.Errorf("expected path %q and prefix %q, got %q and %q", expectedPath, expectedPrefix, gotPath, prefix)
+		}
+	}
+}
+
+func TestMount(t *testing.T) {
+	base := &Server{basePath: "/tools"}
+	t.Run("below base path", testMount(base, "/tools/registry/a", "", http.StatusOK, "/registry/a", "/tools"))
+	t.Run("base path", testMount(base, "/tools", "", http.StatusMovedPermanently, "", ""))
+	t.Run("outside base path", testMount(base, "/other", "", http.StatusNotFound, "", ""))
+	t.Run("untrusted forwarded prefix", testMount(base, "/tools/", "/proxy", http.StatusOK, "/", "/tools"))
+
+	forwarded := &Server{forwarded: true}
+	t.Run("forwarded prefix", testMount(forwarded, "/registry/a", "/proxy/", http.StatusOK, "/registry/a", "/proxy"))
+	t.Run("forwarded prefix to other host", testMount(forwarded, "/", "//evil.example.com", http.StatusOK, "/", ""))
+}
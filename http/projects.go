@@ -0,0 +1,121 @@
+package http
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/sirupsen/logrus"
+)
+
+// projects lists the projects of the repositories, if the registry organizes repositories into projects.
+// Errors are logged, and result in no projects.
+func (s *Server) projects(ctx context.Context, reg registryfrontend.Client, repositories []string) []viewmodels.Project {
+	pl, ok := reg.(registryfrontend.ProjectLister)
+
+	if !ok {
+		return nil
+	}
+
+	ps, err := pl.Projects(ctx)
+
+	if err != nil {
+		s.l.WithField("registry", reg.Name()).Warnf("Failed listing projects: %+v", err)
+		return nil
+	}
+
+	// Only the projects of repositories the user can view are shown.
+	visible := make(map[string]bool)
+
+	for _, repo := range repositories {
+		visible[strings.SplitN(repo, "/", 2)[0]] = true
+	}
+
+	views := make([]viewmodels.Project, 0, len(ps))
+
+	for _, p := range ps {
+		if !visible[p.Name] {
+			continue
+		}
+
+		view := viewmodels.Project{
+			Name:         p.Name,
+			Public:       p.Public,
+			Repositories: p.Repositories,
+			Quota:        "Unlimited",
+			Used:         sizeToString(p.Used),
+			Percent:      -1,
+		}
+
+		if p.Quota >= 0 {
+			view.Quota = sizeToString(p.Quota)
+			view.Percent = 100
+
+			if p.Quota > 0 && p.Used < p.Quota {
+				view.Percent = int(p.Used * 100 / p.Quota)
+			}
+		}
+
+		views = append(views, view)
+	}
+
+	return views
+}
+
+// describeRepository adds the description and pull count of a repository, if the registry describes repositories.
+// Errors are logged, and leave the repository as it is.
+func (s *Server) describeRepository(ctx context.Context, reg registryfrontend.Client, repo *viewmodels.Repository) {
+	rd, ok := reg.(registryfrontend.RepositoryDescriber)
+
+	if !ok {
+		return
+	}
+
+	info, err := rd.RepositoryInfo(ctx, repo.Name)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": reg.Name(), "repository": repo.Name}).Warnf("Failed describing repository: %+v", err)
+		return
+	}
+
+	repo.Description, repo.PullCount = info.Description, info.PullCount
+}
+
+// artifactStatus returns when an image was pushed and pulled and the result of its latest scan,
+// or nil if the registry does not track images. Errors are logged, and result in nil.
+func (s *Server) artifactStatus(ctx context.Context, reg registryfrontend.Client, repository, reference string) *viewmodels.ArtifactStatus {
+	sp, ok := reg.(registryfrontend.ArtifactStatusProvider)
+
+	if !ok {
+		return nil
+	}
+
+	st, err := sp.ArtifactStatus(ctx, repository, reference)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": reg.Name(), "repository": repository, "reference": reference}).Warnf("Failed fetching artifact status: %+v", err)
+		return nil
+	}
+
+	view := &viewmodels.ArtifactStatus{
+		Pushed:     st.Pushed.Format("January 2 2006 15:04:05"),
+		Pulled:     "Never",
+		ScanStatus: st.ScanStatus,
+		Severity:   st.Severity,
+		Vulnerabilities: viewmodels.Severities{
+			Critical: st.Vulnerabilities.Critical,
+			High:     st.Vulnerabilities.High,
+			Medium:   st.Vulnerabilities.Medium,
+			Low:      st.Vulnerabilities.Low,
+		},
+		Total:   st.Vulnerabilities.Total,
+		Fixable: st.Vulnerabilities.Fixable,
+	}
+
+	if !st.Pulled.IsZero() {
+		view.Pulled = st.Pulled.Format("January 2 2006 15:04:05")
+	}
+
+	return view
+}
@@ -0,0 +1,113 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/mikaellindemann/registryfrontend/sbom"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// packages lists the SBOMs of an image, and their packages matching query, or all packages if query is empty.
+// Errors are logged, and result in no SBOMs.
+func (s *Server) packages(ctx context.Context, reg registryfrontend.Client, registry, repository string, d digest.Digest, query string) ([]viewmodels.SBOM, []viewmodels.Package) {
+	if d == "" {
+		return nil, nil
+	}
+
+	docs, err := s.sboms.Get(ctx, reg, repository, d)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repository, "digest": d}).Warnf("Failed finding SBOMs: %+v", err)
+		return nil, nil
+	}
+
+	sboms := make([]viewmodels.SBOM, 0, len(docs))
+	seen := make(map[sbom.Package]bool)
+	var packages []viewmodels.Package
+
+	for _, doc := range docs {
+		sboms = append(sboms, viewmodels.SBOM{Format: doc.Format, Source: doc.Source, Packages: len(doc.Packages)})
+
+		// Images with both an SPDX and a CycloneDX SBOM list most packages twice.
+		for _, p := range doc.Packages {
+			if seen[p] || (query != "" && !p.Matches(query)) {
+				continue
+			}
+
+			seen[p] = true
+			packages = append(packages, viewmodels.Package(p))
+		}
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return strings.ToLower(packages[i].Name) < strings.ToLower(packages[j].Name)
+	})
+
+	return sboms, packages
+}
+
+// packageSearch finds the images containing a package, in every repository the user can view.
+// Only the images whose SBOMs have been fetched, e.g. by viewing them, are searched, using the index of the SBOM cache.
+func (s *Server) packageSearch() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			vm := viewmodels.PackageSearch{
+				Layout:  newLayout(w, r, "Package search"),
+				Package: strings.TrimSpace(r.URL.Query().Get("package")),
+				Version: strings.TrimSpace(r.URL.Query().Get("version")),
+			}
+
+			if vm.Package != "" {
+				vm.Searched = true
+				s.searchPackage(r, &vm)
+			}
+
+			if err := t.Execute(w, vm); err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/packagesearch.tmpl", "templates/layout.tmpl", "templates/menu/menu-packages.tmpl",
+	)
+}
+
+func (s *Server) searchPackage(r *http.Request, vm *viewmodels.PackageSearch) {
+	index := s.sboms.Index()
+
+	for _, img := range index.Images() {
+		if s.canSearch(r, img) {
+			vm.Images++
+		}
+	}
+
+	for _, m := range index.Lookup(vm.Package, vm.Version) {
+		if !s.canSearch(r, m.Image) {
+			continue
+		}
+
+		vm.Matches = append(vm.Matches, viewmodels.PackageMatch{
+			Registry:      m.Registry,
+			Repository:    m.Repository,
+			UrlRepository: template.URLQueryEscaper(template.URLQueryEscaper(m.Repository)),
+			Digest:        m.Digest.String(),
+			ShortDigest:   shortDigest(m.Digest),
+			Package:       viewmodels.Package(m.Package),
+		})
+	}
+}
+
+// canSearch reports whether the indexed image is in a registry that still exists, and in a repository the user can view.
+func (s *Server) canSearch(r *http.Request, img sbom.Image) bool {
+	if _, err := s.s.Lookup(img.Registry); err != nil {
+		return false
+	}
+
+	return s.canView(r, img.Registry) && len(s.visibleRepositories(r, img.Registry, []string{img.Repository})) == 1
+}
@@ -7,36 +7,98 @@ import (
 	"net/http"
 	"net/url"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/audit"
+	"github.com/mikaellindemann/registryfrontend/auth"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/cosign"
 	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/mikaellindemann/registryfrontend/sbom"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/mikaellindemann/registryfrontend/vuln"
 	"github.com/mikaellindemann/templateloader"
+	"github.com/opencontainers/go-digest"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 )
 
 type Server struct {
 	h                http.Server
+	redirect         *http.Server
 	l                *logrus.Logger
 	t                templateloader.Loader
 	s                registryfrontend.Storage
-	r                *mux.Router
 	addRemoveEnabled bool
+	authn            *auth.Methods
+	sessions         *auth.SessionStore
+	authz            authz.Authorizer
+	auditLog         *audit.Log
+	cosign           *cosign.Verifier
+	sboms            *sbom.Cache
+	vulns            *vuln.Store
+	importFile       string
+	basePath         string
+	forwarded        bool
+
+	// routes holds the current routes, which are replaced as a whole by Reload.
+	routes   atomic.Value
+	reloadMu sync.Mutex
+
+	statusMu sync.Mutex
+	status   ReloadStatus
+}
+
+// routes are the handlers created from the templates.
+type routes struct {
+	router       http.Handler
+	errorHandler http.HandlerFunc
+}
+
+// An Option configures optional features of the Server.
+type Option func(*Server)
+
+// WithAddress makes the Server listen on addr instead of :8080.
+func WithAddress(addr string) Option {
+	return func(s *Server) {
+		s.h.Addr = addr
+	}
 }
 
 // Start makes the Server available.
 // The server will run in a separate goroutine, and this function will return immediately.
 func (s *Server) Start() {
 	go func() {
-		err := s.h.ListenAndServe()
+		var err error
+
+		if s.h.TLSConfig != nil {
+			err = s.h.ListenAndServeTLS("", "")
+		} else {
+			err = s.h.ListenAndServe()
+		}
 
 		if err != http.ErrServerClosed {
 			panic(err)
 		}
 	}()
-	s.l.WithField("address", s.h.Addr).Infof("Now listenening on %s.", s.h.Addr)
+	s.l.WithFields(logrus.Fields{"address": s.h.Addr, "tls": s.h.TLSConfig != nil}).Infof("Now listenening on %s.", s.h.Addr)
+
+	if s.redirect != nil {
+		go func() {
+			err := s.redirect.ListenAndServe()
+
+			if err != http.ErrServerClosed {
+				panic(err)
+			}
+		}()
+		s.l.WithField("address", s.redirect.Addr).Infof("Redirecting http requests on %s to https.", s.redirect.Addr)
+	}
 }
 
 // Shutdown will make the server unreachable.
@@ -50,51 +112,145 @@ func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	if s.redirect != nil {
+		if err := s.redirect.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+
 	return s.h.Shutdown(ctx)
 }
 
-func (s *Server) initRouter() {
+// newRoutes loads the templates, and creates the handlers of every route.
+func (s *Server) newRoutes() (routes, error) {
+	var loadErr error
+
 	must := func(h http.HandlerFunc, err error) http.HandlerFunc {
-		if err != nil {
-			panic(err)
+		if err != nil && loadErr == nil {
+			loadErr = err
 		}
 		return h
 	}
 
-	router := s.r
+	router := mux.NewRouter()
+	errorHandler := must(s.errorPage())
+	router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.error(w, r, http.StatusNotFound, errors.Errorf("no route for %s", r.URL.Path))
+	})
+
+	router.Use(s.authenticate, s.csrf)
+
+	if s.authn != nil {
+		router.HandleFunc("/login", must(s.login())).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/login/oidc", s.loginOIDC).Methods(http.MethodGet)
+		router.HandleFunc("/login/oidc/callback", s.loginOIDCCallback).Methods(http.MethodGet)
+		router.HandleFunc("/logout", s.logout).Methods(http.MethodPost)
+	}
+
+	if h, ok := s.t.(http.Handler); ok {
+		router.PathPrefix("/static/").Handler(h).Methods(http.MethodGet, http.MethodHead)
+	}
 
 	router.HandleFunc("/", must(s.overview())).Methods(http.MethodGet)
 
 	if s.addRemoveEnabled {
 		router.HandleFunc("/add_registry", must(s.addRegistryGet())).Methods(http.MethodGet)
-		router.HandleFunc("/add_registry", addRegistryPost(s.s)).Methods(http.MethodPost)
+		router.HandleFunc("/add_registry", s.addRegistryPost).Methods(http.MethodPost)
+
+		router.HandleFunc("/remove_registry", s.removeRegistry).Methods(http.MethodPost)
 
-		router.HandleFunc("/remove_registry", removeRegistry(s.s)).Methods(http.MethodPost)
+		router.HandleFunc("/edit_registry/{registry}", must(s.editRegistryGet())).Methods(http.MethodGet)
+		router.HandleFunc("/edit_registry/{registry}", s.editRegistryPost).Methods(http.MethodPost)
+
+		router.HandleFunc("/import_registries", must(s.importRegistries())).Methods(http.MethodGet, http.MethodPost)
+		router.HandleFunc("/import_bundle", must(s.importBundle())).Methods(http.MethodGet, http.MethodPost)
 	}
 
-	router.HandleFunc("/registry/{registry}", must(repoOverview(s.l, s.t, s.s))).Methods(http.MethodGet)
+	router.HandleFunc("/delete_tag", s.deleteTag).Methods(http.MethodPost)
+
+	router.HandleFunc("/export_registries", must(s.exportRegistriesGet())).Methods(http.MethodGet)
+	router.HandleFunc("/export_registries", s.exportRegistriesPost).Methods(http.MethodPost)
+
+	if s.auditLog != nil {
+		router.HandleFunc("/admin/audit", must(s.auditOverview())).Methods(http.MethodGet)
+		router.HandleFunc("/admin/audit.csv", s.auditExport).Methods(http.MethodGet)
+	}
+
+	router.HandleFunc("/admin/status", must(s.statusPage())).Methods(http.MethodGet)
+
+	router.HandleFunc("/registry/{registry}", must(s.repoOverview())).Methods(http.MethodGet)
+
+	tagDetail := must(s.tagDetail())
 
-	router.HandleFunc("/registry/{registry}/{repo}", must(tagOverview(s.l, s.t, s.s))).Methods(http.MethodGet)
+	// Registered first, as the repository of the next route would match the digest as well.
+	router.HandleFunc("/registry/{registry}/{repo}@{digest:sha256:[a-f0-9]{64}}", tagDetail).Methods(http.MethodGet)
 
-	router.HandleFunc("/registry/{registry}/{repo}/{tag}", must(tagDetail(s.l, s.t, s.s))).Methods(http.MethodGet)
+	router.HandleFunc("/registry/{registry}/{repo}", must(s.tagOverview())).Methods(http.MethodGet)
+
+	router.HandleFunc("/registry/{registry}/{repo}/{tag}", tagDetail).Methods(http.MethodGet)
+
+	router.HandleFunc("/verify/{registry}/{repo}", must(s.verifyRepository())).Methods(http.MethodGet)
+
+	router.HandleFunc("/packages", must(s.packageSearch())).Methods(http.MethodGet)
+
+	return routes{router: router, errorHandler: errorHandler}, loadErr
 }
 
-func NewServer(l *logrus.Logger, t templateloader.Loader, s registryfrontend.Storage, addRemoveEnabled bool) *Server {
-	router := mux.NewRouter()
+func (s *Server) currentRoutes() routes {
+	return s.routes.Load().(routes)
+}
+
+// Reload recreates the handlers using t, e.g. to pick up changed templates.
+// Requests in progress are completed by the previous handlers, while new requests use the new handlers.
+// On errors, the previous handlers are kept.
+func (s *Server) Reload(t templateloader.Loader) error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	old := s.t
+	s.t = t
 
+	r, err := s.newRoutes()
+
+	if err != nil {
+		s.t = old
+		return err
+	}
+
+	s.routes.Store(r)
+	return nil
+}
+
+func NewServer(l *logrus.Logger, t templateloader.Loader, s registryfrontend.Storage, addRemoveEnabled bool, opts ...Option) *Server {
 	server := &Server{
 		h: http.Server{
-			Addr:    ":8080",
-			Handler: router,
+			Addr: ":8080",
 		},
 		s:                s,
 		t:                t,
-		r:                router,
 		l:                l,
 		addRemoveEnabled: addRemoveEnabled,
+		cosign:           cosign.NewVerifier(nil),
+		sboms:            sbom.NewCache(time.Hour),
+		vulns:            vuln.NewStore(nil, time.Hour),
+	}
+
+	for _, opt := range opts {
+		opt(server)
 	}
 
-	server.initRouter()
+	r, err := server.newRoutes()
+
+	if err != nil {
+		panic(err)
+	}
+
+	server.routes.Store(r)
+	server.h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.currentRoutes().router.ServeHTTP(w, r)
+	})
+	server.h.Handler = server.mount(server.h.Handler)
+
 	return server
 }
 
@@ -103,322 +259,751 @@ func (s *Server) overview() (http.HandlerFunc, error) {
 		"layout",
 		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
 				return
 			}
 
 			rs, err := s.s.Registries()
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusInternalServerError)).Error(), http.StatusInternalServerError)
+				s.error(w, r, http.StatusInternalServerError, err)
 				return
 			}
 
 			regs := make([]viewmodels.Registry, 0, len(rs))
 
 			for _, reg := range rs {
+				if !s.canView(r, reg.Name()) {
+					continue
+				}
+
 				repos, err := reg.Repositories(r.Context())
 
-				regs = append(regs, viewmodels.Registry{
+				vm := viewmodels.Registry{
 					Name:          reg.Name(),
 					URL:           reg.URL(),
+					Kind:          kindName(reg.Kind()),
 					Online:        err == nil,
-					NumberOfRepos: len(repos),
-				})
+					NumberOfRepos: len(s.visibleRepositories(r, reg.Name(), repos)),
+					CanRemove:     s.canManage(r, reg.Name()),
+				}
+
+				if vm.Online {
+					vm.TLS = s.tlsDetails(r, reg)
+				}
+
+				regs = append(regs, vm)
 			}
 
 			err = t.Execute(w, viewmodels.Overview{
-				Title:            "Registries",
+				Layout:           newLayout(w, r, "Registries"),
 				Registries:       regs,
-				AddRemoveEnabled: s.addRemoveEnabled,
+				AddRemoveEnabled: s.canAdd(r),
+				CanExport:        s.isAdmin(r),
 			})
 
 			if err != nil {
 				s.l.Errorf("%+v", err)
 			}
 		},
-		"http/templates/registries.tmpl", "http/templates/layout.tmpl", "http/templates/menu/menu-registries.tmpl",
+		"templates/registries.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
 	)
 }
 
+// tlsDetails returns the certificate of a registry served over https, or nil.
+func (s *Server) tlsDetails(r *http.Request, reg registryfrontend.Client) *viewmodels.TLS {
+	if !strings.HasPrefix(reg.URL(), "https://") {
+		return nil
+	}
+
+	info, err := reg.TLS(r.Context())
+
+	if err != nil {
+		s.l.WithField("registry", reg.Name()).Warnf("Failed fetching TLS details: %v", err)
+		return nil
+	}
+
+	if info == nil {
+		return nil
+	}
+
+	now := time.Now()
+
+	return &viewmodels.TLS{
+		Subject:     info.Subject,
+		Issuer:      info.Issuer,
+		Expires:     info.NotAfter.Local().Format("January 2 2006"),
+		Expired:     now.After(info.NotAfter),
+		ExpiresSoon: now.AddDate(0, 0, 30).After(info.NotAfter),
+		Verified:    info.Verified,
+	}
+}
+
 func (s *Server) addRegistryGet() (http.HandlerFunc, error) {
 	return s.t.Load(
 		"layout",
 		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
 				return
 			}
 
-			err := t.Execute(w, viewmodels.Layout{
-				Title: "Add registry",
+			if !s.canAdd(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			err := t.Execute(w, viewmodels.RegistryForm{
+				Layout: newLayout(w, r, "Add registry"),
+				Kinds:  registryKinds(),
 			})
 
 			if err != nil {
 				s.l.Errorf("%+v", err)
 			}
 		},
-		"http/templates/registryform.tmpl", "http/templates/layout.tmpl", "http/templates/menu/menu-registries.tmpl",
+		"templates/registryform.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
 	)
 }
 
-func addRegistryPost(s registryfrontend.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-			return
-		}
+func (s *Server) addRegistryPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
 
-		reg := registryfrontend.Registry{}
+	reg := registryfrontend.Registry{}
 
-		err := r.ParseForm()
+	err := r.ParseForm()
 
-		if err != nil {
-			http.Error(w, errors.Wrap(err, http.StatusText(http.StatusBadRequest)).Error(), http.StatusBadRequest)
-			return
-		}
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
 
-		reg.Name = r.Form.Get("name")
-		reg.Url = r.Form.Get("url")
-		reg.User = r.Form.Get("user")
-		reg.Password = r.Form.Get("password")
+	reg.Name = r.Form.Get("name")
+	reg.Url = r.Form.Get("url")
+	reg.User = r.Form.Get("user")
+	reg.Password = r.Form.Get("password")
+	reg.TLS = tlsFromForm(r, registryfrontend.TLSOptions{})
+	hostedFromForm(r, &reg)
+
+	entry := audit.Entry{
+		Action:     "add_registry",
+		Registry:   reg.Name,
+		Parameters: map[string]string{"url": reg.Url, "user": reg.User},
+	}
+	addTLSParameters(entry.Parameters, reg.TLS)
+	addHostedParameters(entry.Parameters, reg)
 
-		err = s.Add(reg)
+	if !s.canManage(r, reg.Name) {
+		s.audit(r, entry, errAccessDenied)
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
 
-		if err != nil {
-			http.Error(w, fmt.Sprintf("%+v", errors.WithStack(errors.Wrap(err, http.StatusText(http.StatusInternalServerError)))), http.StatusInternalServerError)
-			return
-		}
+	if err := client.ValidateTLSOptions(reg.TLS); err != nil {
+		s.audit(r, entry, err)
+		setFlash(w, r, "danger", fmt.Sprintf("The TLS settings are invalid: %v.", err))
+		http.Redirect(w, r, pathTo(r, "/add_registry"), http.StatusFound)
+		return
+	}
 
-		u := *r.URL
-		u.Path = "/"
+	if err := client.ValidateKind(reg.Kind); err != nil {
+		s.audit(r, entry, err)
+		setFlash(w, r, "danger", fmt.Sprintf("The registry kind is invalid: %v.", err))
+		http.Redirect(w, r, pathTo(r, "/add_registry"), http.StatusFound)
+		return
+	}
 
-		http.Redirect(w, r, u.String(), http.StatusFound)
+	err = s.s.Add(reg)
+	s.audit(r, entry, err)
+
+	if err == storage.ErrIllegalName {
+		setFlash(w, r, "danger", "Registry names may only contain the characters a-z, A-Z, 0-9, - and _.")
+		http.Redirect(w, r, pathTo(r, "/add_registry"), http.StatusFound)
+		return
+	} else if err != nil {
+		s.l.WithField("registry", reg.Name).Errorf("%+v", errors.Wrap(err, "failed adding registry"))
+		setFlash(w, r, "danger", fmt.Sprintf("The registry %s could not be added.", reg.Name))
+		http.Redirect(w, r, pathTo(r, "/add_registry"), http.StatusFound)
+		return
 	}
+
+	setFlash(w, r, "success", fmt.Sprintf("The registry %s was added.", reg.Name))
+	http.Redirect(w, r, pathTo(r, "/"), http.StatusFound)
 }
 
-func removeRegistry(s registryfrontend.Storage) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-			return
-		}
+func (s *Server) editRegistryGet() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+				return
+			}
+
+			name := mux.Vars(r)["registry"]
 
-		reg := registryfrontend.Registry{}
+			if !s.canManage(r, name) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
 
-		err := r.ParseForm()
+			reg, err := s.s.Lookup(name)
 
-		if err != nil {
-			http.Error(w, errors.Wrap(err, http.StatusText(http.StatusBadRequest)).Error(), http.StatusBadRequest)
-			return
-		}
+			if err != nil {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
+
+			err = t.Execute(w, viewmodels.RegistryForm{
+				Layout:             newLayout(w, r, "Edit registry"),
+				Edit:               true,
+				Name:               reg.Name,
+				URL:                reg.Url,
+				User:               reg.User,
+				HasPassword:        reg.Password != "",
+				CACert:             reg.TLS.CACert,
+				ClientCert:         reg.TLS.ClientCert,
+				HasClientKey:       reg.TLS.ClientKey != "",
+				InsecureSkipVerify: reg.TLS.InsecureSkipVerify,
+				Kind:               reg.Kind,
+				Kinds:              registryKinds(),
+				Namespace:          reg.Namespace,
+				API:                reg.API,
+				Repositories:       strings.Join(reg.Repositories, "\n"),
+			})
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/registryform.tmpl", "templates/layout.tmpl", "templates/menu/menu-registries.tmpl",
+	)
+}
+
+// editRegistryPost updates a registry. The password is write-only:
+// leaving the field empty keeps the stored password, unless clear_password is checked.
+func (s *Server) editRegistryPost(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	err := r.ParseForm()
+
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	name := mux.Vars(r)["registry"]
+
+	entry := audit.Entry{
+		Action:     "update_registry",
+		Registry:   name,
+		Parameters: map[string]string{"url": r.Form.Get("url"), "user": r.Form.Get("user")},
+	}
+
+	if !s.canManage(r, name) {
+		s.audit(r, entry, errAccessDenied)
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
+
+	reg, err := s.s.Lookup(name)
+
+	if err != nil {
+		s.audit(r, entry, err)
+		s.error(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	// The credentials of the Docker config are for the configured host, not wherever the registry is moved to.
+	if u := r.Form.Get("url"); u != reg.Url {
+		reg.Url = u
+		reg.DockerConfig = false
+	}
+
+	reg.User = r.Form.Get("user")
 
-		reg.Name = r.Form.Get("name")
+	if p := r.Form.Get("password"); p != "" {
+		reg.Password = p
+		entry.Parameters["password"] = audit.SecretSet
+	} else if r.Form.Get("clear_password") != "" {
+		reg.Password = ""
+		entry.Parameters["password"] = audit.SecretCleared
+	}
 
-		err = s.Remove(reg)
+	tlsOpts := tlsFromForm(r, reg.TLS)
 
-		if err != nil {
-			http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
-			return
+	if tlsOpts.ClientKey != reg.TLS.ClientKey {
+		entry.Parameters["client_key"] = audit.SecretSet
+		if tlsOpts.ClientKey == "" {
+			entry.Parameters["client_key"] = audit.SecretCleared
 		}
+	}
+
+	reg.TLS = tlsOpts
+	addTLSParameters(entry.Parameters, reg.TLS)
+	hostedFromForm(r, &reg)
+	addHostedParameters(entry.Parameters, reg)
+
+	if err := client.ValidateTLSOptions(reg.TLS); err != nil {
+		s.audit(r, entry, err)
+		setFlash(w, r, "danger", fmt.Sprintf("The TLS settings are invalid: %v.", err))
+		http.Redirect(w, r, pathTo(r, "/edit_registry/"+name), http.StatusFound)
+		return
+	}
+
+	if err := client.ValidateKind(reg.Kind); err != nil {
+		s.audit(r, entry, err)
+		setFlash(w, r, "danger", fmt.Sprintf("The registry kind is invalid: %v.", err))
+		http.Redirect(w, r, pathTo(r, "/edit_registry/"+name), http.StatusFound)
+		return
+	}
+
+	err = s.s.Update(reg)
+	s.audit(r, entry, err)
+
+	if err != nil {
+		s.l.WithField("registry", name).Errorf("%+v", errors.Wrap(err, "failed updating registry"))
+		setFlash(w, r, "danger", fmt.Sprintf("The registry %s could not be updated.", name))
+		http.Redirect(w, r, pathTo(r, "/edit_registry/"+name), http.StatusFound)
+		return
+	}
+
+	setFlash(w, r, "success", fmt.Sprintf("The registry %s was updated.", name))
+	http.Redirect(w, r, pathTo(r, "/"), http.StatusFound)
+}
+
+// tlsFromForm reads the TLS options of the registry form.
+// The client key is write-only: leaving the field empty keeps the key of old, unless clear_client_key is checked.
+// Removing the client certificate removes the key as well.
+func tlsFromForm(r *http.Request, old registryfrontend.TLSOptions) registryfrontend.TLSOptions {
+	opts := registryfrontend.TLSOptions{
+		CACert:             strings.TrimSpace(r.Form.Get("ca_cert")),
+		ClientCert:         strings.TrimSpace(r.Form.Get("client_cert")),
+		ClientKey:          old.ClientKey,
+		InsecureSkipVerify: r.Form.Get("insecure_skip_verify") != "",
+	}
+
+	if k := strings.TrimSpace(r.Form.Get("client_key")); k != "" {
+		opts.ClientKey = k
+	} else if r.Form.Get("clear_client_key") != "" || opts.ClientCert == "" {
+		opts.ClientKey = ""
+	}
+
+	return opts
+}
+
+// addTLSParameters records which TLS options are used, without the certificates themselves.
+func addTLSParameters(params map[string]string, opts registryfrontend.TLSOptions) {
+	if opts.CACert != "" {
+		params["ca_cert"] = "set"
+	}
+	if opts.ClientCert != "" {
+		params["client_cert"] = "set"
+	}
+	if opts.InsecureSkipVerify {
+		params["insecure_skip_verify"] = "true"
+	}
+}
+
+func (s *Server) removeRegistry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	reg := registryfrontend.Registry{}
+
+	err := r.ParseForm()
 
-		http.Redirect(w, r, "/", http.StatusFound)
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
 	}
+
+	reg.Name = r.Form.Get("name")
+
+	entry := audit.Entry{Action: "remove_registry", Registry: reg.Name}
+
+	if !s.canManage(r, reg.Name) {
+		s.audit(r, entry, errAccessDenied)
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
+
+	err = s.s.Remove(reg)
+	s.audit(r, entry, err)
+
+	if err != nil {
+		s.l.WithField("registry", reg.Name).Errorf("%+v", errors.Wrap(err, "failed removing registry"))
+		setFlash(w, r, "danger", fmt.Sprintf("The registry %s could not be removed.", reg.Name))
+	} else {
+		setFlash(w, r, "success", fmt.Sprintf("The registry %s was removed.", reg.Name))
+	}
+
+	http.Redirect(w, r, pathTo(r, "/"), http.StatusFound)
 }
 
-func repoOverview(l *logrus.Logger, tl templateloader.Loader, s registryfrontend.Storage) (http.HandlerFunc, error) {
-	return tl.Load(
+func (s *Server) repoOverview() (http.HandlerFunc, error) {
+	return s.t.Load(
 		"layout",
 		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
 				return
 			}
 
 			vars := mux.Vars(r)
 
-			reg, err := s.Registry(vars["registry"])
+			if !s.canView(r, vars["registry"]) {
+				s.error(w, r, http.StatusNotFound, errAccessDenied)
+				return
+			}
+
+			reg, err := s.s.Registry(vars["registry"])
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
+				s.error(w, r, http.StatusNotFound, err)
 				return
 			}
 
 			repos, err := reg.Repositories(r.Context())
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusBadRequest)).Error(), http.StatusBadRequest)
+				s.error(w, r, http.StatusBadRequest, err)
 				return
 			}
 
+			repos = s.visibleRepositories(r, reg.Name(), repos)
+
 			reps := make([]viewmodels.Repository, 0, len(repos))
 
 			for _, repo := range repos {
 				ti, err := reg.Tags(r.Context(), repo)
 
 				if err != nil {
-					http.Error(w, fmt.Sprintf("%+v", errors.WithStack(errors.Wrap(err, "failed fetching repository details"))), http.StatusInternalServerError)
+					s.error(w, r, http.StatusInternalServerError, errors.Wrap(err, "failed fetching repository details"))
 					return
 				}
 
-				reps = append(reps, viewmodels.Repository{
+				newest, critical := s.newestCritical(r.Context(), reg, repo, ti)
+
+				rep := viewmodels.Repository{
 					Name:         repo,
 					UrlName:      template.URLQueryEscaper(template.URLQueryEscaper(repo)),
 					NumberOfTags: len(ti),
-				})
+					NewestTag:    newest,
+					Critical:     critical,
+				}
+				s.describeRepository(r.Context(), reg, &rep)
+
+				reps = append(reps, rep)
 			}
 
+			_, described := reg.(registryfrontend.RepositoryDescriber)
+
 			err = t.Execute(w, viewmodels.RegistryDetail{
-				Title:        "Repositories",
+				Layout:       newLayout(w, r, "Repositories"),
 				Registry:     reg.Name(),
 				Repositories: reps,
+				Projects:     s.projects(r.Context(), reg, repos),
+				Described:    described,
 			})
 
 			if err != nil {
-				l.Errorf("%+v", err)
+				s.l.Errorf("%+v", err)
 			}
 		},
-		"http/templates/repos.tmpl", "http/templates/layout.tmpl", "http/templates/menu/menu-repos.tmpl",
+		"templates/repos.tmpl", "templates/layout.tmpl", "templates/menu/menu-repos.tmpl",
 	)
 }
 
-func tagOverview(l *logrus.Logger, tl templateloader.Loader, s registryfrontend.Storage) (http.HandlerFunc, error) {
-	return tl.Load(
+func (s *Server) tagOverview() (http.HandlerFunc, error) {
+	return s.t.Load(
 		"layout",
 		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
 				return
 			}
 
 			vars := mux.Vars(r)
 
-			reg, err := s.Registry(vars["registry"])
+			repoName, err := url.PathUnescape(vars["repo"])
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
+				s.error(w, r, http.StatusBadRequest, err)
 				return
 			}
 
-			repoName, err := url.PathUnescape(vars["repo"])
+			if s.role(r, vars["registry"], repoName) < authz.Viewer {
+				s.error(w, r, http.StatusNotFound, errAccessDenied)
+				return
+			}
+
+			reg, err := s.s.Registry(vars["registry"])
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusBadRequest)).Error(), http.StatusBadRequest)
+				s.error(w, r, http.StatusNotFound, err)
 				return
 			}
 
 			ts, err := reg.Tags(r.Context(), repoName)
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
+				s.error(w, r, http.StatusNotFound, err)
 				return
 			}
 
-			tags := make([]viewmodels.TagOverviewInfo, 0, len(ts))
+			images := make([]viewmodels.TagGroup, 0, len(ts))
+			groups := make(map[digest.Digest]int)
+			charts := false
 
 			for _, tag := range ts {
-				ti, err := reg.Tag(r.Context(), repoName, tag)
+				// Signatures and attestations are shown with the images they belong to.
+				if cosign.IsArtifactTag(tag) {
+					continue
+				}
+
+				// Tags that cannot be resolved are shown on their own.
+				d, err := reg.Digest(r.Context(), repoName, tag)
+
+				if i, ok := groups[d]; ok && err == nil {
+					images[i].Tags = append(images[i].Tags, tag)
+					continue
+				}
+
+				image := viewmodels.TagGroup{Tags: []string{tag}}
+				ref := tag
+
+				if err == nil {
+					groups[d] = len(images)
+					image.Digest, image.ShortDigest = d.String(), shortDigest(d)
+					ref = d.String()
+				}
+
+				ti, artifact, err := describe(r.Context(), reg, repoName, ref)
+
+				if artifact != nil {
+					image.Kind = artifact.Kind
+					image.Chart = chartView(artifact, nil)
+					charts = charts || image.Chart != nil
+				}
 
 				if err != nil {
 					var zeroTime time.Time
-					tags = append(tags, viewmodels.TagOverviewInfo{
-						Name:    tag,
-						Created: zeroTime.Format("January 2 2006 15:04:05"),
-						Size:    "Unknown",
-						Layers:  -1,
-					})
-					// http.Error(w, fmt.Sprintf("%+v", errors.WithStack(errors.Wrap(err, "failed fetching tag information"))), http.StatusInternalServerError)
-					// return
-					continue
+					image.Created = zeroTime.Format("January 2 2006 15:04:05")
+					image.Size = "Unknown"
+					image.Layers = -1
+				} else {
+					image.Created = ti.Created.Format("January 2 2006 15:04:05")
+					image.Size = sizeToString(ti.Size)
+					image.Layers = ti.Layers
 				}
 
-				tags = append(tags, viewmodels.TagOverviewInfo{
-					Name:    tag,
-					Created: ti.Created.Format("January 2 2006 15:04:05"),
-					Size:    sizeToString(ti.Size),
-					Layers:  ti.Layers,
-				})
+				image.Signature = signatureStatus(s.signatures(r.Context(), reg, vars["registry"], repoName, d, false))
+				image.Vulnerabilities = severities(s.vulnerabilities(r.Context(), reg, vars["registry"], repoName, d))
+
+				images = append(images, image)
 			}
 
-			sort.Slice(tags, func(i, j int) bool {
+			sort.SliceStable(images, func(i, j int) bool {
 				// Errors ignored as the strings were created by applying this format.
-				t1, _ := time.Parse("January 2 2006 15:04:05", tags[i].Created)
-				t2, _ := time.Parse("January 2 2006 15:04:05", tags[j].Created)
+				t1, _ := time.Parse("January 2 2006 15:04:05", images[i].Created)
+				t2, _ := time.Parse("January 2 2006 15:04:05", images[j].Created)
 
 				return t2.Before(t1)
 			})
 
 			err = t.Execute(w, viewmodels.TagOverview{
-				Title:         "Tags",
+				Layout:        newLayout(w, r, "Tags"),
 				Registry:      vars["registry"],
 				Repository:    repoName,
 				UrlRepository: template.URLQueryEscaper(vars["repo"]),
-				Tags:          tags,
+				Images:        images,
+				Charts:        charts,
+				CanDelete:     s.role(r, vars["registry"], repoName) >= authz.Deleter,
 			})
 
 			if err != nil {
-				l.Errorf("%+v", err)
+				s.l.Errorf("%+v", err)
 			}
 		},
-		"http/templates/tags.tmpl", "http/templates/layout.tmpl", "http/templates/menu/menu-tags.tmpl",
+		"templates/tags.tmpl", "templates/layout.tmpl", "templates/menu/menu-tags.tmpl",
 	)
 }
 
-func tagDetail(l *logrus.Logger, tl templateloader.Loader, s registryfrontend.Storage) (http.HandlerFunc, error) {
-	return tl.Load(
+func (s *Server) tagDetail() (http.HandlerFunc, error) {
+	return s.t.Load(
 		"layout",
 		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
-				http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+				s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
 				return
 			}
 
 			vars := mux.Vars(r)
 
-			reg, err := s.Registry(vars["registry"])
+			repoName, err := url.PathUnescape(vars["repo"])
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
+				s.error(w, r, http.StatusBadRequest, err)
 				return
 			}
 
-			repoName, err := url.PathUnescape(vars["repo"])
+			if s.role(r, vars["registry"], repoName) < authz.Viewer {
+				s.error(w, r, http.StatusNotFound, errAccessDenied)
+				return
+			}
+
+			reg, err := s.s.Registry(vars["registry"])
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusBadRequest)).Error(), http.StatusBadRequest)
+				s.error(w, r, http.StatusNotFound, err)
 				return
 			}
 
-			tag, err := reg.Tag(r.Context(), repoName, vars["tag"])
+			// The image is referenced either by tag, or by digest on the /registry/{registry}/{repo}@{digest} route.
+			ref, title := vars["tag"], "Tag details"
+
+			if vars["digest"] != "" {
+				ref, title = vars["digest"], "Image details"
+			}
+
+			tag, artifact, err := describe(r.Context(), reg, repoName, ref)
 
 			if err != nil {
-				http.Error(w, errors.Wrap(err, http.StatusText(http.StatusNotFound)).Error(), http.StatusNotFound)
+				s.error(w, r, http.StatusNotFound, err)
 				return
 			}
 
+			d, err := reg.Digest(r.Context(), repoName, ref)
+
+			if err != nil {
+				s.l.WithFields(logrus.Fields{"registry": vars["registry"], "repository": repoName, "tag": ref}).Warnf("%+v", errors.Wrap(err, "failed resolving digest"))
+			}
+
+			res := s.signatures(r.Context(), reg, vars["registry"], repoName, d, true)
+			sigs, atts := signatureViews(res)
+
+			query := strings.TrimSpace(r.URL.Query().Get("q"))
+			sboms, packages := s.packages(r.Context(), reg, vars["registry"], repoName, d, query)
+
+			reports := s.vulnerabilities(r.Context(), reg, vars["registry"], repoName, d)
+			vulns, vulnReports := vulnerabilityViews(reports)
+
+			var artifactDetails *viewmodels.Artifact
+
+			if artifact != nil {
+				artifactDetails = artifactView(artifact)
+
+				if artifact.Chart != nil {
+					artifactDetails.Chart = chartView(artifact, s.chartFiles(r.Context(), reg, vars["registry"], repoName, artifact))
+				}
+			}
+
 			err = t.Execute(w, viewmodels.TagDetails{
-				Title:         "Tag details",
-				Registry:      vars["registry"],
-				Repository:    repoName,
-				UrlRepository: template.URLQueryEscaper(vars["repo"]),
-				Tag:           vars["tag"],
-				Created:       tag.Created.Format("January 2 2006 15:04:05 "),
-				DockerVersion: tag.DockerVersion,
-				Size:          sizeToString(tag.Size),
-				Layers:        tag.Layers,
-				User:          tag.User,
-				Volumes:       fmt.Sprint(tag.Volumes),
-				Ports:         fmt.Sprint(tag.ExposedPorts),
+				Layout:               newLayout(w, r, title),
+				Registry:             vars["registry"],
+				Repository:           repoName,
+				UrlRepository:        template.URLQueryEscaper(vars["repo"]),
+				Tag:                  vars["tag"],
+				Digest:               d.String(),
+				ShortDigest:          shortDigest(d),
+				Created:              tag.Created.Format("January 2 2006 15:04:05 "),
+				DockerVersion:        tag.DockerVersion,
+				Size:                 sizeToString(tag.Size),
+				Layers:               tag.Layers,
+				User:                 tag.User,
+				Volumes:              fmt.Sprint(tag.Volumes),
+				Ports:                fmt.Sprint(tag.ExposedPorts),
+				Signature:            signatureStatus(res),
+				Signatures:           sigs,
+				Attestations:         atts,
+				Artifact:             artifactDetails,
+				Status:               s.artifactStatus(r.Context(), reg, repoName, ref),
+				Referrers:            s.referrers(r.Context(), reg, vars["registry"], repoName, d),
+				SBOMs:                sboms,
+				Packages:             packages,
+				PackageQuery:         query,
+				Severities:           severities(reports),
+				Vulnerabilities:      vulns,
+				VulnerabilityReports: vulnReports,
+				CanDelete:            s.role(r, vars["registry"], repoName) >= authz.Deleter,
 			})
 
 			if err != nil {
-				l.Errorf("%+v", err)
+				s.l.Errorf("%+v", err)
 			}
 		},
-		"http/templates/tagdetails.tmpl", "http/templates/layout.tmpl", "http/templates/menu/menu-tag-details.tmpl",
+		"templates/tagdetails.tmpl", "templates/layout.tmpl", "templates/menu/menu-tag-details.tmpl",
 	)
 }
 
+func (s *Server) deleteTag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		s.error(w, r, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", r.Method))
+		return
+	}
+
+	err := r.ParseForm()
+
+	if err != nil {
+		s.error(w, r, http.StatusBadRequest, err)
+		return
+	}
+
+	registry, repoName, tag := r.Form.Get("registry"), r.Form.Get("repo"), r.Form.Get("tag")
+
+	entry := audit.Entry{Action: "delete_tag", Registry: registry, Repository: repoName, Tag: tag}
+
+	if s.role(r, registry, repoName) < authz.Deleter {
+		s.audit(r, entry, errAccessDenied)
+		s.error(w, r, http.StatusForbidden, errAccessDenied)
+		return
+	}
+
+	reg, err := s.s.Registry(registry)
+
+	if err != nil {
+		s.audit(r, entry, err)
+		s.error(w, r, http.StatusNotFound, err)
+		return
+	}
+
+	d, err := reg.DeleteTag(r.Context(), repoName, tag)
+	entry.Digest = d.String()
+	s.audit(r, entry, err)
+
+	what := "tag " + tag
+
+	if _, err := digest.Parse(tag); err == nil {
+		what = "image " + tag
+	}
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repoName, "tag": tag}).Errorf("%+v", errors.Wrap(err, "failed deleting tag"))
+		setFlash(w, r, "danger", fmt.Sprintf("The %s could not be deleted.", what))
+	} else {
+		setFlash(w, r, "success", fmt.Sprintf("The %s was deleted.", what))
+	}
+
+	http.Redirect(w, r, pathTo(r, fmt.Sprintf("/registry/%s/%s", registry, template.URLQueryEscaper(template.URLQueryEscaper(repoName)))), http.StatusFound)
+}
+
+// shortDigest abbreviates a digest like docker does, e.g. sha256:4c0d3f6c1b2a.
+func shortDigest(d digest.Digest) string {
+	if d.Validate() != nil || len(d.Encoded()) < 12 {
+		return d.String()
+	}
+	return d.Algorithm().String() + ":" + d.Encoded()[:12]
+}
+
 func sizeToString(byteCount int64) string {
 
 	if gb := float64(byteCount) / 1024.0 / 1024.0 / 1024.0; gb >= 1.0 {
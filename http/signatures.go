@@ -0,0 +1,65 @@
+package http
+
+import (
+	"context"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/cosign"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// WithCosignKeys verifies the cosign signatures and attestations of images against the public keys.
+// Without keys, signatures are listed but shown as unverified.
+func WithCosignKeys(keys []cosign.PublicKey) Option {
+	return func(s *Server) {
+		s.cosign = cosign.NewVerifier(keys)
+	}
+}
+
+// signatures finds and verifies the signatures of an image, and its attestations if attestations is true.
+// Errors are logged, and result in nil.
+func (s *Server) signatures(ctx context.Context, reg registryfrontend.Client, registry, repository string, d digest.Digest, attestations bool) *cosign.Result {
+	if d == "" {
+		return nil
+	}
+
+	res, err := s.cosign.Discover(ctx, reg, repository, d, attestations)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repository, "digest": d}).Warnf("Failed finding signatures: %+v", err)
+		return nil
+	}
+
+	return res
+}
+
+// signatureStatus is the status shown for res, which is empty if the signatures could not be found.
+func signatureStatus(res *cosign.Result) string {
+	if res == nil {
+		return ""
+	}
+
+	return string(res.Status())
+}
+
+func signatureViews(res *cosign.Result) ([]viewmodels.Signature, []viewmodels.Attestation) {
+	if res == nil {
+		return nil, nil
+	}
+
+	sigs := make([]viewmodels.Signature, 0, len(res.Signatures))
+
+	for _, sig := range res.Signatures {
+		sigs = append(sigs, viewmodels.Signature(sig))
+	}
+
+	atts := make([]viewmodels.Attestation, 0, len(res.Attestations))
+
+	for _, att := range res.Attestations {
+		atts = append(atts, viewmodels.Attestation(att))
+	}
+
+	return sigs, atts
+}
@@ -0,0 +1,79 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+)
+
+// ReloadStatus is the result of loading or reloading the configuration.
+type ReloadStatus struct {
+	Time     time.Time
+	Source   string
+	Err      error
+	Added    []string
+	Updated  []string
+	Removed  []string
+	Rejected []string
+}
+
+// SetReloadStatus records the result of loading the configuration, which is shown to admins at /admin/status.
+func (s *Server) SetReloadStatus(st ReloadStatus) {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	s.status = st
+}
+
+func (s *Server) reloadStatus() ReloadStatus {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	return s.status
+}
+
+func (s *Server) statusPage() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			if !s.isAdmin(r) {
+				s.error(w, r, http.StatusForbidden, errAccessDenied)
+				return
+			}
+
+			st := s.reloadStatus()
+
+			vm := viewmodels.Status{
+				Layout:    newLayout(w, r, "Status"),
+				AdminMenu: s.adminMenu(),
+				Loaded:    !st.Time.IsZero(),
+				Source:    st.Source,
+				Added:     st.Added,
+				Updated:   st.Updated,
+				Removed:   st.Removed,
+				Rejected:  st.Rejected,
+			}
+
+			if vm.Loaded {
+				vm.Time = st.Time.Local().Format("January 2 2006 15:04:05")
+			}
+
+			if st.Err != nil {
+				vm.Error = st.Err.Error()
+			}
+
+			err := t.Execute(w, vm)
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/status.tmpl", "templates/layout.tmpl", "templates/menu/menu-admin.tmpl",
+	)
+}
+
+func (s *Server) adminMenu() viewmodels.AdminMenu {
+	return viewmodels.AdminMenu{AuditEnabled: s.auditLog != nil}
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/storage"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+const imageConfig = `{"created":"2020-06-01T12:00:00Z","config":{"User":"app"}}`
+
+func TestDigests(t *testing.T) {
+	manifests := map[string]string{}
+	for i, layerSize := range []int{100, 200} {
+		m := fmt.Sprintf(`{"schemaVersion":2,"config":{"digest":"%s"},"layers":[{"digest":"sha256:%064d","size":%d}]}`, digest.FromString(imageConfig), i, layerSize)
+		manifests[digest.FromString(m).String()] = m
+		if i == 0 {
+			manifests["latest"], manifests["1.4"] = m, m
+		} else {
+			manifests["1.3"] = m
+		}
+	}
+
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/_catalog":
+			fmt.Fprint(w, `{"repositories":["app"]}`)
+		case r.URL.Path == "/v2/app/tags/list":
+			fmt.Fprint(w, `{"name":"app","tags":["1.3","1.4","latest"]}`)
+		case r.URL.Path == "/v2/app/blobs/"+digest.FromString(imageConfig).String():
+			fmt.Fprint(w, imageConfig)
+		case strings.HasPrefix(r.URL.Path, "/v2/app/manifests/"):
+			m, ok := manifests[strings.TrimPrefix(r.URL.Path, "/v2/app/manifests/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/vnd.docker.distribution.manifest.v2+json")
+			w.Header().Set("Docker-Content-Digest", digest.FromString(m).String())
+			fmt.Fprint(w, m)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer reg.Close()
+
+	st := storage.NewInMemoryStorage()
+	if err := st.Add(registryfrontend.Registry{Name: "reg", Url: reg.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(logrus.New(), testFiles(), st, false)
+	latest := digest.FromString(manifests["latest"])
+
+	t.Run("grouped by digest", page(s, "/registry/reg/app", http.StatusOK, func(body string) bool {
+		return strings.Count(body, "/registry/reg/app@sha256:") == 2
+	}))
+	t.Run("tag page shows digest", page(s, "/registry/reg/app/latest", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, latest.String())
+	}))
+	t.Run("by digest", page(s, "/registry/reg/app@"+latest.String(), http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Image details") && strings.Contains(body, "100 B")
+	}))
+	t.Run("verify", page(s, "/verify/reg/app", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Checked 3 tags, 2 manifests and 3 blobs") && strings.Count(body, "<td>missing</td>") == 2
+	}))
+	t.Run("package search", page(s, "/packages?package=openssl", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Searched the SBOMs of 1 image.") && strings.Contains(body, "No images contain openssl")
+	}))
+	t.Run("unknown digest", page(s, "/registry/reg/app@sha256:"+strings.Repeat("0", 64), http.StatusNotFound, nil))
+}
+
+func TestArtifacts(t *testing.T) {
+	config := `{"apiVersion":"v2","name":"app","version":"0.1.0","appVersion":"1.16.0","description":"A chart for the app",` +
+		`"maintainers":[{"name":"Platform team","email":"platform@example.com"}]}`
+	archive := chartArchive(t, map[string]string{
+		"app/Chart.yaml":             "name: app",
+		"app/README.md":              "# The app chart",
+		"app/values.yaml":            "replicaCount: 1",
+		"app/charts/db/values.yaml":  "replicaCount: 3",
+		"app/templates/service.yaml": "kind: Service",
+	})
+	chart := fmt.Sprintf(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json",`+
+		`"config":{"mediaType":"application/vnd.cncf.helm.config.v1+json","digest":"%s","size":%d},`+
+		`"layers":[{"mediaType":"application/vnd.cncf.helm.chart.content.v1.tar+gzip","digest":"%s","size":%d,"annotations":{"org.opencontainers.image.title":"app-0.1.0.tgz"}}],`+
+		`"annotations":{"org.opencontainers.image.created":"2023-01-02T03:04:05Z","org.opencontainers.image.description":"The app chart"}}`,
+		digest.FromString(config), len(config), digest.FromBytes(archive), len(archive))
+	d := digest.FromString(chart)
+
+	reg := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/charts/app/tags/list":
+			fmt.Fprint(w, `{"name":"charts/app","tags":["0.1.0"]}`)
+		case "/v2/charts/app/manifests/0.1.0", "/v2/charts/app/manifests/" + d.String():
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Header().Set("Docker-Content-Digest", d.String())
+			fmt.Fprint(w, chart)
+		case "/v2/charts/app/blobs/" + digest.FromString(config).String():
+			fmt.Fprint(w, config)
+		case "/v2/charts/app/blobs/" + digest.FromBytes(archive).String():
+			w.Write(archive)
+		case "/v2/charts/app/referrers/" + d.String():
+			fmt.Fprintf(w, `{"manifests":[{"mediaType":"application/vnd.oci.image.manifest.v1+json","artifactType":"application/spdx+json","digest":"%s","size":500}]}`, digest.FromString("sbom"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer reg.Close()
+
+	st := storage.NewInMemoryStorage()
+	if err := st.Add(registryfrontend.Registry{Name: "reg", Url: reg.URL}); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(logrus.New(), testFiles(), st, false)
+
+	t.Run("overview", page(s, "/registry/reg/charts%252Fapp", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Helm chart") && strings.Contains(body, sizeToString(int64(len(archive)))) &&
+			strings.Contains(body, "1.16.0") && strings.Contains(body, "A chart for the app")
+	}))
+	t.Run("details", page(s, "/registry/reg/charts%252Fapp/0.1.0", http.StatusOK, func(body string) bool {
+		return strings.Contains(body, "Helm chart") && strings.Contains(body, "app-0.1.0.tgz") &&
+			strings.Contains(body, "The app chart") && strings.Contains(body, "SPDX SBOM") && !strings.Contains(body, "Docker version") &&
+			strings.Contains(body, "Platform team") && strings.Contains(body, "# The app chart") &&
+			strings.Contains(body, "replicaCount: 1") && !strings.Contains(body, "replicaCount: 3")
+	}))
+}
+
+// chartArchive creates a gzipped tar archive of the files, as pushed by helm push.
+func chartArchive(t *testing.T, files map[string]string) []byte {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return b.Bytes()
+}
+
+func page(s *Server, path string, status int, check func(body string) bool) func(*testing.T) {
+	return func(t *testing.T) {
+		w := httptest.NewRecorder()
+		s.h.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		body, _ := ioutil.ReadAll(w.Body)
+
+		if w.Code != status {
+			t.Fatalf("expected status %d, got %d", status, w.Code)
+		}
+		if check != nil && !check(string(body)) {
+			t.Errorf("unexpected page:\n%s", body)
+		}
+	}
+}
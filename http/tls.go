@@ -0,0 +1,154 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/internal/watch"
+	"github.com/pkg/errors"
+)
+
+// CertificateFile is a certificate and key read from files, which can be reloaded without restarting the frontend,
+// e.g. when they are renewed by cert-manager. If reloading fails, the previously loaded certificate stays in use.
+type CertificateFile struct {
+	certFile, keyFile string
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTimes [2]time.Time
+}
+
+// OpenCertificateFile loads the PEM encoded certificate and key.
+func OpenCertificateFile(certFile, keyFile string) (*CertificateFile, error) {
+	f := &CertificateFile{certFile: certFile, keyFile: keyFile}
+
+	return f, f.Reload()
+}
+
+func (f *CertificateFile) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+
+	for i, path := range []string{f.certFile, f.keyFile} {
+		info, err := os.Stat(path)
+
+		if err != nil {
+			return modTimes, errors.Wrap(err, "failed reading certificate")
+		}
+
+		modTimes[i] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// Reload reads the certificate and key again.
+func (f *CertificateFile) Reload() error {
+	modTimes, err := f.stat()
+
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+
+	if err != nil {
+		return errors.Wrap(err, "failed loading certificate")
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.cert = &cert
+	f.modTimes = modTimes
+
+	return nil
+}
+
+// Watch reloads the certificate whenever the modification time of either file changes, checking every interval.
+// The result of every reload is passed to onReload. Watch returns when ctx is cancelled.
+func (f *CertificateFile) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	watch.Poll(ctx, interval, f.changed, f.Reload, onReload)
+}
+
+// changed reports whether the modification time of either file changed since the last reload.
+func (f *CertificateFile) changed() (bool, error) {
+	modTimes, err := f.stat()
+
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return modTimes != f.modTimes, nil
+}
+
+// GetCertificate returns the currently loaded certificate, for use in tls.Config.
+func (f *CertificateFile) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.cert, nil
+}
+
+// WithTLS makes the Server serve https using the certificate.
+func WithTLS(cert *CertificateFile) Option {
+	return func(s *Server) {
+		if s.h.TLSConfig == nil {
+			s.h.TLSConfig = &tls.Config{}
+		}
+		s.h.TLSConfig.GetCertificate = cert.GetCertificate
+	}
+}
+
+// WithClientCAs makes the Server verify client certificates issued by the CAs.
+// If required is false, clients without a certificate are accepted as well.
+// It must be combined with WithTLS.
+func WithClientCAs(cas *x509.CertPool, required bool) Option {
+	return func(s *Server) {
+		if s.h.TLSConfig == nil {
+			s.h.TLSConfig = &tls.Config{}
+		}
+		s.h.TLSConfig.ClientCAs = cas
+		s.h.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		if required {
+			s.h.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+}
+
+// WithRedirect makes the Server listen for http requests on addr as well, redirecting them to https.
+// It must be combined with WithTLS.
+func WithRedirect(addr string) Option {
+	return func(s *Server) {
+		s.redirect = &http.Server{
+			Addr:    addr,
+			Handler: http.HandlerFunc(s.redirectToHTTPS),
+		}
+	}
+}
+
+func (s *Server) redirectToHTTPS(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	if _, port, err := net.SplitHostPort(s.h.Addr); err == nil && port != "443" && port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	u := *r.URL
+	u.Scheme = "https"
+	u.Host = host
+
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
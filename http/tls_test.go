@@ -0,0 +1,135 @@
+package http
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCertificate(t *testing.T, dir, cn string, modTime time.Time) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]*pem.Block{
+		"cert.pem": {Type: "CERTIFICATE", Bytes: der},
+		"key.pem":  {Type: "EC PRIVATE KEY", Bytes: keyDer},
+	}
+
+	for name, block := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func commonName(t *testing.T, f *CertificateFile) string {
+	t.Helper()
+
+	cert, err := f.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed.Subject.CommonName
+}
+
+func TestCertificateFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeCertificate(t, dir, "old", time.Now().Add(-time.Hour))
+
+	f, err := OpenCertificateFile(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeCertificate(t, dir, "new", time.Now())
+
+	if cn := commonName(t, f); cn != "old" {
+		t.Errorf("expected the old certificate before reloading, got %s", cn)
+	}
+
+	if err := f.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cn := commonName(t, f); cn != "new" {
+		t.Errorf("expected the new certificate after reloading, got %s", cn)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "key.pem"), []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.Reload(); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+
+	if cn := commonName(t, f); cn != "new" {
+		t.Errorf("expected the previous certificate to stay in use, got %s", cn)
+	}
+}
+
+func testRedirect(addr, host, expected string) func(*testing.T) {
+	return func(t *testing.T) {
+		s := &Server{h: http.Server{Addr: addr}}
+
+		r := httptest.NewRequest(http.MethodGet, "http://"+host+"/registry/a?x=1", nil)
+		w := httptest.NewRecorder()
+		s.redirectToHTTPS(w, r)
+
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != expected {
+			t.Errorf("expected a redirect to %s, got %d %s", expected, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	t.Run("default port", testRedirect(":443", "frontend:80", "https://frontend/registry/a?x=1"))
+	t.Run("other port", testRedirect(":8443", "frontend", "https://frontend:8443/registry/a?x=1"))
+}
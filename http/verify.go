@@ -0,0 +1,76 @@
+package http
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+	"github.com/mikaellindemann/registryfrontend/authz"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/pkg/errors"
+)
+
+// verifyRepository checks the manifests and blobs of every tag of a repository, and reports the missing or corrupt ones.
+func (s *Server) verifyRepository() (http.HandlerFunc, error) {
+	return s.t.Load(
+		"layout",
+		func(t *template.Template, w http.ResponseWriter, r *http.Request) {
+			vars := mux.Vars(r)
+
+			repoName, err := url.PathUnescape(vars["repo"])
+
+			if err != nil {
+				s.error(w, r, http.StatusBadRequest, err)
+				return
+			}
+
+			if s.role(r, vars["registry"], repoName) < authz.Viewer {
+				s.error(w, r, http.StatusNotFound, errAccessDenied)
+				return
+			}
+
+			reg, err := s.s.Registry(vars["registry"])
+
+			if err != nil {
+				s.error(w, r, http.StatusNotFound, err)
+				return
+			}
+
+			report, err := client.Verify(r.Context(), reg, repoName)
+
+			if err != nil {
+				s.error(w, r, http.StatusBadGateway, errors.Wrap(err, "failed verifying repository"))
+				return
+			}
+
+			vm := viewmodels.VerifyReport{
+				Layout:        newLayout(w, r, "Verify"),
+				Registry:      vars["registry"],
+				Repository:    repoName,
+				UrlRepository: template.URLQueryEscaper(vars["repo"]),
+				Tags:          report.Tags,
+				Manifests:     report.Manifests,
+				Blobs:         report.Blobs,
+			}
+
+			for _, p := range report.Problems {
+				vm.Problems = append(vm.Problems, viewmodels.VerifyProblem{
+					Tag:         p.Tag,
+					Kind:        p.Kind,
+					Digest:      p.Digest.String(),
+					ShortDigest: shortDigest(p.Digest),
+					Message:     p.Message,
+				})
+			}
+
+			err = t.Execute(w, vm)
+
+			if err != nil {
+				s.l.Errorf("%+v", err)
+			}
+		},
+		"templates/verify.tmpl", "templates/layout.tmpl", "templates/menu/menu-tags.tmpl",
+	)
+}
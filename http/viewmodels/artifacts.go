@@ -0,0 +1,31 @@
+package viewmodels
+
+// Artifact describes a manifest that is not a container image, e.g. a Helm chart or an SBOM.
+type Artifact struct {
+	Kind         string
+	MediaType    string
+	ArtifactType string
+	// SubjectDigest is the digest of the manifest the artifact refers to, e.g. the image an SBOM describes.
+	SubjectDigest string
+	Layers        []Descriptor
+	Annotations   []Annotation
+	// Chart is nil unless the artifact is a Helm chart.
+	Chart *Chart
+}
+
+// Descriptor is a layer of an artifact, or a referrer of an image or artifact.
+type Descriptor struct {
+	Kind         string
+	MediaType    string
+	ArtifactType string
+	Digest       string
+	ShortDigest  string
+	Size         string
+	// Title is the org.opencontainers.image.title annotation, e.g. the file name of a layer.
+	Title string
+}
+
+type Annotation struct {
+	Key   string
+	Value string
+}
@@ -0,0 +1,31 @@
+package viewmodels
+
+type AuditEntry struct {
+	Time       string
+	Actor      string
+	SourceIP   string
+	Action     string
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+	Parameters map[string]string
+	Outcome    string
+	Error      string
+}
+
+type Audit struct {
+	Layout
+	AdminMenu
+	Actor      string
+	Action     string
+	Registry   string
+	Repository string
+	Outcome    string
+	Since      string
+	Until      string
+	Query      string
+	Actions    []string
+	Outcomes   []string
+	Entries    []AuditEntry
+}
@@ -0,0 +1,31 @@
+package viewmodels
+
+// Chart is the metadata of a Helm chart, and in the tag details its README and default values.
+type Chart struct {
+	Name         string
+	Version      string
+	AppVersion   string
+	Description  string
+	Type         string
+	KubeVersion  string
+	Home         string
+	Keywords     []string
+	Sources      []string
+	Deprecated   bool
+	Maintainers  []ChartMaintainer
+	Dependencies []ChartDependency
+	Readme       string
+	Values       string
+}
+
+type ChartMaintainer struct {
+	Name  string
+	Email string
+	URL   string
+}
+
+type ChartDependency struct {
+	Name       string
+	Version    string
+	Repository string
+}
@@ -0,0 +1,7 @@
+package viewmodels
+
+type Error struct {
+	Layout
+	Status  int
+	Message string
+}
@@ -1,5 +1,19 @@
 package viewmodels
 
+// Layout contains the values used by the shared layout template.
+// It is embedded in the view model of every page.
 type Layout struct {
 	Title string
+	// BasePath is the path the frontend is reached at, without a trailing slash. Links must start with it.
+	BasePath  string
+	User      string
+	CSRFToken string
+	Flash     *Flash
+}
+
+// Flash is a one-time message shown at the top of the page, e.g. after submitting a form.
+// Kind is a Bootstrap alert type, like success or danger.
+type Flash struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
 }
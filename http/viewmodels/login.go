@@ -0,0 +1,9 @@
+package viewmodels
+
+type Login struct {
+	Layout
+	PasswordEnabled bool
+	OIDCEnabled     bool
+	Next            string
+	Error           string
+}
@@ -1,14 +1,29 @@
 package viewmodels
 
 type Registry struct {
-	Name          string
-	URL           string
+	Name string
+	URL  string
+	// Kind names the kind of hosted registry, e.g. Docker Hub, and is empty for self-hosted registries.
+	Kind          string
 	Online        bool
 	NumberOfRepos int
+	CanRemove     bool
+	TLS           *TLS
+}
+
+// TLS describes the certificate of a registry. ExpiresSoon is set when it expires within 30 days.
+type TLS struct {
+	Subject     string
+	Issuer      string
+	Expires     string
+	Expired     bool
+	ExpiresSoon bool
+	Verified    bool
 }
 
 type Overview struct {
-	Title            string
+	Layout
 	Registries       []Registry
 	AddRemoveEnabled bool
+	CanExport        bool
 }
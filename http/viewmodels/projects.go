@@ -0,0 +1,26 @@
+package viewmodels
+
+// Project groups repositories in registries such as Harbor.
+type Project struct {
+	Name         string
+	Public       bool
+	Repositories int
+	// Quota is Unlimited if the project has no storage quota.
+	Quota string
+	Used  string
+	// Percent is the share of the quota used, or -1 if the storage is unlimited.
+	Percent int
+}
+
+// ArtifactStatus is what the registry knows about the use and scanning of an image.
+type ArtifactStatus struct {
+	Pushed string
+	// Pulled is Never if the image was never pulled.
+	Pulled string
+	// ScanStatus is empty if the image was never scanned.
+	ScanStatus      string
+	Severity        string
+	Vulnerabilities Severities
+	Total           int
+	Fixable         int
+}
@@ -0,0 +1,28 @@
+package viewmodels
+
+// RegistryForm is used both for adding and editing a registry.
+// The password and client key are never sent back to the browser,
+// HasPassword and HasClientKey only tell whether they are set.
+type RegistryForm struct {
+	Layout
+	Edit               bool
+	Name               string
+	URL                string
+	User               string
+	HasPassword        bool
+	CACert             string
+	ClientCert         string
+	HasClientKey       bool
+	InsecureSkipVerify bool
+	Kind               string
+	Kinds              []RegistryKind
+	Namespace          string
+	API                string
+	// Repositories are the repositories of the allowlist, one per line.
+	Repositories string
+}
+
+type RegistryKind struct {
+	Value string
+	Name  string
+}
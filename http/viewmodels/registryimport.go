@@ -0,0 +1,33 @@
+package viewmodels
+
+// RegistryImport previews the registries imported from a Docker configuration, or from a bundle exported by a frontend.
+// The pasted Payload is sent back with the preview, so applying it imports the same registries.
+// The passphrase of bundles is never sent back, and must be entered again.
+type RegistryImport struct {
+	Layout
+	Bundle  bool
+	Payload string
+	// HasFile tells whether a mounted file can be imported, and FromFile whether it was chosen.
+	HasFile   bool
+	FromFile  bool
+	Previewed bool
+	Error     string
+	Changes   []RegistryChange
+}
+
+type RegistryChange struct {
+	Name        string
+	URL         string
+	Kind        string
+	User        string
+	HasPassword bool
+	// Action is add, update, unchanged, conflict or invalid.
+	Action  string
+	Warning string
+}
+
+// RegistryExport is the form exporting the registries. CanExportPasswords tells whether credentials can be included.
+type RegistryExport struct {
+	Layout
+	CanExportPasswords bool
+}
@@ -4,10 +4,20 @@ type Repository struct {
 	Name         string
 	UrlName      string
 	NumberOfTags int
+	// NewestTag is the tag considered newest, and Critical the number of its critical vulnerabilities.
+	NewestTag string
+	Critical  int
+	// Description and PullCount are only known for registries that describe their repositories, e.g. Harbor.
+	Description string
+	PullCount   int64
 }
 
 type RegistryDetail struct {
-	Title        string
+	Layout
 	Registry     string
 	Repositories []Repository
+	// Projects are the projects of the repositories, for registries organizing repositories into projects.
+	Projects []Project
+	// Described is true if the registry describes its repositories, to show their descriptions and pull counts.
+	Described bool
 }
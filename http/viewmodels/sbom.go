@@ -0,0 +1,37 @@
+package viewmodels
+
+// SBOM is an SBOM attached to an image.
+type SBOM struct {
+	Format   string
+	Source   string
+	Packages int
+}
+
+// Package is a package listed by the SBOMs of an image.
+type Package struct {
+	Name    string
+	Version string
+	License string
+	PURL    string
+}
+
+// PackageMatch is an image containing a package that was searched for.
+type PackageMatch struct {
+	Registry      string
+	Repository    string
+	UrlRepository string
+	Digest        string
+	ShortDigest   string
+	Package       Package
+}
+
+type PackageSearch struct {
+	Layout
+	Package string
+	Version string
+	// Searched is false until a package is given.
+	Searched bool
+	// Images is the number of indexed images searched.
+	Images  int
+	Matches []PackageMatch
+}
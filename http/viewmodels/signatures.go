@@ -0,0 +1,20 @@
+package viewmodels
+
+// Signature is a cosign signature of an image.
+type Signature struct {
+	Source   string
+	Identity string
+	Signer   string
+	Key      string
+	Verified bool
+	Problem  string
+}
+
+// Attestation is a cosign attestation of an image, e.g. SLSA provenance.
+type Attestation struct {
+	Source        string
+	PredicateType string
+	Key           string
+	Verified      bool
+	Problem       string
+}
@@ -0,0 +1,19 @@
+package viewmodels
+
+// AdminMenu holds the pages shown in the menu of the admin pages.
+type AdminMenu struct {
+	AuditEnabled bool
+}
+
+type Status struct {
+	Layout
+	AdminMenu
+	Loaded   bool
+	Time     string
+	Source   string
+	Error    string
+	Added    []string
+	Updated  []string
+	Removed  []string
+	Rejected []string
+}
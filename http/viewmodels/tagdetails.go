@@ -1,11 +1,14 @@
 package viewmodels
 
 type TagDetails struct {
-	Title         string
+	Layout
 	Registry      string
 	Repository    string
 	UrlRepository string
+	// Tag is empty when the image is browsed by digest.
 	Tag           string
+	Digest        string
+	ShortDigest   string
 	Created       string
 	DockerVersion string
 	Size          string
@@ -13,4 +16,22 @@ type TagDetails struct {
 	User          string
 	Ports         string
 	Volumes       string
+	// Signature is verified, unverified or unsigned, or empty if the signatures could not be found.
+	Signature    string
+	Signatures   []Signature
+	Attestations []Attestation
+	// Artifact is set when the manifest is not a container image, whose details are then left empty.
+	Artifact *Artifact
+	// Status is nil unless the registry tracks the use and scanning of images, e.g. Harbor.
+	Status    *ArtifactStatus
+	Referrers []Descriptor
+	SBOMs     []SBOM
+	// Packages are the packages of the SBOMs, filtered by PackageQuery.
+	Packages     []Package
+	PackageQuery string
+	// Severities is nil if the image has no vulnerability reports.
+	Severities           *Severities
+	Vulnerabilities      []Vulnerability
+	VulnerabilityReports []VulnerabilityReport
+	CanDelete            bool
 }
@@ -1,16 +1,31 @@
 package viewmodels
 
-type TagOverviewInfo struct {
-	Name    string
-	Created string
-	Size    string
-	Layers  int
+// TagGroup is an image, and the tags pointing to it.
+type TagGroup struct {
+	Tags []string
+	// Digest is the digest of the manifest, or empty if the tags could not be resolved.
+	Digest      string
+	ShortDigest string
+	Created     string
+	Size        string
+	Layers      int
+	// Kind names the type of artifact, e.g. Helm chart, and is empty for container images.
+	Kind string
+	// Chart is the metadata of a Helm chart, without its README and values, and is nil for other artifacts.
+	Chart *Chart
+	// Vulnerabilities is nil if the image has no vulnerability reports.
+	Vulnerabilities *Severities
+	// Signature is verified, unverified or unsigned, or empty if the signatures could not be found.
+	Signature string
 }
 
 type TagOverview struct {
-	Title         string
+	Layout
 	Registry      string
 	Repository    string
 	UrlRepository string
-	Tags          []TagOverviewInfo
+	Images        []TagGroup
+	// Charts is true if any of the images is a Helm chart, to show their chart and app versions.
+	Charts    bool
+	CanDelete bool
 }
@@ -0,0 +1,21 @@
+package viewmodels
+
+// VerifyProblem is a missing or corrupt manifest or blob.
+type VerifyProblem struct {
+	Tag         string
+	Kind        string
+	Digest      string
+	ShortDigest string
+	Message     string
+}
+
+type VerifyReport struct {
+	Layout
+	Registry      string
+	Repository    string
+	UrlRepository string
+	Tags          int
+	Manifests     int
+	Blobs         int
+	Problems      []VerifyProblem
+}
@@ -0,0 +1,26 @@
+package viewmodels
+
+// Severities are the number of vulnerabilities of each severity.
+type Severities struct {
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+	Unknown  int
+}
+
+type Vulnerability struct {
+	ID               string
+	Package          string
+	InstalledVersion string
+	FixedVersion     string
+	Severity         string
+	Title            string
+	URL              string
+}
+
+// VulnerabilityReport describes where a vulnerability report was found.
+type VulnerabilityReport struct {
+	Scanner string
+	Source  string
+}
@@ -0,0 +1,153 @@
+package http
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/cosign"
+	"github.com/mikaellindemann/registryfrontend/http/viewmodels"
+	"github.com/mikaellindemann/registryfrontend/vuln"
+	"github.com/opencontainers/go-digest"
+	"github.com/sirupsen/logrus"
+)
+
+// WithVulnerabilityReports shows the vulnerability reports in dir, in addition to the reports attested for images.
+func WithVulnerabilityReports(dir *vuln.Directory) Option {
+	return func(s *Server) {
+		s.vulns = vuln.NewStore(dir, time.Hour)
+	}
+}
+
+// vulnerabilities returns the vulnerability reports of an image. Errors are logged, and result in no reports.
+func (s *Server) vulnerabilities(ctx context.Context, reg registryfrontend.Client, registry, repository string, d digest.Digest) []*vuln.Report {
+	if d == "" {
+		return nil
+	}
+
+	reports, err := s.vulns.Reports(ctx, reg, repository, d)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": registry, "repository": repository, "digest": d}).Warnf("Failed finding vulnerability reports: %+v", err)
+		return nil
+	}
+
+	return reports
+}
+
+// severities counts the vulnerabilities of the reports, and is nil if there are no reports.
+func severities(reports []*vuln.Report) *viewmodels.Severities {
+	if len(reports) == 0 {
+		return nil
+	}
+
+	c := viewmodels.Severities(vuln.Summarize(reports))
+	return &c
+}
+
+// vulnerabilityViews lists the vulnerabilities of the reports, the most severe first.
+func vulnerabilityViews(reports []*vuln.Report) ([]viewmodels.Vulnerability, []viewmodels.VulnerabilityReport) {
+	vulns := vuln.Merge(reports)
+
+	sort.SliceStable(vulns, func(i, j int) bool {
+		if vulns[i].Severity != vulns[j].Severity {
+			return vulns[i].Severity > vulns[j].Severity
+		}
+		return vulns[i].ID < vulns[j].ID
+	})
+
+	views := make([]viewmodels.Vulnerability, 0, len(vulns))
+
+	for _, v := range vulns {
+		views = append(views, viewmodels.Vulnerability{
+			ID:               v.ID,
+			Package:          v.Package,
+			InstalledVersion: v.InstalledVersion,
+			FixedVersion:     v.FixedVersion,
+			Severity:         v.Severity.String(),
+			Title:            v.Title,
+			URL:              v.URL,
+		})
+	}
+
+	sources := make([]viewmodels.VulnerabilityReport, 0, len(reports))
+
+	for _, r := range reports {
+		sources = append(sources, viewmodels.VulnerabilityReport{Scanner: r.Scanner, Source: r.Source})
+	}
+
+	return views, sources
+}
+
+// newestCritical returns the newest of the tags, and the number of critical vulnerabilities of its image.
+func (s *Server) newestCritical(ctx context.Context, reg registryfrontend.Client, repository string, tags []string) (string, int) {
+	tag := newestTag(tags)
+
+	if tag == "" {
+		return "", 0
+	}
+
+	d, err := reg.Digest(ctx, repository, tag)
+
+	if err != nil {
+		s.l.WithFields(logrus.Fields{"registry": reg.Name(), "repository": repository, "tag": tag}).Warnf("Failed resolving digest: %+v", err)
+		return tag, 0
+	}
+
+	return tag, vuln.Summarize(s.vulnerabilities(ctx, reg, reg.Name(), repository, d)).Critical
+}
+
+// newestTag returns latest if it is one of the tags, or else the highest version, comparing numbers by value.
+// Tags of signatures, attestations and SBOMs are left out.
+func newestTag(tags []string) string {
+	newest := ""
+
+	for _, t := range tags {
+		if t == "latest" {
+			return t
+		}
+
+		if !cosign.IsArtifactTag(t) && (newest == "" || versionLess(newest, t)) {
+			newest = t
+		}
+	}
+
+	return newest
+}
+
+// versionLess compares the tags as versions, e.g. 1.9 < 1.10, by comparing runs of digits as numbers.
+func versionLess(a, b string) bool {
+	for a != "" && b != "" {
+		na, ra := leadingRun(a)
+		nb, rb := leadingRun(b)
+
+		if na != nb {
+			x, errX := strconv.ParseUint(na, 10, 64)
+			y, errY := strconv.ParseUint(nb, 10, 64)
+
+			if errX == nil && errY == nil && x != y {
+				return x < y
+			}
+
+			return na < nb
+		}
+
+		a, b = ra, rb
+	}
+
+	return len(a) < len(b)
+}
+
+// leadingRun splits s after its leading run of digits, or of other characters.
+func leadingRun(s string) (string, string) {
+	digit := func(c byte) bool { return c >= '0' && c <= '9' }
+	i := 1
+
+	for i < len(s) && digit(s[i]) == digit(s[0]) {
+		i++
+	}
+
+	return s[:i], s[i:]
+}
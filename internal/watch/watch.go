@@ -0,0 +1,29 @@
+// Package watch reloads files when they change, by polling them.
+package watch
+
+import (
+	"context"
+	"time"
+)
+
+// Poll calls changed every interval until ctx is done, and reload whenever changed reports a change.
+// The result of every reload, and the errors of changed, are passed to onReload.
+func Poll(ctx context.Context, interval time.Duration, changed func() (bool, error), reload func() error, onReload func(error)) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			ok, err := changed()
+
+			if err != nil {
+				onReload(err)
+			} else if ok {
+				onReload(reload())
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package sbom
+
+import (
+	"context"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+)
+
+// Cache keeps the SBOMs of images in memory, so viewing an image again does not fetch every SBOM again.
+// Entries expire after the ttl, which picks up SBOMs attached to an image later.
+// The packages of every image whose SBOMs are cached are indexed, and can be searched using Index.
+type Cache struct {
+	docs  *client.AttachedCache
+	index *Index
+}
+
+// cachedDocs are the SBOMs of an image, which is removed from the index when they expire.
+type cachedDocs struct {
+	image Image
+	docs  []*Document
+}
+
+func NewCache(ttl time.Duration) *Cache {
+	c := &Cache{index: NewIndex()}
+	c.docs = client.NewAttachedCache(ttl, func(value interface{}) {
+		c.index.Remove(value.(cachedDocs).image)
+	})
+
+	return c
+}
+
+// Index returns the index of the packages of the images whose SBOMs are cached.
+func (c *Cache) Index() *Index {
+	c.docs.Expire()
+	return c.index
+}
+
+// Get returns the SBOMs of the image with digest d, fetching them if they are not cached.
+func (c *Cache) Get(ctx context.Context, reg registryfrontend.Client, repository string, d digest.Digest) ([]*Document, error) {
+	cached, err := c.docs.Get(reg, repository, d, func() (interface{}, error) {
+		docs, err := Find(ctx, reg, repository, d)
+
+		if err != nil {
+			return nil, err
+		}
+
+		img := Image{Registry: reg.Name(), Repository: repository, Digest: d}
+		c.index.Add(img, docs)
+
+		return cachedDocs{image: img, docs: docs}, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cached.(cachedDocs).docs, nil
+}
@@ -0,0 +1,122 @@
+package sbom
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// mediaTypes are the media and artifact types of SBOMs in JSON.
+var mediaTypes = map[string]bool{
+	"application/spdx+json":          true,
+	"text/spdx+json":                 true,
+	"application/vnd.cyclonedx+json": true,
+}
+
+// artifactTypes are the artifact types of referrers that are SBOMs, or attestations that may be SBOMs.
+var artifactTypes = map[string]bool{
+	"application/spdx+json":                           true,
+	"application/vnd.cyclonedx+json":                  true,
+	"application/vnd.dev.cosign.artifact.att.v1+json": true,
+	"application/vnd.in-toto+json":                    true,
+	client.DSSEMediaType:                              true,
+}
+
+// predicateTypes are the in-toto predicate types of SBOMs.
+var predicateTypes = map[string]bool{
+	"https://spdx.dev/Document":  true,
+	"https://cyclonedx.org/bom":  true,
+	"https://cyclonedx.org/bom/": true,
+}
+
+// Find fetches and parses the SBOMs attached to the image with digest d. Documents in other formats are left out.
+func Find(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest) ([]*Document, error) {
+	layers, err := client.Attached(ctx, c, repository, d, []string{".sbom", ".att"}, artifactTypes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*Document
+
+	for _, l := range layers {
+		doc, err := document(ctx, c, repository, l.Descriptor)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading SBOM of %s", l.Source)
+		} else if doc != nil {
+			doc.Source = l.Source
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// document reads the SBOM of a layer, which is nil if the layer is not an SBOM.
+func document(ctx context.Context, c registryfrontend.Client, repository string, layer registryfrontend.Descriptor) (*Document, error) {
+	switch {
+	case mediaTypes[layer.MediaType]:
+		content, err := client.ReadBlob(ctx, c, repository, layer, client.MaxAttestationSize)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return Parse(content)
+	case layer.MediaType == client.DSSEMediaType:
+		// Cosign annotates attestation layers with their predicate type, so other attestations are not fetched.
+		if t := layer.Annotations["predicateType"]; t != "" && !predicateTypes[t] {
+			return nil, nil
+		}
+
+		content, err := client.ReadBlob(ctx, c, repository, layer, client.MaxAttestationSize)
+
+		if err != nil {
+			return nil, err
+		}
+
+		predicate, ok := attestedDocument(content)
+
+		if !ok {
+			return nil, nil
+		}
+
+		return Parse(predicate)
+	}
+
+	return nil, nil
+}
+
+// attestedDocument returns the predicate of an in-toto attestation in a DSSE envelope, if it is an SBOM.
+func attestedDocument(content []byte) ([]byte, bool) {
+	env := struct {
+		Payload string `json:"payload"`
+	}{}
+
+	if json.Unmarshal(content, &env) != nil {
+		return nil, false
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+
+	if err != nil {
+		return nil, false
+	}
+
+	statement := struct {
+		PredicateType string          `json:"predicateType"`
+		Predicate     json.RawMessage `json:"predicate"`
+	}{}
+
+	if json.Unmarshal(payload, &statement) != nil || !predicateTypes[statement.PredicateType] {
+		return nil, false
+	}
+
+	return statement.Predicate, true
+}
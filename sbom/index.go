@@ -0,0 +1,149 @@
+package sbom
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/opencontainers/go-digest"
+)
+
+// Image is an image whose SBOMs are indexed.
+type Image struct {
+	Registry   string
+	Repository string
+	Digest     digest.Digest
+}
+
+// Match is a package found in the SBOMs of an image.
+type Match struct {
+	Image
+	Package Package
+}
+
+// Index finds the images containing a package by its name, from the SBOMs added to it,
+// so searches do not walk every registry, repository and tag.
+type Index struct {
+	mu       sync.RWMutex
+	packages map[Image][]Package
+	// images are the images with a package, by the lower case package name and name in its PURL.
+	images map[string]map[Image]bool
+}
+
+func NewIndex() *Index {
+	return &Index{packages: make(map[Image][]Package), images: make(map[string]map[Image]bool)}
+}
+
+// Add indexes the packages of the SBOMs of the image, replacing those indexed before.
+func (i *Index) Add(img Image, docs []*Document) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(img)
+
+	seen := make(map[Package]bool)
+	packages := []Package{}
+
+	for _, d := range docs {
+		for _, p := range d.Packages {
+			if seen[p] {
+				continue
+			}
+
+			seen[p] = true
+			packages = append(packages, p)
+
+			for _, name := range indexNames(p) {
+				if i.images[name] == nil {
+					i.images[name] = make(map[Image]bool)
+				}
+				i.images[name][img] = true
+			}
+		}
+	}
+
+	i.packages[img] = packages
+}
+
+// Remove removes the packages of the image from the index.
+func (i *Index) Remove(img Image) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.remove(img)
+}
+
+// remove removes the packages of the image. The caller must hold the lock.
+func (i *Index) remove(img Image) {
+	for _, p := range i.packages[img] {
+		for _, name := range indexNames(p) {
+			delete(i.images[name], img)
+
+			if len(i.images[name]) == 0 {
+				delete(i.images, name)
+			}
+		}
+	}
+
+	delete(i.packages, img)
+}
+
+// Images returns the indexed images.
+func (i *Index) Images() []Image {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	res := make([]Image, 0, len(i.packages))
+
+	for img := range i.packages {
+		res = append(res, img)
+	}
+
+	return res
+}
+
+// Lookup returns the packages of the indexed images with the name, and the version unless it is empty,
+// ordered by registry, repository and digest. The name is compared case-insensitively with both the name
+// of the packages and the name in their PURL.
+func (i *Index) Lookup(name, version string) []Match {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var res []Match
+
+	for img := range i.images[strings.ToLower(name)] {
+		for _, p := range i.packages[img] {
+			if (strings.EqualFold(p.Name, name) || strings.EqualFold(purlName(p.PURL), name)) && (version == "" || p.Version == version) {
+				res = append(res, Match{Image: img, Package: p})
+			}
+		}
+	}
+
+	sort.SliceStable(res, func(a, b int) bool {
+		x, y := res[a], res[b]
+
+		if x.Registry != y.Registry {
+			return x.Registry < y.Registry
+		}
+		if x.Repository != y.Repository {
+			return x.Repository < y.Repository
+		}
+		if x.Digest != y.Digest {
+			return x.Digest < y.Digest
+		}
+		return x.Package.Version < y.Package.Version
+	})
+
+	return res
+}
+
+// indexNames returns the names a package is found by.
+func indexNames(p Package) []string {
+	name, purl := strings.ToLower(p.Name), strings.ToLower(purlName(p.PURL))
+
+	if purl == "" || purl == name {
+		return []string{name}
+	}
+
+	return []string{name, purl}
+}
@@ -0,0 +1,196 @@
+// Package sbom finds the software bills of materials attached to images, and parses the packages they list.
+//
+// SPDX and CycloneDX documents in JSON are supported, whether attached by cosign attach sbom, pushed as referrers,
+// or wrapped in in-toto attestations.
+package sbom
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	SPDX      = "SPDX"
+	CycloneDX = "CycloneDX"
+)
+
+// Package is a package listed by an SBOM.
+type Package struct {
+	Name    string
+	Version string
+	License string
+	// PURL is the package URL, e.g. pkg:deb/debian/openssl@1.1.1n-0+deb11u3.
+	PURL string
+}
+
+// Document is a parsed SBOM.
+type Document struct {
+	// Format is SPDX or CycloneDX.
+	Format string
+	// Source is the tag the SBOM was found by, or referrers if it was found by the referrers API.
+	Source   string
+	Packages []Package
+}
+
+// Matches reports whether the package name or PURL contains query, ignoring case.
+func (p Package) Matches(query string) bool {
+	query = strings.ToLower(query)
+	return strings.Contains(strings.ToLower(p.Name), query) || strings.Contains(strings.ToLower(p.PURL), query)
+}
+
+// Parse parses an SPDX or CycloneDX document in JSON.
+func Parse(content []byte) (*Document, error) {
+	format := struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}{}
+
+	if err := json.Unmarshal(content, &format); err != nil {
+		return nil, errors.Wrap(err, "could not parse SBOM")
+	}
+
+	switch {
+	case format.SPDXVersion != "":
+		return parseSPDX(content)
+	case format.BOMFormat == CycloneDX:
+		return parseCycloneDX(content)
+	}
+
+	return nil, errors.New("the document is neither SPDX nor CycloneDX")
+}
+
+type spdxDocument struct {
+	Packages []struct {
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+		ExternalRefs     []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+func parseSPDX(content []byte) (*Document, error) {
+	doc := spdxDocument{}
+
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not parse SPDX document")
+	}
+
+	res := &Document{Format: SPDX, Packages: make([]Package, 0, len(doc.Packages))}
+
+	for _, p := range doc.Packages {
+		pkg := Package{Name: p.Name, Version: p.VersionInfo, License: spdxLicense(p.LicenseDeclared)}
+
+		if pkg.License == "" {
+			pkg.License = spdxLicense(p.LicenseConcluded)
+		}
+
+		for _, ref := range p.ExternalRefs {
+			if ref.ReferenceType == "purl" {
+				pkg.PURL = ref.ReferenceLocator
+				break
+			}
+		}
+
+		res.Packages = append(res.Packages, pkg)
+	}
+
+	return res, nil
+}
+
+// spdxLicense leaves out the NOASSERTION and NONE placeholders.
+func spdxLicense(l string) string {
+	if l == "NOASSERTION" || l == "NONE" {
+		return ""
+	}
+	return l
+}
+
+type cycloneDXComponent struct {
+	Name     string `json:"name"`
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	PURL     string `json:"purl"`
+	Licenses []struct {
+		License struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"license"`
+		Expression string `json:"expression"`
+	} `json:"licenses"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+func parseCycloneDX(content []byte) (*Document, error) {
+	doc := struct {
+		Components []cycloneDXComponent `json:"components"`
+	}{}
+
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not parse CycloneDX document")
+	}
+
+	res := &Document{Format: CycloneDX}
+	res.addComponents(doc.Components)
+
+	return res, nil
+}
+
+// addComponents adds the components and their nested components.
+func (d *Document) addComponents(components []cycloneDXComponent) {
+	for _, c := range components {
+		pkg := Package{Name: c.Name, Version: c.Version, PURL: c.PURL}
+
+		if c.Group != "" {
+			pkg.Name = c.Group + "/" + c.Name
+		}
+
+		licenses := make([]string, 0, len(c.Licenses))
+
+		for _, l := range c.Licenses {
+			switch {
+			case l.Expression != "":
+				licenses = append(licenses, l.Expression)
+			case l.License.ID != "":
+				licenses = append(licenses, l.License.ID)
+			case l.License.Name != "":
+				licenses = append(licenses, l.License.Name)
+			}
+		}
+
+		pkg.License = strings.Join(licenses, " OR ")
+
+		d.Packages = append(d.Packages, pkg)
+		d.addComponents(c.Components)
+	}
+}
+
+// Lookup returns the packages of the documents with the name, and the version unless it is empty.
+// The name is compared to the package name and the name in its PURL, ignoring case.
+func Lookup(docs []*Document, name, version string) []Package {
+	var res []Package
+
+	for _, d := range docs {
+		for _, p := range d.Packages {
+			if (strings.EqualFold(p.Name, name) || strings.EqualFold(purlName(p.PURL), name)) && (version == "" || p.Version == version) {
+				res = append(res, p)
+			}
+		}
+	}
+
+	return res
+}
+
+// purlName returns the name of a package URL, e.g. openssl for pkg:deb/debian/openssl@1.1.1n?arch=amd64.
+func purlName(purl string) string {
+	if i := strings.IndexAny(purl, "@?#"); i >= 0 {
+		purl = purl[:i]
+	}
+
+	return purl[strings.LastIndex(purl, "/")+1:]
+}
@@ -0,0 +1,151 @@
+package sbom
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+)
+
+const spdxDoc = `{"spdxVersion":"SPDX-2.3","packages":[
+	{"name":"openssl","versionInfo":"3.0.11","licenseDeclared":"NOASSERTION","licenseConcluded":"Apache-2.0",
+	 "externalRefs":[{"referenceCategory":"PACKAGE-MANAGER","referenceType":"purl","referenceLocator":"pkg:deb/debian/openssl@3.0.11?arch=amd64"}]},
+	{"name":"zlib","versionInfo":"1.2.13","licenseDeclared":"Zlib"}]}`
+
+const cycloneDXDoc = `{"bomFormat":"CycloneDX","specVersion":"1.5","components":[
+	{"name":"jackson-databind","group":"com.fasterxml.jackson.core","version":"2.15.2","purl":"pkg:maven/com.fasterxml.jackson.core/jackson-databind@2.15.2",
+	 "licenses":[{"license":{"id":"Apache-2.0"}}],
+	 "components":[{"name":"jackson-core","version":"2.15.2","licenses":[{"expression":"Apache-2.0 OR MIT"}]}]}]}`
+
+func TestParse(t *testing.T) {
+	parse := func(content, format string, expected []Package) func(*testing.T) {
+		return func(t *testing.T) {
+			doc, err := Parse([]byte(content))
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if doc.Format != format {
+				t.Errorf("expected format %s, got %s", format, doc.Format)
+			}
+			if fmt.Sprint(doc.Packages) != fmt.Sprint(expected) {
+				t.Errorf("expected packages %v, got %v", expected, doc.Packages)
+			}
+		}
+	}
+
+	t.Run("spdx", parse(spdxDoc, SPDX, []Package{
+		{"openssl", "3.0.11", "Apache-2.0", "pkg:deb/debian/openssl@3.0.11?arch=amd64"},
+		{"zlib", "1.2.13", "Zlib", ""},
+	}))
+	t.Run("cyclonedx", parse(cycloneDXDoc, CycloneDX, []Package{
+		{"com.fasterxml.jackson.core/jackson-databind", "2.15.2", "Apache-2.0", "pkg:maven/com.fasterxml.jackson.core/jackson-databind@2.15.2"},
+		{"jackson-core", "2.15.2", "Apache-2.0 OR MIT", ""},
+	}))
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := Parse([]byte(`{"predicateType":"https://slsa.dev/provenance/v0.2"}`)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
+
+func TestFind(t *testing.T) {
+	image := digest.FromString("image")
+	blobs := map[string]string{}
+	manifests := map[string]string{}
+
+	push := func(reference, mediaType, content string) digest.Digest {
+		d := digest.FromString(content)
+		blobs[d.String()] = content
+		m := fmt.Sprintf(`{"layers":[{"mediaType":"%s","digest":"%s","size":%d}]}`, mediaType, d, len(content))
+		manifests[reference], manifests[digest.FromString(m).String()] = m, m
+		return digest.FromString(m)
+	}
+
+	// The SPDX document is attached by cosign attach sbom, and the CycloneDX document is attested and found by the referrers API.
+	push("sha256-"+image.Encoded()+".sbom", "text/spdx+json", spdxDoc)
+	statement, _ := json.Marshal(map[string]interface{}{"predicateType": "https://cyclonedx.org/bom", "predicate": json.RawMessage(cycloneDXDoc)})
+	att := push("attestation", client.DSSEMediaType, fmt.Sprintf(`{"payload":"%s"}`, base64.StdEncoding.EncodeToString(statement)))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, "/v2/app/")
+		switch {
+		case p == "referrers/"+image.String():
+			fmt.Fprintf(w, `{"manifests":[{"artifactType":"application/vnd.dev.cosign.artifact.att.v1+json","digest":"%s"}]}`, att)
+		case strings.HasPrefix(p, "manifests/") && manifests[strings.TrimPrefix(p, "manifests/")] != "":
+			fmt.Fprint(w, manifests[strings.TrimPrefix(p, "manifests/")])
+		case strings.HasPrefix(p, "blobs/") && blobs[strings.TrimPrefix(p, "blobs/")] != "":
+			fmt.Fprint(w, blobs[strings.TrimPrefix(p, "blobs/")])
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	c, _ := client.New(registryfrontend.Registry{Name: "test", Url: srv.URL})
+
+	docs, err := NewCache(time.Hour).Get(context.Background(), c, "app", image)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if len(docs) != 2 || docs[0].Format != SPDX || docs[1].Format != CycloneDX || docs[1].Source != "referrers" {
+		t.Fatalf("expected an SPDX and a CycloneDX document, got %+v", docs)
+	}
+
+	if p := Lookup(docs, "OpenSSL", "3.0.11"); len(p) != 1 {
+		t.Errorf("expected openssl 3.0.11 to be found, got %v", p)
+	}
+	if p := Lookup(docs, "jackson-databind", ""); len(p) != 1 {
+		t.Errorf("expected jackson-databind to be found by its PURL, got %v", p)
+	}
+	if p := Lookup(docs, "zlib", "1.3"); len(p) != 0 {
+		t.Errorf("expected zlib 1.3 not to be found, got %v", p)
+	}
+
+	expiring := NewCache(time.Millisecond)
+	if _, err := expiring.Get(context.Background(), c, "app", image); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if images := expiring.Index().Images(); len(images) != 0 {
+		t.Errorf("expected the image to be removed from the index when its SBOMs expire, got %v", images)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	i := NewIndex()
+	app := Image{Registry: "reg", Repository: "app", Digest: digest.FromString("app")}
+	web := Image{Registry: "reg", Repository: "web", Digest: digest.FromString("web")}
+
+	openssl := Package{Name: "libssl3", Version: "3.0.2", PURL: "pkg:deb/ubuntu/openssl@3.0.2"}
+	i.Add(app, []*Document{{Packages: []Package{openssl, {Name: "zlib", Version: "1.2.13"}}}, {Packages: []Package{openssl}}})
+	i.Add(web, []*Document{{Packages: []Package{{Name: "OpenSSL", Version: "1.1.1"}}}})
+
+	if m := i.Lookup("openssl", ""); len(m) != 2 || m[0].Image != app || m[1].Image != web {
+		t.Errorf("expected openssl in app and web once each, got %+v", m)
+	}
+	if m := i.Lookup("openssl", "1.1.1"); len(m) != 1 || m[0].Image != web {
+		t.Errorf("expected openssl 1.1.1 in web, got %+v", m)
+	}
+
+	i.Add(app, nil)
+	if m := i.Lookup("zlib", ""); len(m) != 0 {
+		t.Errorf("expected the packages of app to be replaced, got %+v", m)
+	}
+	if len(i.Images()) != 2 {
+		t.Errorf("expected 2 indexed images, got %d", len(i.Images()))
+	}
+
+	i.Remove(web)
+	if m := i.Lookup("openssl", ""); len(m) != 0 || len(i.Images()) != 1 {
+		t.Errorf("expected web to be removed, got %+v", m)
+	}
+}
@@ -0,0 +1,269 @@
+// Package secret encrypts credentials stored by the frontend.
+//
+// Secrets are encrypted using envelope encryption: each secret is encrypted with its own random data key
+// using AES-256-GCM, and the data key is in turn encrypted with a key encryption key from the Keyring.
+// Secrets sealed with an old key encryption key are found with NeedsRotation, and sealed again with the primary key.
+package secret
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	version = "v1"
+	keySize = 32
+)
+
+var (
+	ErrUnknownKey = errors.New("secret was encrypted with an unknown key")
+	ErrMalformed  = errors.New("malformed encrypted secret")
+)
+
+// Keyring holds the key encryption keys.
+// New secrets are encrypted with the primary key, while older keys are kept for decrypting existing secrets.
+type Keyring struct {
+	primary string
+	keys    map[string][]byte
+}
+
+// NewKeyring creates a Keyring from 32 byte keys. The first key is the primary key.
+func NewKeyring(keys ...[]byte) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("no encryption keys")
+	}
+
+	k := &Keyring{keys: make(map[string][]byte)}
+
+	for i, key := range keys {
+		if len(key) != keySize {
+			return nil, errors.Errorf("encryption key %d must be %d bytes, was %d", i+1, keySize, len(key))
+		}
+
+		id := keyID(key)
+		if i == 0 {
+			k.primary = id
+		}
+		k.keys[id] = key
+	}
+
+	return k, nil
+}
+
+// ParseKeyring creates a Keyring from base64 encoded keys separated by commas or newlines.
+// Empty lines and lines starting with # are ignored. The first key is the primary key.
+func ParseKeyring(encoded string) (*Keyring, error) {
+	var keys [][]byte
+
+	scanner := bufio.NewScanner(strings.NewReader(strings.Replace(encoded, ",", "\n", -1)))
+
+	for scanner.Scan() {
+		l := strings.TrimSpace(scanner.Text())
+
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		key, err := base64.StdEncoding.DecodeString(l)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "encryption key %d is not valid base64", len(keys)+1)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewKeyring(keys...)
+}
+
+// LoadKeyring reads the keys from a file in the format accepted by ParseKeyring.
+func LoadKeyring(path string) (*Keyring, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed opening key file")
+	}
+	defer f.Close()
+
+	b := &strings.Builder{}
+	if _, err := io.Copy(b, f); err != nil {
+		return nil, errors.Wrap(err, "failed reading key file")
+	}
+
+	return ParseKeyring(b.String())
+}
+
+// GenerateKey returns a new random key, base64 encoded.
+func GenerateKey() (string, error) {
+	key := make([]byte, keySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", errors.Wrap(err, "failed generating key")
+	}
+
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// PassphraseKeyring creates a Keyring with a key derived from the passphrase and salt with scrypt,
+// e.g. to encrypt secrets leaving the frontend.
+func PassphraseKeyring(passphrase string, salt []byte) (*Keyring, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed deriving key from passphrase")
+	}
+
+	return NewKeyring(key)
+}
+
+// GenerateSalt returns a new random salt for PassphraseKeyring.
+func GenerateSalt() ([]byte, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errors.Wrap(err, "failed generating salt")
+	}
+
+	return salt, nil
+}
+
+// keyID identifies a key without revealing it.
+func keyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// Seal encrypts the plaintext. The result has the form v1:<key id>:<encrypted data key>:<ciphertext>.
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	dek := make([]byte, keySize)
+
+	if _, err := rand.Read(dek); err != nil {
+		return "", errors.Wrap(err, "failed generating data key")
+	}
+
+	ciphertext, err := encrypt(dek, []byte(plaintext))
+
+	if err != nil {
+		return "", err
+	}
+
+	wrapped, err := encrypt(k.keys[k.primary], dek)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Join([]string{
+		version,
+		k.primary,
+		base64.RawStdEncoding.EncodeToString(wrapped),
+		base64.RawStdEncoding.EncodeToString(ciphertext),
+	}, ":"), nil
+}
+
+// Open decrypts a secret created by Seal.
+func (k *Keyring) Open(sealed string) (string, error) {
+	dek, ciphertext, err := k.unwrap(sealed)
+
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := decrypt(dek, ciphertext)
+
+	return string(plaintext), err
+}
+
+// NeedsRotation reports whether the secret is encrypted with a key other than the primary key.
+func (k *Keyring) NeedsRotation(sealed string) bool {
+	parts := strings.Split(sealed, ":")
+	return len(parts) != 4 || parts[1] != k.primary
+}
+
+func (k *Keyring) unwrap(sealed string) (dek, ciphertext []byte, err error) {
+	parts := strings.Split(sealed, ":")
+
+	if len(parts) != 4 || parts[0] != version {
+		return nil, nil, ErrMalformed
+	}
+
+	kek, ok := k.keys[parts[1]]
+
+	if !ok {
+		return nil, nil, ErrUnknownKey
+	}
+
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, nil, ErrMalformed
+	}
+
+	ciphertext, err = base64.RawStdEncoding.DecodeString(parts[3])
+
+	if err != nil {
+		return nil, nil, ErrMalformed
+	}
+
+	dek, err = decrypt(kek, wrapped)
+
+	return dek, ciphertext, err
+}
+
+// encrypt returns the nonce followed by the AES-GCM ciphertext.
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed generating nonce")
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func decrypt(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, ErrMalformed
+	}
+
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+
+	return plaintext, errors.Wrap(err, "failed decrypting secret")
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,63 @@
+package secret
+
+import (
+	"strings"
+	"testing"
+)
+
+func mustKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	k, err := ParseKeyring(strings.Join(keys, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyring(t *testing.T) {
+	oldKey, _ := GenerateKey()
+	newKey, _ := GenerateKey()
+
+	old := mustKeyring(t, oldKey)
+	sealed, err := old.Seal("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "hunter2") {
+		t.Fatal("plaintext visible in sealed secret")
+	}
+
+	if p, err := old.Open(sealed); err != nil || p != "hunter2" {
+		t.Errorf("expected hunter2, got %q %v", p, err)
+	}
+
+	rotated := mustKeyring(t, newKey, oldKey)
+	if !rotated.NeedsRotation(sealed) {
+		t.Error("expected secret sealed with old key to need rotation")
+	}
+
+	resealed, err := rotated.Seal("hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.NeedsRotation(resealed) {
+		t.Error("expected rotated secret to use the primary key")
+	}
+
+	if p, err := mustKeyring(t, newKey).Open(resealed); err != nil || p != "hunter2" {
+		t.Errorf("expected rotated secret to open without the old key, got %q %v", p, err)
+	}
+
+	if _, err := mustKeyring(t, newKey).Open(sealed); err != ErrUnknownKey {
+		t.Errorf("expected ErrUnknownKey, got %v", err)
+	}
+
+	tampered := sealed[:len(sealed)-2] + "AA"
+	if _, err := old.Open(tampered); err == nil {
+		t.Error("expected tampered secret to be rejected")
+	}
+
+	if _, err := ParseKeyring("c2hvcnQ="); err == nil {
+		t.Error("expected short key to be rejected")
+	}
+}
@@ -0,0 +1,60 @@
+package secret
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces secrets in output.
+const Redacted = "REDACTED"
+
+var secretWords = []string{"password", "secret", "token", "credential", "client_key", "private_key"}
+
+// IsSecretName reports whether a field or parameter with the given name is expected to hold a secret.
+func IsSecretName(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, w := range secretWords {
+		if strings.Contains(name, w) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RedactURL removes any password included in the URL.
+func RedactURL(u string) string {
+	parsed, err := url.Parse(u)
+
+	if err != nil || parsed.User == nil {
+		return u
+	}
+
+	if _, ok := parsed.User.Password(); ok {
+		parsed.User = url.UserPassword(parsed.User.Username(), Redacted)
+	}
+
+	return parsed.String()
+}
+
+// RedactHook is a logrus hook that replaces the values of fields named like secrets.
+type RedactHook struct{}
+
+func (RedactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (RedactHook) Fire(e *logrus.Entry) error {
+	for k, v := range e.Data {
+		if IsSecretName(k) {
+			e.Data[k] = Redacted
+		} else if s, ok := v.(string); ok && strings.Contains(s, "://") {
+			e.Data[k] = RedactURL(s)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,290 @@
+package storage
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// bundleVersion is the version of the bundle format written by Export.
+const bundleVersion = 1
+
+// ErrPassphraseRequired is returned when importing a bundle with encrypted credentials without a passphrase.
+var ErrPassphraseRequired = errors.New("the credentials of the bundle are encrypted, a passphrase is required")
+
+// Bundle is an export of the registries of a storage, to import them into another frontend.
+// Credentials are either in plain text, encrypted with a key derived from a passphrase, or left out.
+type Bundle struct {
+	Version int `json:"version" yaml:"version"`
+	// Salt derives the key encrypting the credentials from the passphrase, if they are encrypted.
+	Salt string `json:"salt,omitempty" yaml:"salt,omitempty"`
+	// CredentialsOmitted keeps the credentials of overwritten registries when importing.
+	CredentialsOmitted bool             `json:"credentials_omitted,omitempty" yaml:"credentials_omitted,omitempty"`
+	Registries         []bundleRegistry `json:"registries" yaml:"registries"`
+}
+
+type bundleRegistry struct {
+	Name               string   `json:"name" yaml:"name"`
+	Url                string   `json:"url" yaml:"url"`
+	User               string   `json:"user,omitempty" yaml:"user,omitempty"`
+	Password           string   `json:"password,omitempty" yaml:"password,omitempty"`
+	EncryptedPassword  string   `json:"encrypted_password,omitempty" yaml:"encrypted_password,omitempty"`
+	Timeout            string   `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	CACert             string   `json:"ca_cert,omitempty" yaml:"ca_cert,omitempty"`
+	ClientCert         string   `json:"client_cert,omitempty" yaml:"client_cert,omitempty"`
+	ClientKey          string   `json:"client_key,omitempty" yaml:"client_key,omitempty"`
+	EncryptedClientKey string   `json:"encrypted_client_key,omitempty" yaml:"encrypted_client_key,omitempty"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+	Kind               string   `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Namespace          string   `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	API                string   `json:"api,omitempty" yaml:"api,omitempty"`
+	Repositories       []string `json:"repositories,omitempty" yaml:"repositories,omitempty"`
+}
+
+// ExportOptions configures how Export writes the credentials of registries.
+type ExportOptions struct {
+	// OmitCredentials leaves out passwords and client keys.
+	OmitCredentials bool
+	// Passphrase encrypts passwords and client keys, if given. Otherwise they are exported in plain text.
+	Passphrase string
+	// Include selects the registries to export by name. Every registry is exported if it is nil.
+	Include func(name string) bool
+}
+
+// Export creates a bundle of the registries of the storage, ordered by name.
+func Export(st registryfrontend.Storage, opts ExportOptions) (*Bundle, error) {
+	regs, err := storedRegistries(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{Version: bundleVersion, CredentialsOmitted: opts.OmitCredentials, Registries: make([]bundleRegistry, 0, len(regs))}
+
+	var keys *secret.Keyring
+
+	if opts.Passphrase != "" && !opts.OmitCredentials {
+		salt, err := secret.GenerateSalt()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if keys, err = secret.PassphraseKeyring(opts.Passphrase, salt); err != nil {
+			return nil, err
+		}
+
+		b.Salt = base64.StdEncoding.EncodeToString(salt)
+	}
+
+	for _, reg := range regs {
+		if opts.Include != nil && !opts.Include(reg.Name) {
+			continue
+		}
+
+		r := bundleRegistry{
+			Name:               reg.Name,
+			Url:                reg.Url,
+			User:               reg.User,
+			CACert:             reg.TLS.CACert,
+			ClientCert:         reg.TLS.ClientCert,
+			InsecureSkipVerify: reg.TLS.InsecureSkipVerify,
+			Kind:               reg.Kind,
+			Namespace:          reg.Namespace,
+			API:                reg.API,
+			Repositories:       reg.Repositories,
+		}
+
+		if reg.Timeout != 0 {
+			r.Timeout = reg.Timeout.String()
+		}
+
+		switch {
+		case opts.OmitCredentials:
+		case keys != nil:
+			if r.EncryptedPassword, err = sealNonEmpty(keys, reg.Password); err != nil {
+				return nil, errors.Wrapf(err, "failed encrypting password of registry %s", reg.Name)
+			}
+			if r.EncryptedClientKey, err = sealNonEmpty(keys, reg.TLS.ClientKey); err != nil {
+				return nil, errors.Wrapf(err, "failed encrypting client key of registry %s", reg.Name)
+			}
+		default:
+			r.Password = reg.Password
+			r.ClientKey = reg.TLS.ClientKey
+		}
+
+		b.Registries = append(b.Registries, r)
+	}
+
+	return b, nil
+}
+
+func sealNonEmpty(keys *secret.Keyring, plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	return keys.Seal(plaintext)
+}
+
+// ParseBundle reads a bundle written by Export in JSON or YAML.
+func ParseBundle(content []byte) (*Bundle, error) {
+	b := &Bundle{}
+
+	// JSON is YAML as well.
+	if err := yaml.UnmarshalStrict(content, b); err != nil {
+		return nil, errors.Wrap(err, "could not parse bundle")
+	}
+
+	if b.Version != bundleVersion {
+		return nil, errors.Errorf("unsupported bundle version %d", b.Version)
+	}
+
+	return b, nil
+}
+
+// Encrypted reports whether the credentials of the bundle are encrypted.
+func (b *Bundle) Encrypted() bool {
+	return b.Salt != ""
+}
+
+// Open returns the registries of the bundle, decrypting their credentials with the passphrase if they are encrypted.
+func (b *Bundle) Open(passphrase string) ([]registryfrontend.Registry, error) {
+	var keys *secret.Keyring
+
+	if b.Encrypted() {
+		if passphrase == "" {
+			return nil, ErrPassphraseRequired
+		}
+
+		salt, err := base64.StdEncoding.DecodeString(b.Salt)
+
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid salt")
+		}
+
+		if keys, err = secret.PassphraseKeyring(passphrase, salt); err != nil {
+			return nil, err
+		}
+	}
+
+	res := make([]registryfrontend.Registry, 0, len(b.Registries))
+
+	for _, r := range b.Registries {
+		reg := registryfrontend.Registry{
+			Name:     r.Name,
+			Url:      r.Url,
+			User:     r.User,
+			Password: r.Password,
+			TLS: registryfrontend.TLSOptions{
+				CACert:             r.CACert,
+				ClientCert:         r.ClientCert,
+				ClientKey:          r.ClientKey,
+				InsecureSkipVerify: r.InsecureSkipVerify,
+			},
+			Kind:         r.Kind,
+			Namespace:    r.Namespace,
+			API:          r.API,
+			Repositories: r.Repositories,
+		}
+
+		if r.Timeout != "" {
+			var err error
+			if reg.Timeout, err = time.ParseDuration(r.Timeout); err != nil {
+				return nil, errors.Wrapf(err, "invalid timeout of registry %s", r.Name)
+			}
+		}
+
+		if r.EncryptedPassword != "" || r.EncryptedClientKey != "" {
+			if keys == nil {
+				return nil, errors.Errorf("registry %s has encrypted credentials, but the bundle has no salt", r.Name)
+			}
+
+			var err error
+			if reg.Password, err = openNonEmpty(keys, r.EncryptedPassword); err != nil {
+				return nil, errors.Wrap(err, "failed decrypting the credentials, the passphrase may be wrong")
+			}
+			if reg.TLS.ClientKey, err = openNonEmpty(keys, r.EncryptedClientKey); err != nil {
+				return nil, errors.Wrap(err, "failed decrypting the credentials, the passphrase may be wrong")
+			}
+		}
+
+		res = append(res, reg)
+	}
+
+	return res, nil
+}
+
+func openNonEmpty(keys *secret.Keyring, sealed string) (string, error) {
+	if sealed == "" {
+		return "", nil
+	}
+	return keys.Open(sealed)
+}
+
+// PlanBundle plans importing the registries of a bundle. Registries with invalid names or settings cannot be imported,
+// and registries whose names are taken conflict with the stored registry, which they overwrite if chosen.
+// If the credentials were omitted from the bundle, overwritten registries keep theirs.
+func PlanBundle(st registryfrontend.Storage, b *Bundle, regs []registryfrontend.Registry) ([]Change, error) {
+	existing, err := storedRegistries(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]registryfrontend.Registry, len(existing))
+
+	for _, reg := range existing {
+		byName[reg.Name] = reg
+	}
+
+	seen := make(map[string]bool, len(regs))
+	changes := make([]Change, 0, len(regs))
+
+	for _, reg := range regs {
+		c := Change{Registry: reg, Action: ActionAdd}
+		old, taken := byName[reg.Name]
+
+		if taken && b.CredentialsOmitted {
+			c.Registry.User, c.Registry.Password, c.Registry.TLS.ClientKey = old.User, old.Password, old.TLS.ClientKey
+		}
+
+		switch {
+		case isInvalidName(reg.Name):
+			c.Action = ActionInvalid
+			c.Warning = "Registry names may only contain the characters a-z, A-Z, 0-9, - and _."
+		case seen[reg.Name]:
+			c.Action = ActionInvalid
+			c.Warning = "The name is used by another registry of the bundle."
+		case client.ValidateKind(reg.Kind) != nil:
+			c.Action = ActionInvalid
+			c.Warning = fmt.Sprintf("The registry kind is invalid: %v.", client.ValidateKind(reg.Kind))
+		case client.ValidateTLSOptions(reg.TLS) != nil:
+			c.Action = ActionInvalid
+			c.Warning = fmt.Sprintf("The TLS settings are invalid: %v.", client.ValidateTLSOptions(reg.TLS))
+		case taken && sameRegistry(old, c.Registry):
+			c.Action = ActionUnchanged
+		case taken:
+			c.Action = ActionConflict
+		}
+
+		seen[reg.Name] = true
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// sameRegistry reports whether importing b over a changes nothing.
+func sameRegistry(a, b registryfrontend.Registry) bool {
+	if len(a.Repositories) == 0 && len(b.Repositories) == 0 {
+		a.Repositories, b.Repositories = nil, nil
+	}
+	return reflect.DeepEqual(a, b)
+}
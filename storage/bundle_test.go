@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBundle(t *testing.T) {
+	src := NewInMemoryStorage()
+	for _, reg := range []registryfrontend.Registry{
+		{Name: "internal", Url: "https://registry.internal", User: "ci", Password: "s3cret"},
+		{Name: "hub", Url: "https://registry-1.docker.io", Kind: "dockerhub", Namespace: "acme"},
+	} {
+		if err := src.Add(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	roundTrip := func(opts ExportOptions, marshal func(interface{}) ([]byte, error), passphrase, password string) func(*testing.T) {
+		return func(t *testing.T) {
+			b, err := Export(src, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := marshal(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			parsed, err := ParseBundle(content)
+			if err != nil {
+				t.Fatalf("%+v\n%s", err, content)
+			}
+
+			regs, err := parsed.Open(passphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(regs) != 2 || regs[1].Name != "internal" || regs[1].User != "ci" || regs[1].Password != password || regs[0].Namespace != "acme" {
+				t.Errorf("unexpected registries %+v", regs)
+			}
+		}
+	}
+
+	t.Run("plain json", roundTrip(ExportOptions{}, json.Marshal, "", "s3cret"))
+	t.Run("encrypted yaml", roundTrip(ExportOptions{Passphrase: "correct horse"}, yaml.Marshal, "correct horse", "s3cret"))
+	t.Run("omitted", roundTrip(ExportOptions{OmitCredentials: true, Passphrase: "unused"}, json.Marshal, "", ""))
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		b, err := Export(src, ExportOptions{Passphrase: "correct horse"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.Open(""); err != ErrPassphraseRequired {
+			t.Errorf("expected a passphrase to be required, got %v", err)
+		}
+		if _, err := b.Open("wrong"); err == nil {
+			t.Error("expected decrypting with the wrong passphrase to fail")
+		}
+	})
+}
+
+func TestPlanBundle(t *testing.T) {
+	st := NewInMemoryStorage()
+	for _, reg := range []registryfrontend.Registry{
+		{Name: "internal", Url: "https://registry.internal", User: "ci", Password: "s3cret"},
+		{Name: "same", Url: "https://same.internal"},
+	} {
+		if err := st.Add(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	regs := []registryfrontend.Registry{
+		{Name: "internal", Url: "https://new.internal"},
+		{Name: "same", Url: "https://same.internal"},
+		{Name: "bad name", Url: "https://bad.internal"},
+		{Name: "new", Url: "https://new.internal", Kind: "unknown"},
+		{Name: "other", Url: "https://other.internal"},
+		{Name: "other", Url: "https://other.internal"},
+	}
+
+	changes, err := PlanBundle(st, &Bundle{CredentialsOmitted: true}, regs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Action{ActionConflict, ActionUnchanged, ActionInvalid, ActionInvalid, ActionAdd, ActionInvalid}
+
+	for i, a := range expected {
+		if changes[i].Action != a {
+			t.Errorf("expected %s to be %s, got %+v", regs[i].Name, a, changes[i])
+		}
+	}
+
+	if c := changes[0].Registry; c.Url != "https://new.internal" || c.Password != "s3cret" {
+		t.Errorf("expected the overwritten registry to keep its credentials, got %+v", c)
+	}
+}
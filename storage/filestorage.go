@@ -0,0 +1,298 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/pkg/errors"
+)
+
+// FileStorage keeps the registries in memory, and persists them to a JSON file on every change,
+// with passwords and client keys encrypted by a Keyring.
+// Secrets encrypted with an old key are re-encrypted with the primary key when the file is opened.
+type FileStorage struct {
+	mu   sync.RWMutex
+	path string
+	keys *secret.Keyring
+	m    MemoryStorage
+}
+
+var _ registryfrontend.Storage = &FileStorage{}
+
+type fileRegistry struct {
+	Name               string   `json:"name"`
+	Url                string   `json:"url"`
+	User               string   `json:"user,omitempty"`
+	EncryptedPassword  string   `json:"encrypted_password,omitempty"`
+	Timeout            string   `json:"timeout,omitempty"`
+	CACert             string   `json:"ca_cert,omitempty"`
+	ClientCert         string   `json:"client_cert,omitempty"`
+	EncryptedClientKey string   `json:"encrypted_client_key,omitempty"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify,omitempty"`
+	Kind               string   `json:"kind,omitempty"`
+	Namespace          string   `json:"namespace,omitempty"`
+	API                string   `json:"api,omitempty"`
+	Repositories       []string `json:"repositories,omitempty"`
+	DockerConfig       bool     `json:"docker_config,omitempty"`
+}
+
+type fileContent struct {
+	Registries []fileRegistry `json:"registries"`
+}
+
+// NewFileStorage opens the storage file at path, creating it on the first change if it does not exist.
+func NewFileStorage(path string, keys *secret.Keyring) (*FileStorage, error) {
+	m, rotate, err := readFile(path, keys)
+
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileStorage{path: path, keys: keys, m: m}
+
+	if rotate {
+		if err := f.save(); err != nil {
+			return nil, errors.Wrap(err, "failed re-encrypting passwords")
+		}
+	}
+
+	return f, nil
+}
+
+// ReadFileStorage reads the registries of the storage file at path into memory, without ever writing the file,
+// e.g. to copy them elsewhere. Secrets encrypted with an old key are not re-encrypted.
+func ReadFileStorage(path string, keys *secret.Keyring) (MemoryStorage, error) {
+	m, _, err := readFile(path, keys)
+	return m, err
+}
+
+// readFile reads the registries of the storage file at path, which has none if it does not exist,
+// and reports whether secrets are encrypted with an old key.
+func readFile(path string, keys *secret.Keyring) (MemoryStorage, bool, error) {
+	m := make(MemoryStorage)
+
+	content, err := ioutil.ReadFile(path)
+
+	if os.IsNotExist(err) {
+		return m, false, nil
+	} else if err != nil {
+		return nil, false, errors.Wrap(err, "failed reading storage file")
+	}
+
+	fc := fileContent{}
+
+	if err := json.Unmarshal(content, &fc); err != nil {
+		return nil, false, errors.Wrap(err, "could not parse storage file")
+	}
+
+	rotate := false
+
+	for _, r := range fc.Registries {
+		reg := registryfrontend.Registry{
+			Name: r.Name,
+			Url:  r.Url,
+			User: r.User,
+			TLS: registryfrontend.TLSOptions{
+				CACert:             r.CACert,
+				ClientCert:         r.ClientCert,
+				InsecureSkipVerify: r.InsecureSkipVerify,
+			},
+			Kind:         r.Kind,
+			Namespace:    r.Namespace,
+			API:          r.API,
+			Repositories: r.Repositories,
+			DockerConfig: r.DockerConfig,
+		}
+
+		if r.Timeout != "" {
+			if reg.Timeout, err = time.ParseDuration(r.Timeout); err != nil {
+				return nil, false, errors.Wrapf(err, "invalid timeout of registry %s", r.Name)
+			}
+		}
+
+		if r.EncryptedPassword != "" {
+			if reg.Password, err = keys.Open(r.EncryptedPassword); err != nil {
+				return nil, false, errors.Wrapf(err, "failed decrypting password of registry %s", r.Name)
+			}
+			rotate = rotate || keys.NeedsRotation(r.EncryptedPassword)
+		}
+
+		if r.EncryptedClientKey != "" {
+			if reg.TLS.ClientKey, err = keys.Open(r.EncryptedClientKey); err != nil {
+				return nil, false, errors.Wrapf(err, "failed decrypting client key of registry %s", r.Name)
+			}
+			rotate = rotate || keys.NeedsRotation(r.EncryptedClientKey)
+		}
+
+		if err := m.Add(reg); err != nil {
+			return nil, false, errors.Wrapf(err, "invalid registry %q in storage file", r.Name)
+		}
+	}
+
+	return m, rotate, nil
+}
+
+// save writes all registries to the file. The caller must hold the lock.
+func (f *FileStorage) save() error {
+	fc := fileContent{Registries: make([]fileRegistry, 0, len(f.m))}
+
+	for _, reg := range f.m {
+		r := fileRegistry{
+			Name:               reg.Name,
+			Url:                reg.Url,
+			User:               reg.User,
+			CACert:             reg.TLS.CACert,
+			ClientCert:         reg.TLS.ClientCert,
+			InsecureSkipVerify: reg.TLS.InsecureSkipVerify,
+			Kind:               reg.Kind,
+			Namespace:          reg.Namespace,
+			API:                reg.API,
+			Repositories:       reg.Repositories,
+			DockerConfig:       reg.DockerConfig,
+		}
+
+		if reg.Timeout != 0 {
+			r.Timeout = reg.Timeout.String()
+		}
+
+		var err error
+
+		if reg.Password != "" {
+			if r.EncryptedPassword, err = f.keys.Seal(reg.Password); err != nil {
+				return err
+			}
+		}
+
+		if reg.TLS.ClientKey != "" {
+			if r.EncryptedClientKey, err = f.keys.Seal(reg.TLS.ClientKey); err != nil {
+				return err
+			}
+		}
+
+		fc.Registries = append(fc.Registries, r)
+	}
+
+	sort.Slice(fc.Registries, func(i, j int) bool {
+		return fc.Registries[i].Name < fc.Registries[j].Name
+	})
+
+	content, err := json.MarshalIndent(fc, "", "  ")
+
+	if err != nil {
+		return errors.Wrap(err, "failed encoding storage file")
+	}
+
+	// Write to a temporary file first, so a crash never leaves a half written file behind.
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), ".registries-*")
+
+	if err != nil {
+		return errors.Wrap(err, "failed writing storage file")
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "failed writing storage file")
+	}
+
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed writing storage file")
+	}
+
+	return errors.Wrap(os.Rename(tmp.Name(), f.path), "failed writing storage file")
+}
+
+func (f *FileStorage) Registries() ([]registryfrontend.Client, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.m.Registries()
+}
+
+func (f *FileStorage) Registry(name string) (registryfrontend.Client, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.m.Registry(name)
+}
+
+func (f *FileStorage) Lookup(name string) (registryfrontend.Registry, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.m.Lookup(name)
+}
+
+func (f *FileStorage) Add(r registryfrontend.Registry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, existed := f.m[r.Name]
+
+	if err := f.m.Add(r); err != nil {
+		return err
+	}
+
+	if err := f.save(); err != nil {
+		if existed {
+			f.m[r.Name] = old
+		} else {
+			delete(f.m, r.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStorage) Update(r registryfrontend.Registry) error {
+	return f.Add(r)
+}
+
+func (f *FileStorage) Clear() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.m
+	f.m = make(MemoryStorage)
+
+	if err := f.save(); err != nil {
+		f.m = old
+		return err
+	}
+
+	for name := range old {
+		clients.Forget(name)
+	}
+
+	return nil
+}
+
+func (f *FileStorage) Remove(r registryfrontend.Registry) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, existed := f.m[r.Name]
+
+	if !existed {
+		return nil
+	}
+
+	delete(f.m, r.Name)
+
+	if err := f.save(); err != nil {
+		f.m[r.Name] = old
+		return err
+	}
+
+	clients.Forget(r.Name)
+
+	return nil
+}
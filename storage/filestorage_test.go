@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/secret"
+)
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registries.json")
+	oldKey, _ := secret.GenerateKey()
+	newKey, _ := secret.GenerateKey()
+	old, _ := secret.ParseKeyring(oldKey)
+
+	s, err := NewFileStorage(path, old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reg := registryfrontend.Registry{Name: "internal", Url: "https://registry", User: "ci", Password: "hunter2",
+		Kind: "gitlab", Namespace: "group", API: "https://gitlab.example.com", Repositories: []string{"group/app"}}
+	if err := s.Add(reg); err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(content), "hunter2") {
+		t.Error("password stored in plaintext")
+	}
+
+	rotated, _ := secret.ParseKeyring(newKey + "," + oldKey)
+
+	// Reading the file never writes it, even if the secrets need re-encrypting.
+	if m, err := ReadFileStorage(path, rotated); err != nil || !reflect.DeepEqual(m["internal"], reg) {
+		t.Errorf("expected %v, got %v %v", reg, m["internal"], err)
+	}
+	if unchanged, _ := ioutil.ReadFile(path); string(unchanged) != string(content) {
+		t.Error("expected reading the storage file not to change it")
+	}
+
+	s, err = NewFileStorage(path, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r, err := s.Lookup("internal"); err != nil || !reflect.DeepEqual(r, reg) {
+		t.Errorf("expected %v, got %v %v", reg, r, err)
+	}
+
+	// After rotation, the file can be opened without the old key.
+	current, _ := secret.ParseKeyring(newKey)
+	if _, err := NewFileStorage(path, current); err != nil {
+		t.Errorf("expected passwords to be re-encrypted with the new key: %v", err)
+	}
+
+	if err := s.Remove(reg); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lookup("internal"); err != ErrRegistryNotFound {
+		t.Errorf("expected registry to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+)
+
+// Action is what importing a registry does to a storage.
+type Action string
+
+const (
+	ActionAdd       Action = "add"
+	ActionUpdate    Action = "update"
+	ActionUnchanged Action = "unchanged"
+	// ActionConflict replaces the stored registry with the same name, if chosen.
+	ActionConflict Action = "conflict"
+	// ActionInvalid registries cannot be imported, the Warning of the change tells why.
+	ActionInvalid Action = "invalid"
+)
+
+// Change is a registry an import adds to or updates in a storage.
+type Change struct {
+	Registry registryfrontend.Registry
+	Action   Action
+	// Warning tells what could not be imported, if anything.
+	Warning string
+}
+
+// invalidNameChars matches the characters that may not be used in registry names.
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9\-_]+`)
+
+// PlanLogins plans importing the logins of a Docker configuration, e.g. of a Kubernetes imagePullSecret.
+// Registries already stored for a host get the credentials of its login, while other hosts are added
+// with a name generated from the host, or the kind of hosted registry at the host, e.g. ghcr.
+func PlanLogins(st registryfrontend.Storage, logins map[string]client.Credentials) ([]Change, error) {
+	existing, err := storedRegistries(st)
+
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(existing))
+	byHost := make(map[string]registryfrontend.Registry, len(existing))
+
+	for _, reg := range existing {
+		taken[reg.Name] = true
+		byHost[registryHost(reg.Url)] = reg
+	}
+
+	hosts := make([]string, 0, len(logins))
+
+	for host := range logins {
+		hosts = append(hosts, host)
+	}
+
+	sort.Strings(hosts)
+
+	changes := make([]Change, 0, len(hosts))
+
+	for _, host := range hosts {
+		login := logins[host]
+		kind := client.KindOfHost(host)
+
+		c := Change{Action: ActionAdd}
+
+		if login.IdentityToken != "" {
+			c.Warning = "The identity token cannot be stored, set a password instead."
+		}
+
+		u := client.DefaultURL(kind)
+
+		if u == "" {
+			u = "https://" + host
+		}
+
+		if reg, ok := byHost[registryHost(u)]; ok {
+			c.Registry = reg
+			c.Action = ActionUpdate
+
+			if reg.User == login.User && reg.Password == login.Password {
+				c.Action = ActionUnchanged
+			}
+		} else {
+			c.Registry = registryfrontend.Registry{Name: uniqueName(taken, kind, host), Url: u, Kind: kind}
+			taken[c.Registry.Name] = true
+		}
+
+		c.Registry.User, c.Registry.Password = login.User, login.Password
+		changes = append(changes, c)
+	}
+
+	return changes, nil
+}
+
+// storedRegistries returns the configuration of every registry of the storage.
+func storedRegistries(st registryfrontend.Storage) ([]registryfrontend.Registry, error) {
+	clients, err := st.Registries()
+
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]registryfrontend.Registry, 0, len(clients))
+
+	for _, c := range clients {
+		reg, err := st.Lookup(c.Name())
+
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, reg)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+
+	return res, nil
+}
+
+// registryHost returns the host of the URL of a registry, folding the hosts of Docker Hub into one.
+func registryHost(u string) string {
+	parsed, err := url.Parse(u)
+
+	if err != nil {
+		return u
+	}
+
+	if client.KindOfHost(parsed.Host) == "dockerhub" {
+		return "index.docker.io"
+	}
+
+	return strings.ToLower(parsed.Host)
+}
+
+// uniqueName generates a valid registry name from the kind, or else the host, that is not yet taken.
+func uniqueName(taken map[string]bool, kind, host string) string {
+	base := kind
+
+	if base == "" {
+		base = strings.Trim(invalidNameChars.ReplaceAllString(host, "-"), "-")
+	}
+
+	if base == "" {
+		base = "registry"
+	}
+
+	name := base
+
+	for i := 2; taken[name]; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+
+	return name
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+)
+
+func TestPlanLogins(t *testing.T) {
+	config := `{"auths":{
+		"https://index.docker.io/v1/":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("ci:s3cret")) + `"},
+		"registry.internal:5000":{"username":"ci","password":"hunter2"},
+		"Registry.example.com":{"username":"ci","password":"s3cret"},
+		"ghcr.io":{"username":"ci","identitytoken":"refr3sh"}}}`
+	secret := "apiVersion: v1\nkind: Secret\ntype: kubernetes.io/dockerconfigjson\ndata:\n  .dockerconfigjson: " +
+		base64.StdEncoding.EncodeToString([]byte(config)) + "\n"
+
+	d, err := client.ParsePullSecret([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	st := NewInMemoryStorage()
+	for _, reg := range []registryfrontend.Registry{
+		{Name: "internal", Url: "https://registry.internal:5000", User: "ci", Password: "old"},
+		{Name: "registry-example-com", Url: "https://other.example.com"},
+	} {
+		if err := st.Add(reg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := PlanLogins(st, d.Logins())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		name   string
+		url    string
+		action Action
+	}{
+		{"ghcr", "https://ghcr.io", ActionAdd},
+		{"dockerhub", "https://registry-1.docker.io", ActionAdd},
+		{"registry-example-com-2", "https://registry.example.com", ActionAdd},
+		{"internal", "https://registry.internal:5000", ActionUpdate},
+	}
+
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got %+v", len(expected), changes)
+	}
+
+	for i, e := range expected {
+		c := changes[i]
+		if c.Registry.Name != e.name || c.Registry.Url != e.url || c.Action != e.action || isInvalidName(c.Registry.Name) {
+			t.Errorf("expected %s %s to be %s, got %+v", e.name, e.url, e.action, c)
+		}
+	}
+
+	if changes[0].Warning == "" || changes[1].Registry.Password != "s3cret" || changes[3].Registry.Password != "hunter2" {
+		t.Errorf("unexpected credentials %+v", changes)
+	}
+}
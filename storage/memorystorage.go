@@ -15,6 +15,9 @@ var (
 	ErrRegistryNotFound                          = errors.New("registry not found")
 )
 
+// clients reuses the clients of the registries of every storage, instead of creating them on every request.
+var clients = client.NewCache()
+
 func NewInMemoryStorage() *MemoryStorage {
 	s := make(MemoryStorage)
 	return &s
@@ -24,19 +27,11 @@ func (m *MemoryStorage) Registries() ([]registryfrontend.Client, error) {
 	res := make([]registryfrontend.Client, 0, len(*m))
 
 	for _, reg := range *m {
-		if reg.User != "" {
-			c, err := client.MakeV2BasicAuth(reg.Name, reg.Url, reg.User, reg.Password)
-			if err != nil {
-				return nil, err
-			}
-			res = append(res, c)
-		} else {
-			c, err := client.MakeV2(reg.Name, reg.Url)
-			if err != nil {
-				return nil, err
-			}
-			res = append(res, c)
+		c, err := clients.Client(reg)
+		if err != nil {
+			return nil, err
 		}
+		res = append(res, c)
 	}
 
 	return res, nil
@@ -46,19 +41,26 @@ func (m *MemoryStorage) Registry(name string) (registryfrontend.Client, error) {
 	if reg, ok := (*m)[name]; !ok {
 		return nil, ErrRegistryNotFound
 	} else {
-		if reg.User != "" {
-			return client.MakeV2BasicAuth(reg.Name, reg.Url, reg.User, reg.Password)
-		} else {
-			return client.MakeV2(reg.Name, reg.Url)
-		}
+		return clients.Client(reg)
 	}
 }
 
+func (m *MemoryStorage) Lookup(name string) (registryfrontend.Registry, error) {
+	reg, ok := (*m)[name]
+
+	if !ok {
+		return registryfrontend.Registry{}, ErrRegistryNotFound
+	}
+
+	return reg, nil
+}
+
 func (m *MemoryStorage) Add(r registryfrontend.Registry) error {
 	if isInvalidName(r.Name) {
 		return ErrIllegalName
 	}
 	(*m)[r.Name] = r
+	clients.Forget(r.Name)
 	return nil
 }
 
@@ -66,13 +68,25 @@ func (m *MemoryStorage) Update(r registryfrontend.Registry) error {
 	return m.Add(r)
 }
 
+// ValidateName returns ErrIllegalName if the name cannot be used for a registry.
+func ValidateName(name string) error {
+	if isInvalidName(name) {
+		return ErrIllegalName
+	}
+	return nil
+}
+
 func (m *MemoryStorage) Clear() error {
+	for name := range *m {
+		clients.Forget(name)
+	}
 	*m = make(map[string]registryfrontend.Registry)
 	return nil
 }
 
 func (m *MemoryStorage) Remove(r registryfrontend.Registry) error {
 	delete(*m, r.Name)
+	clients.Forget(r.Name)
 	return nil
 }
 
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/mikaellindemann/registryfrontend"
+)
+
+// SynchronizedStorage serializes access to a storage, which is not safe for concurrent use by itself,
+// e.g. when registries are added by a reload while requests are being served.
+type SynchronizedStorage struct {
+	mu sync.RWMutex
+	s  registryfrontend.Storage
+}
+
+var _ registryfrontend.Storage = &SynchronizedStorage{}
+
+func NewSynchronizedStorage(s registryfrontend.Storage) *SynchronizedStorage {
+	return &SynchronizedStorage{s: s}
+}
+
+func (s *SynchronizedStorage) Registries() ([]registryfrontend.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.s.Registries()
+}
+
+func (s *SynchronizedStorage) Registry(name string) (registryfrontend.Client, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.s.Registry(name)
+}
+
+func (s *SynchronizedStorage) Lookup(name string) (registryfrontend.Registry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.s.Lookup(name)
+}
+
+func (s *SynchronizedStorage) Add(r registryfrontend.Registry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.s.Add(r)
+}
+
+func (s *SynchronizedStorage) Update(r registryfrontend.Registry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.s.Update(r)
+}
+
+func (s *SynchronizedStorage) Clear() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.s.Clear()
+}
+
+func (s *SynchronizedStorage) Remove(r registryfrontend.Registry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.s.Remove(r)
+}
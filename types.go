@@ -2,14 +2,84 @@ package registryfrontend
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"time"
+
+	"github.com/mikaellindemann/registryfrontend/secret"
+	"github.com/opencontainers/go-digest"
 )
 
 type Registry struct {
-	Name     string `json:"name"`
-	Url      string `json:"url"`
-	User     string `json:"user,omitempty"`
-	Password string `json:"password,omitempty"`
+	Name     string        `json:"name"`
+	Url      string        `json:"url"`
+	User     string        `json:"user,omitempty"`
+	Password string        `json:"password,omitempty"`
+	Timeout  time.Duration `json:"timeout,omitempty"`
+	TLS      TLSOptions    `json:"tls,omitempty"`
+	// Kind is the kind of hosted registry, e.g. dockerhub or ghcr, or empty for a self-hosted distribution registry.
+	Kind string `json:"kind,omitempty"`
+	// Namespace is the user, organization or group whose repositories are listed on hosted registries,
+	// and defaults to the user.
+	Namespace string `json:"namespace,omitempty"`
+	// API is the URL of the API of the provider, if it is not the default of the kind, e.g. for a self-hosted GitLab.
+	API string `json:"api,omitempty"`
+	// Repositories are listed instead of the repositories of the registry, if given.
+	Repositories []string `json:"repositories,omitempty"`
+	// DockerConfig uses the credentials of the Docker config for the host of the registry if it has no user or password.
+	// Only registries of the configuration can opt in, registries added in the frontend never use them.
+	DockerConfig bool `json:"docker_config,omitempty"`
+}
+
+// TLSOptions configures how the frontend connects to registries served over https.
+// Certificates and keys are PEM encoded.
+type TLSOptions struct {
+	// CACert is a bundle of certificates trusted in addition to the system roots.
+	CACert             string `json:"ca_cert,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+}
+
+// MarshalJSON redacts the password and client key, so it is never exposed by accident.
+// Code that needs to persist the password must do so explicitly.
+func (r Registry) MarshalJSON() ([]byte, error) {
+	type registry Registry
+	return json.Marshal(registry(r.Redacted()))
+}
+
+// String redacts the password and client key, so it does not end up in logs.
+func (r Registry) String() string {
+	c := r.Redacted()
+	return fmt.Sprintf("{Name:%s Url:%s User:%s Password:%s}", c.Name, c.Url, c.User, c.Password)
+}
+
+func (r Registry) GoString() string {
+	return "registryfrontend.Registry" + r.String()
+}
+
+// Redacted returns a copy of the registry with its password and client key replaced.
+func (r Registry) Redacted() Registry {
+	if r.Password != "" {
+		r.Password = secret.Redacted
+	}
+	if r.TLS.ClientKey != "" {
+		r.TLS.ClientKey = secret.Redacted
+	}
+	r.Url = secret.RedactURL(r.Url)
+
+	return r
+}
+
+// TLSInfo describes the certificate presented by a registry served over https.
+type TLSInfo struct {
+	Subject   string
+	Issuer    string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// Verified is false if the registry is configured to skip certificate verification.
+	Verified bool
 }
 
 type TagInfo struct {
@@ -23,9 +93,76 @@ type TagInfo struct {
 	Volumes       []string
 }
 
+// ArtifactInfo describes a manifest as an OCI artifact, e.g. a Helm chart, an SBOM or a WebAssembly module.
+type ArtifactInfo struct {
+	// MediaType is the media type of the manifest.
+	MediaType string
+	// ArtifactType is the artifact type of the manifest, or else the media type of its config.
+	ArtifactType string
+	// Kind names the type of artifact, e.g. Helm chart.
+	Kind string
+	// Created is read from the org.opencontainers.image.created annotation, and is zero if it is missing.
+	Created     time.Time
+	Subject     *Descriptor
+	Annotations map[string]string
+	// Layers are the layers of a manifest, or the manifests of an index.
+	Layers []Descriptor
+	Size   int64
+	// Chart is the metadata of a Helm chart, and is nil for other artifacts.
+	Chart *Chart
+}
+
+// Chart is the metadata of a Helm chart, as in its Chart.yaml.
+type Chart struct {
+	APIVersion   string            `json:"apiVersion"`
+	Name         string            `json:"name"`
+	Version      string            `json:"version"`
+	AppVersion   string            `json:"appVersion"`
+	Description  string            `json:"description"`
+	Type         string            `json:"type"`
+	KubeVersion  string            `json:"kubeVersion"`
+	Home         string            `json:"home"`
+	Icon         string            `json:"icon"`
+	Keywords     []string          `json:"keywords"`
+	Sources      []string          `json:"sources"`
+	Deprecated   bool              `json:"deprecated"`
+	Maintainers  []ChartMaintainer `json:"maintainers"`
+	Dependencies []ChartDependency `json:"dependencies"`
+}
+
+type ChartMaintainer struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	URL   string `json:"url"`
+}
+
+type ChartDependency struct {
+	Name       string `json:"name"`
+	Version    string `json:"version"`
+	Repository string `json:"repository"`
+}
+
+// Manifest is a manifest as stored by a registry.
+type Manifest struct {
+	MediaType string
+	Digest    digest.Digest
+	Content   []byte
+}
+
+// Descriptor references a manifest or blob, e.g. in the list of referrers of an image.
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Digest       digest.Digest     `json:"digest"`
+	Size         int64             `json:"size"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
 type Client interface {
 	Name() string
 	URL() string
+	// Kind is the kind of the registry, e.g. dockerhub, or empty for a self-hosted distribution registry.
+	Kind() string
 
 	Repositories(ctx context.Context) ([]string, error)
 	RepositoriesN(ctx context.Context, n int, last string) ([]string, error)
@@ -33,12 +170,93 @@ type Client interface {
 	Tags(ctx context.Context, repository string) ([]string, error)
 	TagsN(ctx context.Context, repository string, n int, last string) ([]string, error)
 
+	// Tag describes the image referenced by a tag or digest.
 	Tag(ctx context.Context, repository, tag string) (*TagInfo, error)
+	// Artifact describes the manifest referenced by a tag or digest, whether or not it is an image.
+	Artifact(ctx context.Context, repository, reference string) (*ArtifactInfo, error)
+	// Digest resolves a tag to the digest of the manifest it points to.
+	Digest(ctx context.Context, repository, reference string) (digest.Digest, error)
+	// DeleteTag deletes the manifest the tag points to, and returns its digest.
+	DeleteTag(ctx context.Context, repository, tag string) (digest.Digest, error)
+
+	// Manifest fetches the manifest referenced by a tag or digest.
+	Manifest(ctx context.Context, repository, reference string) (*Manifest, error)
+	// PutManifest uploads the manifest, and tags it if reference is a tag.
+	PutManifest(ctx context.Context, repository, reference string, m *Manifest) error
+
+	// Referrers lists the manifests whose subject is the manifest with digest d, e.g. signatures.
+	Referrers(ctx context.Context, repository string, d digest.Digest) ([]Descriptor, error)
+
+	BlobSize(ctx context.Context, repository string, d digest.Digest) (int64, error)
+	// Blob returns the content of the blob and its size, which is -1 if unknown. The caller must close the content.
+	Blob(ctx context.Context, repository string, d digest.Digest) (io.ReadCloser, int64, error)
+	// PutBlob uploads a blob of the given digest and size.
+	PutBlob(ctx context.Context, repository string, d digest.Digest, size int64, content io.Reader) error
+
+	// TLS connects to the registry, and returns the certificate it presents, or nil if it is not served over https.
+	TLS(ctx context.Context) (*TLSInfo, error)
+}
+
+// Project groups repositories in registries such as Harbor, e.g. library of library/app.
+type Project struct {
+	Name         string
+	Public       bool
+	Repositories int
+	// Quota is the storage quota in bytes, or -1 if the storage is unlimited.
+	Quota int64
+	// Used is the storage used by the project in bytes.
+	Used int64
+}
+
+// ProjectLister is implemented by clients of registries that organize repositories into projects.
+type ProjectLister interface {
+	Projects(ctx context.Context) ([]Project, error)
+}
+
+// RepositoryInfo describes a repository beyond what the distribution API offers.
+type RepositoryInfo struct {
+	Description string
+	PullCount   int64
+	Artifacts   int
+	Updated     time.Time
+}
+
+// RepositoryDescriber is implemented by clients of registries that describe their repositories, e.g. Harbor.
+type RepositoryDescriber interface {
+	RepositoryInfo(ctx context.Context, repository string) (*RepositoryInfo, error)
+}
+
+// ArtifactStatus is what a registry knows about the use and scanning of an artifact.
+type ArtifactStatus struct {
+	Pushed time.Time
+	// Pulled is zero if the artifact was never pulled.
+	Pulled time.Time
+	// ScanStatus is the status of the latest vulnerability scan, e.g. Success, or empty if it was never scanned.
+	ScanStatus string
+	// Severity is the highest severity found by the scan, e.g. High.
+	Severity        string
+	Vulnerabilities VulnerabilityCounts
+}
+
+type VulnerabilityCounts struct {
+	Total    int
+	Fixable  int
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+}
+
+// ArtifactStatusProvider is implemented by clients of registries that track artifacts, e.g. Harbor.
+type ArtifactStatusProvider interface {
+	ArtifactStatus(ctx context.Context, repository, reference string) (*ArtifactStatus, error)
 }
 
 type Storage interface {
 	Registries() ([]Client, error)
 	Registry(name string) (Client, error)
+	// Lookup returns the configuration of the registry, including its credentials.
+	Lookup(name string) (Registry, error)
 	Add(r Registry) error
 	Update(r Registry) error
 	Clear() error
@@ -0,0 +1,152 @@
+package vuln
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend/internal/watch"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// fileDigest matches file names starting with a digest, e.g. sha256-<hash>.json or sha256:<hash>.trivy.json.
+var fileDigest = regexp.MustCompile(`^(sha256)[-:]([a-f0-9]{64})`)
+
+// Directory holds the reports in the JSON files of a directory and its subdirectories, e.g. written by CI pipelines.
+// A report is attached to the digest its file name starts with, or else to the digests the report states.
+type Directory struct {
+	path string
+
+	mu sync.RWMutex
+	// files are the names, sizes and modification times of the files, to detect changes.
+	files   string
+	reports map[digest.Digest][]*Report
+}
+
+// OpenDirectory reads the reports in the directory. Even if it fails, the directory can be reloaded later.
+func OpenDirectory(path string) (*Directory, error) {
+	d := &Directory{path: path, reports: make(map[digest.Digest][]*Report)}
+	return d, d.Reload()
+}
+
+// Reload reads the reports again. Files that cannot be read are skipped and reported by the error,
+// while the remaining reports are loaded.
+func (d *Directory) Reload() error {
+	files, err := d.stat()
+
+	if err != nil {
+		return err
+	}
+
+	reports := make(map[digest.Digest][]*Report)
+	var skipped []string
+
+	err = filepath.Walk(d.path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
+			return err
+		}
+
+		r, digests, err := readReport(path)
+
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("%s: %v", path, err))
+			return nil
+		}
+
+		for _, dg := range digests {
+			reports[dg] = append(reports[dg], r)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.Wrap(err, "failed reading vulnerability reports")
+	}
+
+	d.mu.Lock()
+	d.files, d.reports = files, reports
+	d.mu.Unlock()
+
+	if len(skipped) > 0 {
+		return errors.Errorf("skipped vulnerability reports: %s", strings.Join(skipped, "; "))
+	}
+
+	return nil
+}
+
+func readReport(path string) (*Report, []digest.Digest, error) {
+	content, err := ioutil.ReadFile(path)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r, err := Parse(content)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r.Source = filepath.Base(path)
+	digests := r.Digests
+
+	if m := fileDigest.FindStringSubmatch(filepath.Base(path)); m != nil {
+		digests = []digest.Digest{digest.NewDigestFromEncoded(digest.Algorithm(m[1]), m[2])}
+	}
+
+	if len(digests) == 0 {
+		return nil, nil, errors.New("neither the file name nor the report contains the digest of the image")
+	}
+
+	return r, digests, nil
+}
+
+// stat describes the names, sizes and modification times of the files.
+func (d *Directory) stat() (string, error) {
+	var b strings.Builder
+
+	err := filepath.Walk(d.path, func(path string, info os.FileInfo, err error) error {
+		if err == nil {
+			fmt.Fprintf(&b, "%s %d %d\n", path, info.Size(), info.ModTime().UnixNano())
+		}
+		return err
+	})
+
+	return b.String(), errors.Wrap(err, "failed reading vulnerability reports")
+}
+
+// Watch reloads the reports when files are added, changed or removed, checking every interval until ctx is done.
+// onReload is called with the result of every reload.
+func (d *Directory) Watch(ctx context.Context, interval time.Duration, onReload func(error)) {
+	watch.Poll(ctx, interval, d.changed, d.Reload, onReload)
+}
+
+// changed reports whether files were added, changed or removed since the last reload.
+func (d *Directory) changed() (bool, error) {
+	files, err := d.stat()
+
+	if err != nil {
+		return false, err
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return files != d.files, nil
+}
+
+// Reports returns the reports of the image with digest dg.
+func (d *Directory) Reports(dg digest.Digest) []*Report {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.reports[dg]
+}
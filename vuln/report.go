@@ -0,0 +1,259 @@
+// Package vuln attaches the vulnerability reports of scanners such as Trivy and Grype to images.
+//
+// Reports are read from a directory, or from the in-toto attestations of images, e.g. pushed by cosign attest --type vuln.
+package vuln
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+type Severity int
+
+const (
+	Unknown Severity = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Low:
+		return "Low"
+	case Medium:
+		return "Medium"
+	case High:
+		return "High"
+	case Critical:
+		return "Critical"
+	}
+	return "Unknown"
+}
+
+// parseSeverity reads the severities of Trivy, e.g. CRITICAL, and Grype, e.g. Critical or Negligible.
+func parseSeverity(s string) Severity {
+	switch strings.ToLower(s) {
+	case "critical":
+		return Critical
+	case "high":
+		return High
+	case "medium":
+		return Medium
+	case "low", "negligible":
+		return Low
+	}
+	return Unknown
+}
+
+type Vulnerability struct {
+	// ID identifies the vulnerability, e.g. CVE-2023-0286.
+	ID               string
+	Package          string
+	InstalledVersion string
+	// FixedVersion is empty if no fix is available.
+	FixedVersion string
+	Severity     Severity
+	Title        string
+	URL          string
+}
+
+// Report is the result of scanning an image.
+type Report struct {
+	// Scanner is Trivy or Grype.
+	Scanner string
+	// Source is the file the report was read from, the tag the attestation was found by, or referrers.
+	Source string
+	// Digests are the digests of the image the report states it was created for, if any.
+	Digests         []digest.Digest
+	Vulnerabilities []Vulnerability
+}
+
+// Counts are the number of vulnerabilities of each severity.
+type Counts struct {
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+	Unknown  int
+}
+
+// Summarize counts the vulnerabilities of the reports, counting vulnerabilities found by several reports once.
+func Summarize(reports []*Report) Counts {
+	c := Counts{}
+
+	for _, v := range Merge(reports) {
+		switch v.Severity {
+		case Critical:
+			c.Critical++
+		case High:
+			c.High++
+		case Medium:
+			c.Medium++
+		case Low:
+			c.Low++
+		default:
+			c.Unknown++
+		}
+	}
+
+	return c
+}
+
+// Merge lists the vulnerabilities of the reports, leaving out vulnerabilities of the same package found by several reports.
+func Merge(reports []*Report) []Vulnerability {
+	type key struct{ id, pkg, version string }
+
+	var res []Vulnerability
+	seen := make(map[key]bool)
+
+	for _, r := range reports {
+		for _, v := range r.Vulnerabilities {
+			k := key{v.ID, v.Package, v.InstalledVersion}
+
+			if !seen[k] {
+				seen[k] = true
+				res = append(res, v)
+			}
+		}
+	}
+
+	return res
+}
+
+// Parse parses the JSON report of Trivy or Grype, or a cosign vulnerability attestation predicate wrapping one.
+func Parse(content []byte) (*Report, error) {
+	probe := struct {
+		SchemaVersion int    `json:"SchemaVersion"`
+		ArtifactName  string `json:"ArtifactName"`
+		Descriptor    struct {
+			Name string `json:"name"`
+		} `json:"descriptor"`
+		Scanner struct {
+			Result json.RawMessage `json:"result"`
+		} `json:"scanner"`
+	}{}
+
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return nil, errors.Wrap(err, "could not parse vulnerability report")
+	}
+
+	switch {
+	case len(probe.Scanner.Result) > 0:
+		return Parse(probe.Scanner.Result)
+	case probe.SchemaVersion != 0 && probe.ArtifactName != "":
+		return parseTrivy(content)
+	case probe.Descriptor.Name == "grype":
+		return parseGrype(content)
+	}
+
+	return nil, errors.New("the document is neither a Trivy nor a Grype report")
+}
+
+func parseTrivy(content []byte) (*Report, error) {
+	doc := struct {
+		Metadata struct {
+			RepoDigests []string `json:"RepoDigests"`
+		} `json:"Metadata"`
+		Results []struct {
+			Vulnerabilities []struct {
+				VulnerabilityID  string `json:"VulnerabilityID"`
+				PkgName          string `json:"PkgName"`
+				InstalledVersion string `json:"InstalledVersion"`
+				FixedVersion     string `json:"FixedVersion"`
+				Severity         string `json:"Severity"`
+				Title            string `json:"Title"`
+				PrimaryURL       string `json:"PrimaryURL"`
+			} `json:"Vulnerabilities"`
+		} `json:"Results"`
+	}{}
+
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not parse Trivy report")
+	}
+
+	r := &Report{Scanner: "Trivy", Digests: repoDigests(doc.Metadata.RepoDigests)}
+
+	for _, res := range doc.Results {
+		for _, v := range res.Vulnerabilities {
+			r.Vulnerabilities = append(r.Vulnerabilities, Vulnerability{
+				ID:               v.VulnerabilityID,
+				Package:          v.PkgName,
+				InstalledVersion: v.InstalledVersion,
+				FixedVersion:     v.FixedVersion,
+				Severity:         parseSeverity(v.Severity),
+				Title:            v.Title,
+				URL:              v.PrimaryURL,
+			})
+		}
+	}
+
+	return r, nil
+}
+
+func parseGrype(content []byte) (*Report, error) {
+	doc := struct {
+		Matches []struct {
+			Vulnerability struct {
+				ID          string `json:"id"`
+				Severity    string `json:"severity"`
+				DataSource  string `json:"dataSource"`
+				Description string `json:"description"`
+				Fix         struct {
+					Versions []string `json:"versions"`
+				} `json:"fix"`
+			} `json:"vulnerability"`
+			Artifact struct {
+				Name    string `json:"name"`
+				Version string `json:"version"`
+			} `json:"artifact"`
+		} `json:"matches"`
+		Source struct {
+			Target struct {
+				RepoDigests    []string `json:"repoDigests"`
+				ManifestDigest string   `json:"manifestDigest"`
+			} `json:"target"`
+		} `json:"source"`
+	}{}
+
+	if err := json.Unmarshal(content, &doc); err != nil {
+		return nil, errors.Wrap(err, "could not parse Grype report")
+	}
+
+	r := &Report{Scanner: "Grype", Digests: repoDigests(doc.Source.Target.RepoDigests)}
+
+	if d, err := digest.Parse(doc.Source.Target.ManifestDigest); err == nil {
+		r.Digests = append(r.Digests, d)
+	}
+
+	for _, m := range doc.Matches {
+		r.Vulnerabilities = append(r.Vulnerabilities, Vulnerability{
+			ID:               m.Vulnerability.ID,
+			Package:          m.Artifact.Name,
+			InstalledVersion: m.Artifact.Version,
+			FixedVersion:     strings.Join(m.Vulnerability.Fix.Versions, ", "),
+			Severity:         parseSeverity(m.Vulnerability.Severity),
+			Title:            m.Vulnerability.Description,
+			URL:              m.Vulnerability.DataSource,
+		})
+	}
+
+	return r, nil
+}
+
+// repoDigests reads the digests of references such as registry/app@sha256:<hash>.
+func repoDigests(refs []string) []digest.Digest {
+	var res []digest.Digest
+
+	for _, ref := range refs {
+		if d, err := digest.Parse(ref[strings.LastIndex(ref, "@")+1:]); err == nil {
+			res = append(res, d)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,125 @@
+package vuln
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/mikaellindemann/registryfrontend"
+	"github.com/mikaellindemann/registryfrontend/client"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+)
+
+// attestationTypes are the artifact types of referrers that may be vulnerability attestations.
+var attestationTypes = map[string]bool{
+	"application/vnd.dev.cosign.artifact.att.v1+json": true,
+	"application/vnd.in-toto+json":                    true,
+	client.DSSEMediaType:                              true,
+}
+
+// otherPredicates are prefixes of the predicate types of attestations that are known not to be vulnerability reports,
+// so they are not fetched.
+var otherPredicates = []string{"https://spdx.dev/", "https://cyclonedx.org/", "https://slsa.dev/"}
+
+// Store finds the vulnerability reports of images, both in a directory and in their attestations.
+// The attested reports are cached in memory for the ttl.
+type Store struct {
+	dir      *Directory
+	attested *client.AttachedCache
+}
+
+// NewStore creates a Store reading reports from dir, which may be nil to only use attestations.
+func NewStore(dir *Directory, ttl time.Duration) *Store {
+	return &Store{dir: dir, attested: client.NewAttachedCache(ttl, nil)}
+}
+
+// Reports returns the reports of the image with digest d, from the directory followed by its attestations.
+func (s *Store) Reports(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest) ([]*Report, error) {
+	attested, err := s.attested.Get(c, repository, d, func() (interface{}, error) {
+		return Attested(ctx, c, repository, d)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*Report
+
+	if s.dir != nil {
+		reports = append(reports, s.dir.Reports(d)...)
+	}
+
+	return append(reports, attested.([]*Report)...), nil
+}
+
+// Attested fetches the vulnerability reports in the attestations of the image with digest d.
+// Attestations of other kinds are left out.
+func Attested(ctx context.Context, c registryfrontend.Client, repository string, d digest.Digest) ([]*Report, error) {
+	layers, err := client.Attached(ctx, c, repository, d, []string{".att"}, attestationTypes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var reports []*Report
+
+	for _, l := range layers {
+		if l.MediaType != client.DSSEMediaType || isOtherPredicate(l.Annotations["predicateType"]) {
+			continue
+		}
+
+		content, err := client.ReadBlob(ctx, c, repository, l.Descriptor, client.MaxAttestationSize)
+
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed reading attestation of %s", l.Source)
+		}
+
+		if r, ok := attestedReport(content); ok {
+			r.Source = l.Source
+			reports = append(reports, r)
+		}
+	}
+
+	return reports, nil
+}
+
+func isOtherPredicate(t string) bool {
+	for _, p := range otherPredicates {
+		if strings.HasPrefix(t, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// attestedReport parses the predicate of an in-toto attestation in a DSSE envelope, if it is a vulnerability report.
+func attestedReport(content []byte) (*Report, bool) {
+	env := struct {
+		Payload string `json:"payload"`
+	}{}
+
+	if json.Unmarshal(content, &env) != nil {
+		return nil, false
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(env.Payload)
+
+	if err != nil {
+		return nil, false
+	}
+
+	statement := struct {
+		Predicate json.RawMessage `json:"predicate"`
+	}{}
+
+	if json.Unmarshal(payload, &statement) != nil || len(statement.Predicate) == 0 {
+		return nil, false
+	}
+
+	r, err := Parse(statement.Predicate)
+
+	return r, err == nil
+}
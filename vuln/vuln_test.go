@@ -0,0 +1,93 @@
+package vuln
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/go-digest"
+)
+
+const imageDigest = "sha256:6c3c624b58dbbcd3c0dd82b4c53f04194d1247c6eebdaab7c610cf7d66709b3b"
+
+const trivyReport = `{"SchemaVersion":2,"ArtifactName":"registry.example.com/app:1.0",
+	"Metadata":{"RepoDigests":["registry.example.com/app@` + imageDigest + `"]},
+	"Results":[{"Target":"debian","Vulnerabilities":[
+		{"VulnerabilityID":"CVE-2023-0286","PkgName":"openssl","InstalledVersion":"3.0.7","FixedVersion":"3.0.8","Severity":"HIGH","Title":"X.400 type confusion","PrimaryURL":"https://avd.aquasec.com/nvd/cve-2023-0286"},
+		{"VulnerabilityID":"CVE-2023-4911","PkgName":"libc6","InstalledVersion":"2.36-9","Severity":"CRITICAL"}]}]}`
+
+const grypeReport = `{"descriptor":{"name":"grype"},
+	"source":{"target":{"manifestDigest":"` + imageDigest + `"}},
+	"matches":[
+		{"vulnerability":{"id":"CVE-2023-0286","severity":"High","fix":{"versions":["3.0.8"]}},"artifact":{"name":"openssl","version":"3.0.7"}},
+		{"vulnerability":{"id":"CVE-2022-3715","severity":"Negligible","fix":{"versions":[]}},"artifact":{"name":"bash","version":"5.2.15"}}]}`
+
+func TestParse(t *testing.T) {
+	parse := func(content, scanner string, expected Counts) func(*testing.T) {
+		return func(t *testing.T) {
+			r, err := Parse([]byte(content))
+			if err != nil {
+				t.Fatalf("%+v", err)
+			}
+			if r.Scanner != scanner {
+				t.Errorf("expected scanner %s, got %s", scanner, r.Scanner)
+			}
+			if fmt.Sprint(r.Digests) != "["+imageDigest+"]" {
+				t.Errorf("expected the digest of the image, got %v", r.Digests)
+			}
+			if c := Summarize([]*Report{r}); c != expected {
+				t.Errorf("expected %+v, got %+v", expected, c)
+			}
+		}
+	}
+
+	t.Run("trivy", parse(trivyReport, "Trivy", Counts{Critical: 1, High: 1}))
+	t.Run("grype", parse(grypeReport, "Grype", Counts{High: 1, Low: 1}))
+	t.Run("cosign predicate", parse(`{"scanner":{"uri":"pkg:github/aquasecurity/trivy","result":`+trivyReport+`}}`, "Trivy", Counts{Critical: 1, High: 1}))
+	t.Run("unknown format", func(t *testing.T) {
+		if _, err := Parse([]byte(`{"spdxVersion":"SPDX-2.3"}`)); err == nil {
+			t.Error("expected an error")
+		}
+	})
+	t.Run("merge", func(t *testing.T) {
+		trivy, _ := Parse([]byte(trivyReport))
+		grype, _ := Parse([]byte(grypeReport))
+
+		if c := Summarize([]*Report{trivy, grype}); c != (Counts{Critical: 1, High: 1, Low: 1}) {
+			t.Errorf("expected the shared vulnerability to be counted once, got %+v", c)
+		}
+	})
+}
+
+func TestDirectory(t *testing.T) {
+	path, err := ioutil.TempDir("", "vuln")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	other := digest.FromString("other")
+	write := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(path, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("app.json", trivyReport)
+	write("sha256-"+other.Encoded()+".json", grypeReport)
+	write("broken.json", `{`)
+
+	dir, err := OpenDirectory(path)
+	if err == nil {
+		t.Error("expected the broken report to be reported")
+	}
+
+	if r := dir.Reports(imageDigest); len(r) != 1 || r[0].Scanner != "Trivy" || r[0].Source != "app.json" {
+		t.Errorf("expected the Trivy report for the digest it states, got %+v", r)
+	}
+	if r := dir.Reports(other); len(r) != 1 || r[0].Scanner != "Grype" {
+		t.Errorf("expected the Grype report for the digest of its file name, got %+v", r)
+	}
+}